@@ -15,6 +15,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
 	"github.com/gin-gonic/gin"
 
 	appConfig "github.com/edinfamous/historial-blockchain/internal/config"
@@ -41,70 +42,98 @@ func main() {
 	// Initialiser les services
 	log.Println("🔧 Initialisation des services...")
 
-	// 1. Initialiser DynamoDB
-	dynamoClient, err := initDynamoDBClient(cfg)
+	// 1. Initialiser le stockage
+	repository, err := initRepository(cfg)
 	if err != nil {
-		log.Fatalf("❌ Erreur initialisation DynamoDB: %v", err)
+		log.Fatalf("❌ Erreur initialisation stockage: %v", err)
 	}
-	log.Println("✅ Connecté à DynamoDB")
-
-	dynamoDBService := services.NewDynamoDBService(
-		dynamoClient,
-		cfg.DynamoDBTableHistorial,
-		cfg.DynamoDBTableEvento,
-		cfg.DynamoDBTableBlockchainEvents,
-	)
 
-	// 2. Initialiser Blockchain Service
-	blockchainService, err := services.NewBlockchainService(
-		cfg.BlockchainRPCURL,
-		time.Duration(cfg.BlockchainTimeout)*time.Second,
-		cfg.MaxRetries,
-	)
+	// 2. Initialiser le registre de vérification (blockchain ou local)
+	ledgerVerifier, err := initLedgerVerifier(cfg)
 	if err != nil {
-		log.Printf("⚠️ Avertissement: Impossible de se connecter à la blockchain: %v", err)
+		log.Printf("⚠️ Avertissement: Registre de vérification indisponible: %v", err)
 		log.Println("   Le service continuera sans vérification blockchain stricte")
 		cfg.EnableStrictVerification = false
-	} else {
-		err = blockchainService.VerificarConexion(context.Background())
-		if err != nil {
-			log.Printf("⚠️ Avertissement: Connexion blockchain échouée: %v", err)
-			cfg.EnableStrictVerification = false
-		} else {
-			log.Println("✅ Connecté à la blockchain")
-		}
 	}
 
-	// 3. Initialiser Kafka Service
-	kafkaService := services.NewKafkaService(
-		cfg.KafkaBootstrapServers,
-		cfg.KafkaConsumerGroup,
-		cfg.KafkaTopic,
-		cfg.KafkaProducerTopic,
-	)
+	// 3. Initialiser le bus d'événements (Kafka ou en mémoire)
+	eventBus, memoryEventBus := initEventBus(cfg)
 
-	err = kafkaService.VerificarConexion(context.Background())
+	err = eventBus.VerificarConexion(context.Background())
 	if err != nil {
-		log.Printf("⚠️ Avertissement: Connexion Kafka échouée: %v", err)
+		log.Printf("⚠️ Avertissement: Connexion au bus d'événements échouée: %v", err)
 		log.Println("   Assurez-vous que Kafka est en cours d'exécution")
 	} else {
-		log.Println("✅ Connecté à Kafka")
+		log.Println("✅ Bus d'événements prêt")
 	}
 
 	// 4. Initialiser Historial Service
 	historialService := services.NewHistorialService(
-		dynamoDBService,
-		blockchainService,
-		kafkaService,
+		repository,
+		ledgerVerifier,
+		eventBus,
 		cfg.EnableStrictVerification,
 	)
+	historialService.DefinirParalelismoSincronizacion(services.ParalelismoSincronizacion{
+		Segmentos: cfg.SyncScanSegments,
+		Workers:   cfg.SyncWorkers,
+	})
+	historialService.DefinirFenetreFraicheur(time.Duration(cfg.SyncStalenessWindow) * time.Second)
+	historialService.DefinirRetencionTareas(time.Duration(cfg.TaskTTLHours) * time.Hour)
+
+	// Alimentation de evento_verificado par le stream de la table blockchain
+	var streamConsumer *services.ConsumidorStreamBlockchain
+	if cfg.BlockchainStreamEnabled {
+		streamConsumer, err = initStreamConsumer(cfg, repository, historialService)
+		if err != nil {
+			log.Fatalf("❌ Erreur initialisation stream blockchain: %v", err)
+		}
+		historialService.DefinirAlimentationParStream(true)
+	}
+
+	// 5. Initialiser le registre d'acteurs et la vérification des signatures
+	actorRegistry, err := initActorRegistry(cfg)
+	if err != nil {
+		log.Fatalf("❌ Erreur initialisation registre d'acteurs: %v", err)
+	}
+	if cfg.EnableSignatureVerification {
+		log.Printf("🔏 Vérification des signatures activée (obligatoire: %t)", cfg.RequireSignature)
+		historialService.DefinirVerificateurSignatures(services.NewSignatureVerifier(actorRegistry, cfg.RequireSignature))
+	}
+
+	// 6. Ancrage par lot Merkle des événements reçus sans transaction
+	var merkleBatcher *services.MerkleBatcher
+	if cfg.MerkleBatchEnabled {
+		if anclador, ok := ledgerVerifier.(services.Anclador); ok {
+			var verificador services.LedgerVerifier
+			if cfg.EnableStrictVerification {
+				verificador = ledgerVerifier
+			}
+			merkleBatcher = services.NewMerkleBatcher(
+				repository,
+				anclador,
+				verificador,
+				cfg.MerkleBatchSize,
+				time.Duration(cfg.MerkleBatchInterval)*time.Second,
+			)
+			historialService.DefinirMerkleBatcher(merkleBatcher)
+		} else {
+			log.Println("⚠️ Ancrage par lot désactivé: registre de vérification indisponible")
+		}
+	}
 
 	// Initialiser les handlers
 	healthHandler := handlers.NewHealthHandler()
 	historialHandler := handlers.NewHistorialHandler(historialService)
+	actorHandler := handlers.NewActorHandler(services.NewActorService(actorRegistry))
+
+	var standaloneHandler *handlers.StandaloneHandler
+	if memoryEventBus != nil {
+		standaloneHandler = handlers.NewStandaloneHandler(memoryEventBus)
+	}
 
 	// Configurer les routes
-	router := setupRoutes(cfg, healthHandler, historialHandler)
+	router := setupRoutes(cfg, healthHandler, historialHandler, actorHandler, standaloneHandler)
 
 	// Créer le serveur HTTP
 	server := &http.Server{
@@ -112,26 +141,71 @@ func main() {
 		Handler: router,
 	}
 
-	// Démarrer le consumer Kafka en arrière-plan
+	// Démarrer le consumer d'événements en arrière-plan
 	ctx, cancel := context.WithCancel(context.Background())
 	var wg sync.WaitGroup
 
-	wg.Add(1)
+	// Les tâches de fond (synchronisation globale) s'arrêtent avec le service
+	historialService.DefinirContexteTaches(ctx)
+
+	// Le traitement en cours survit à l'arrêt de la consommation le temps du drainage
+	traitementCtx, arreterTraitement := context.WithCancel(context.Background())
+	defer arreterTraitement()
+	consumerArrete := make(chan struct{})
+
 	go func() {
-		defer wg.Done()
-		log.Println("🎧 Démarrage du consumer Kafka...")
+		defer close(consumerArrete)
+		log.Println("🎧 Démarrage du consumer d'événements...")
 		
 		// Wrapper pour adapter la signature de la fonction
 		handler := func(event *models.TransaccionBlockchainEvent) error {
-			return historialService.TraiterEvenementTransaccion(ctx, event)
+			return historialService.TraiterEvenementTransaccion(traitementCtx, event)
 		}
 		
-		err := kafkaService.ConsumeEvents(ctx, handler)
+		err := eventBus.ConsumeEvents(ctx, handler)
 		if err != nil && ctx.Err() == nil {
-			log.Printf("❌ Erreur consumer Kafka: %v", err)
+			log.Printf("❌ Erreur consumer d'événements: %v", err)
 		}
 	}()
 
+	// Re-vérifier en arrière-plan les événements en attente de confirmation (la profondeur ne fixe
+	// que le nombre de confirmations attendues)
+	if ledgerVerifier != nil {
+		confirmationMonitor := services.NewConfirmationMonitor(
+			repository,
+			ledgerVerifier,
+			uint64(cfg.ConfirmationDepth),
+			time.Duration(cfg.ConfirmationRecheckInterval)*time.Second,
+		)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("⛓️ Re-vérification des confirmations toutes les %ds (profondeur %d)", cfg.ConfirmationRecheckInterval, cfg.ConfirmationDepth)
+			confirmationMonitor.Demarrer(ctx)
+		}()
+	}
+
+	// Ancrer les lots Merkle en arrière-plan
+	if merkleBatcher != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("🌳 Ancrage par lot Merkle: %d événements ou toutes les %ds", cfg.MerkleBatchSize, cfg.MerkleBatchInterval)
+			merkleBatcher.Demarrer(ctx)
+		}()
+	}
+
+	// Lire le stream de la table blockchain en arrière-plan
+	if streamConsumer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			log.Printf("📡 Lecture du stream %s toutes les %ds", cfg.DynamoDBTableBlockchainEvents, cfg.BlockchainStreamPollInterval)
+			streamConsumer.Demarrer(ctx)
+		}()
+	}
+
 	// Démarrer le serveur HTTP
 	go func() {
 		log.Printf("🚀 Serveur démarré sur le port %s", cfg.ServerPort)
@@ -148,7 +222,7 @@ func main() {
 	log.Println("🛑 Arrêt du serveur...")
 
 	// Arrêter gracieusement
-	cancel() // Arrêter le consumer Kafka
+	cancel() // Arrêter le consumer d'événements
 
 	// Arrêter le serveur HTTP avec timeout
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
@@ -158,22 +232,238 @@ func main() {
 		log.Printf("❌ Erreur arrêt serveur: %v", err)
 	}
 
-	// Attendre que le consumer se termine
+	// Drainer le consumer: les messages en cours sont terminés puis validés
+	select {
+	case <-consumerArrete:
+	case <-time.After(time.Duration(cfg.EventDrainTimeout) * time.Second):
+		log.Printf("⚠️ Drainage du consumer dépassé (%ds): les messages en cours seront relivrés", cfg.EventDrainTimeout)
+		arreterTraitement()
+		<-consumerArrete
+	}
+
+	// Attendre que les tâches de fond se terminent
 	wg.Wait()
 
-	// Fermer les services
-	if kafkaService != nil {
-		kafkaService.Close()
+	// Fermer les services (envoie les dernières validations d'offsets)
+	if err := eventBus.Close(); err != nil {
+		log.Printf("❌ Erreur fermeture bus d'événements: %v", err)
 	}
-	if blockchainService != nil {
-		blockchainService.Close()
+	if ledgerVerifier != nil {
+		ledgerVerifier.Close()
 	}
 
 	log.Println("✅ Serveur arrêté proprement")
 }
 
-// initDynamoDBClient initialise le client DynamoDB
-func initDynamoDBClient(cfg *appConfig.Config) (*dynamodb.Client, error) {
+// initRepository initialise le backend de stockage configuré
+func initRepository(cfg *appConfig.Config) (services.HistorialRepository, error) {
+	if cfg.StorageBackend == "memory" {
+		memoryRepository := services.NewMemoryRepository()
+		if cfg.MemorySeedFile != "" {
+			if err := memoryRepository.CargarEventosBlockchain(cfg.MemorySeedFile); err != nil {
+				return nil, err
+			}
+		}
+		log.Println("🧪 Utilisation du stockage en mémoire (données non persistées)")
+		return memoryRepository, nil
+	}
+
+	dynamoClient, err := initDynamoDBClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Println("✅ Connecté à DynamoDB")
+
+	dynamoDBService := services.NewDynamoDBService(
+		dynamoClient,
+		cfg.DynamoDBTableHistorial,
+		cfg.DynamoDBTableEvento,
+		cfg.DynamoDBTableBlockchainEvents,
+	)
+	dynamoDBService.DefinirIndiceProducto(cfg.DynamoDBIndexProducto)
+	dynamoDBService.DefinirTablaPuntosSincronizacion(cfg.DynamoDBTableSync)
+	dynamoDBService.DefinirTablaIntentosVerificacion(cfg.DynamoDBTableVerificaciones)
+	dynamoDBService.DefinirTablaTareas(cfg.DynamoDBTableTasks)
+
+	if cfg.DynamoDBCreateTables {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if cfg.DynamoDBIndexProducto != "" {
+			if err := dynamoDBService.AsegurarTablaEventosBlockchain(ctx); err != nil {
+				return nil, err
+			}
+		}
+		if err := dynamoDBService.AsegurarTablaPuntosSincronizacion(ctx); err != nil {
+			return nil, err
+		}
+		if err := dynamoDBService.AsegurarTablaIntentosVerificacion(ctx); err != nil {
+			return nil, err
+		}
+		if err := dynamoDBService.AsegurarTablaTareas(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return dynamoDBService, nil
+}
+
+// initLedgerVerifier initialise le backend de vérification configuré
+func initLedgerVerifier(cfg *appConfig.Config) (services.LedgerVerifier, error) {
+	reglas := services.ReglasAnclaje{
+		ConfirmacionesRequeridas: uint64(cfg.ConfirmationDepth),
+		ContratosPermitidos:      cfg.ContratosRegistro(),
+		TopicoEvento:             cfg.RegistryEventTopic,
+	}
+	if len(reglas.ContratosPermitidos) == 0 {
+		log.Printf("⚠️ Aucun contrat registre configuré pour %s: toute transaction est acceptée", cfg.BlockchainNetwork)
+	}
+
+	if cfg.LedgerBackend == "local" {
+		localLedger := services.NewLocalLedger()
+		if cfg.LedgerFile != "" {
+			if err := localLedger.CargarDesdeArchivo(cfg.LedgerFile); err != nil {
+				return nil, err
+			}
+		}
+		localLedger.DefinirConfirmaciones(reglas.ConfirmacionesRequeridas)
+		localLedger.DefinirContratosPermitidos(reglas.ContratosPermitidos, reglas.TopicoEvento)
+		log.Println("🧪 Utilisation du registre local (pas de connexion blockchain)")
+		return localLedger, nil
+	}
+
+	blockchainService, err := services.NewBlockchainService(
+		cfg.BlockchainRPCURL,
+		time.Duration(cfg.BlockchainTimeout)*time.Second,
+		cfg.MaxRetries,
+		reglas,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.AnchorPrivateKey != "" {
+		if err := blockchainService.DefinirCleAncrage(cfg.AnchorPrivateKey); err != nil {
+			blockchainService.Close()
+			return nil, err
+		}
+	}
+
+	if err := blockchainService.VerificarConexion(context.Background()); err != nil {
+		blockchainService.Close()
+		return nil, err
+	}
+
+	log.Println("✅ Connecté à la blockchain")
+	return blockchainService, nil
+}
+
+// initActorRegistry initialise le registre des acteurs de confiance
+func initActorRegistry(cfg *appConfig.Config) (services.ActorRegistry, error) {
+	if cfg.StorageBackend == "memory" {
+		memoryActorRegistry := services.NewMemoryActorRegistry()
+		if cfg.TrustedActorsFile != "" {
+			if err := memoryActorRegistry.CargarDesdeArchivo(cfg.TrustedActorsFile); err != nil {
+				return nil, err
+			}
+		}
+		return memoryActorRegistry, nil
+	}
+
+	if cfg.TrustedActorsFile != "" {
+		log.Println("⚠️ TRUSTED_ACTORS_FILE ignoré avec le stockage DynamoDB, utiliser /api/actors")
+	}
+
+	dynamoClient, err := initDynamoDBClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return services.NewDynamoDBActorRegistry(dynamoClient, cfg.DynamoDBTableActors), nil
+}
+
+// initEventBus initialise le bus d'événements configuré. Le bus en mémoire est aussi
+// retourné concrètement pour exposer les routes d'injection du mode standalone.
+func initEventBus(cfg *appConfig.Config) (services.EventBus, *services.MemoryEventBus) {
+	politica := services.PoliticaReintentos{
+		MaxIntentos:   cfg.EventRetryMaxAttempts,
+		EsperaInicial: time.Duration(cfg.EventRetryInitialBackoffMs) * time.Millisecond,
+		EsperaMaxima:  time.Duration(cfg.EventRetryMaxBackoffMs) * time.Millisecond,
+	}
+
+	if cfg.EventBusBackend == "memory" {
+		log.Println("🧪 Utilisation du bus d'événements en mémoire (pas de Kafka)")
+		memoryEventBus := services.NewMemoryEventBus(1000)
+		memoryEventBus.DefinirPoliticaReintentos(politica)
+		memoryEventBus.DefinirConcurrencia(cfg.EventConsumerWorkers)
+		return memoryEventBus, memoryEventBus
+	}
+
+	kafkaService := services.NewKafkaService(
+		cfg.KafkaBootstrapServers,
+		cfg.KafkaConsumerGroup,
+		cfg.KafkaTopic,
+		cfg.KafkaProducerTopic,
+	)
+	kafkaService.DefinirPoliticaReintentos(politica)
+	kafkaService.DefinirConcurrencia(cfg.EventConsumerWorkers)
+	if cfg.KafkaDLQTopic != "" {
+		log.Printf("📮 Messages en échec envoyés en DLQ sur %s", cfg.KafkaDLQTopic)
+		kafkaService.DefinirTopicDLQ(cfg.KafkaDLQTopic)
+	}
+	return kafkaService, nil
+}
+
+// initStreamConsumer initialise le consumer du stream de la table blockchain et ses séquences par shard
+func initStreamConsumer(cfg *appConfig.Config, repository services.HistorialRepository, historialService *services.HistorialService) (*services.ConsumidorStreamBlockchain, error) {
+	dynamoDBService, ok := repository.(*services.DynamoDBService)
+	if !ok {
+		return nil, fmt.Errorf("le stream blockchain nécessite le stockage DynamoDB")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	streamArn := cfg.BlockchainStreamArn
+	if streamArn == "" {
+		var err error
+		streamArn, err = dynamoDBService.ArnStreamEventosBlockchain(ctx, cfg.DynamoDBCreateTables)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	awsConfig, err := loadAWSConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	streamsClient := dynamodbstreams.NewFromConfig(awsConfig, func(o *dynamodbstreams.Options) {
+		if cfg.DynamoDBEndpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.DynamoDBEndpoint)
+		}
+	})
+
+	dynamoClient, err := initDynamoDBClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	puntos := services.NewDynamoDBRegistroPuntosStream(dynamoClient, cfg.DynamoDBTableStreamCheckpoints)
+	if cfg.DynamoDBCreateTables {
+		if err := puntos.AsegurarTabla(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("✅ Stream blockchain: %s", streamArn)
+	return services.NewConsumidorStreamBlockchain(
+		streamsClient,
+		streamArn,
+		historialService,
+		puntos,
+		time.Duration(cfg.BlockchainStreamPollInterval)*time.Second,
+	), nil
+}
+
+// loadAWSConfig charge la configuration AWS (credentials fournis ou chaîne par défaut)
+func loadAWSConfig(cfg *appConfig.Config) (aws.Config, error) {
 	ctx := context.Background()
 
 	var awsConfig aws.Config
@@ -197,7 +487,16 @@ func initDynamoDBClient(cfg *appConfig.Config) (*dynamodb.Client, error) {
 	}
 
 	if err != nil {
-		return nil, fmt.Errorf("impossible de charger la configuration AWS: %w", err)
+		return aws.Config{}, fmt.Errorf("impossible de charger la configuration AWS: %w", err)
+	}
+	return awsConfig, nil
+}
+
+// initDynamoDBClient initialise le client DynamoDB
+func initDynamoDBClient(cfg *appConfig.Config) (*dynamodb.Client, error) {
+	awsConfig, err := loadAWSConfig(cfg)
+	if err != nil {
+		return nil, err
 	}
 
 	// Créer le client DynamoDB
@@ -215,7 +514,7 @@ func initDynamoDBClient(cfg *appConfig.Config) (*dynamodb.Client, error) {
 }
 
 // setupRoutes configure les routes de l'application
-func setupRoutes(cfg *appConfig.Config, healthHandler *handlers.HealthHandler, historialHandler *handlers.HistorialHandler) *gin.Engine {
+func setupRoutes(cfg *appConfig.Config, healthHandler *handlers.HealthHandler, historialHandler *handlers.HistorialHandler, actorHandler *handlers.ActorHandler, standaloneHandler *handlers.StandaloneHandler) *gin.Engine {
 	router := gin.New()
 
 	// Middleware globaux
@@ -246,12 +545,38 @@ func setupRoutes(cfg *appConfig.Config, healthHandler *handlers.HealthHandler, h
 		historialGroup := apiGroup.Group("/historial")
 		{
 			historialGroup.GET("/:idProducto", historialHandler.ObtenerHistorial)
+			historialGroup.GET("/:idProducto/lotes", historialHandler.ListarLotes)
 			historialGroup.POST("/reconstruir", historialHandler.ReconstruirHistorial)
+			historialGroup.POST("/sync", historialHandler.SynchroniserTout)
+			historialGroup.POST("/dlq/replay", historialHandler.RejouerDLQ)
 			historialGroup.GET("/:idProducto/verify/:idEvento", historialHandler.VerificarEvento)
 			historialGroup.GET("/:idProducto/events", historialHandler.ObtenerEventos)
+			historialGroup.GET("/:idProducto/events/:idEvento/proof", historialHandler.ObtenerPruebaMerkle)
+			historialGroup.GET("/:idProducto/events/:idEvento/verifications", historialHandler.ListarVerificaciones)
+			historialGroup.GET("/tasks", historialHandler.ListarTareas)
 			historialGroup.GET("/tasks/:taskId", historialHandler.ObtenerStatusTarea)
 			historialGroup.GET("/inconsistencies", historialHandler.ListarInconsistencias)
 		}
+
+		// Routes du registre d'acteurs de confiance
+		actorGroup := apiGroup.Group("/actors")
+		{
+			actorGroup.POST("", actorHandler.RegistrarActor)
+			actorGroup.GET("", actorHandler.ListarActores)
+			actorGroup.GET("/:idActor", actorHandler.ObtenerActor)
+			actorGroup.POST("/:idActor/keys", actorHandler.RotarClave)
+			actorGroup.POST("/:idActor/keys/:idClave/revoke", actorHandler.RevocarClave)
+		}
+
+		// Routes du mode standalone (bus d'événements en mémoire uniquement)
+		if standaloneHandler != nil {
+			standaloneGroup := apiGroup.Group("/standalone")
+			{
+				standaloneGroup.POST("/events", standaloneHandler.PublicarEvento)
+				standaloneGroup.GET("/published", standaloneHandler.ListarPublicados)
+				standaloneGroup.GET("/dlq", standaloneHandler.ListarDLQ)
+			}
+		}
 	}
 
 	// Route pour metrics Prometheus (si activé)
@@ -0,0 +1,191 @@
+// Commande migrate-historial: copie les historiales d'une ancienne table historial_transparencia
+// (clé idProducto seule, un seul lot conservé par produit) vers une table à clé composite
+// (idProducto, lote). Les lignes sans lote sont rangées sous models.LoteGlobal.
+//
+// Usage:
+//
+//	go run ./cmd/migrate-historial -source historial_transparencia_v1 -target historial_transparencia [-create-table] [-dry-run]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// resumenMigracion compte les lignes traitées
+type resumenMigracion struct {
+	Lues       int
+	Copiees    int
+	Existantes int
+	SansLote   int
+}
+
+func main() {
+	source := flag.String("source", "", "table d'origine à clé idProducto seule (requis)")
+	target := flag.String("target", getEnvOrDefault("DYNAMODB_TABLE_HISTORIAL", "historial_transparencia"), "table cible à clé (idProducto, lote)")
+	createTable := flag.Bool("create-table", false, "créer la table cible si elle n'existe pas")
+	dryRun := flag.Bool("dry-run", false, "afficher ce qui serait copié sans écrire")
+	flag.Parse()
+
+	if *source == "" {
+		log.Fatal("❌ -source est requis")
+	}
+	if *source == *target {
+		log.Fatal("❌ -source et -target doivent être différentes: le schéma de clé d'une table DynamoDB ne peut pas être modifié")
+	}
+
+	ctx := context.Background()
+	client, err := initDynamoDBClient(ctx)
+	if err != nil {
+		log.Fatalf("❌ Erreur initialisation DynamoDB: %v", err)
+	}
+
+	if *createTable && !*dryRun {
+		if err := creerTableCible(ctx, client, *target); err != nil {
+			log.Fatalf("❌ Erreur création table %s: %v", *target, err)
+		}
+	}
+
+	resumen, err := migrer(ctx, client, *source, *target, *dryRun)
+	if err != nil {
+		log.Fatalf("❌ Migration interrompue: %v", err)
+	}
+
+	log.Printf("✅ Migration %s → %s terminée: %d lues, %d copiées, %d déjà présentes, %d sans lote (→ %s)",
+		*source, *target, resumen.Lues, resumen.Copiees, resumen.Existantes, resumen.SansLote, models.LoteGlobal)
+}
+
+// migrer copie chaque ligne de la table source vers la table cible. Une ligne déjà présente dans
+// la cible (même idProducto et lote) n'est jamais écrasée: la migration peut être relancée.
+func migrer(ctx context.Context, client *dynamodb.Client, source, target string, dryRun bool) (*resumenMigracion, error) {
+	resumen := &resumenMigracion{}
+	var startKey map[string]types.AttributeValue
+
+	for {
+		result, err := client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(source),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return resumen, fmt.Errorf("erreur scan %s: %w", source, err)
+		}
+
+		for _, item := range result.Items {
+			resumen.Lues++
+
+			lote, ok := item["lote"].(*types.AttributeValueMemberS)
+			if !ok || lote.Value == "" {
+				item["lote"] = &types.AttributeValueMemberS{Value: models.LoteGlobal}
+				resumen.SansLote++
+			}
+
+			if dryRun {
+				log.Printf("🔎 %s - %s", valeurChaine(item["idProducto"]), valeurChaine(item["lote"]))
+				resumen.Copiees++
+				continue
+			}
+
+			_, err := client.PutItem(ctx, &dynamodb.PutItemInput{
+				TableName:           aws.String(target),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(idProducto)"),
+			})
+			if err != nil {
+				var conditionErr *types.ConditionalCheckFailedException
+				if errors.As(err, &conditionErr) {
+					resumen.Existantes++
+					continue
+				}
+				return resumen, fmt.Errorf("erreur copie %s: %w", valeurChaine(item["idProducto"]), err)
+			}
+			resumen.Copiees++
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return resumen, nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// creerTableCible crée la table à clé composite (idProducto HASH, lote RANGE) si elle est absente
+func creerTableCible(ctx context.Context, client *dynamodb.Client, table string) error {
+	_, err := client.DescribeTable(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)})
+	if err == nil {
+		log.Printf("📋 Table %s déjà existante", table)
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return err
+	}
+
+	_, err = client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("idProducto"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("lote"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("idProducto"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("lote"), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("⏳ Attente de l'activation de la table %s...", table)
+	return dynamodb.NewTableExistsWaiter(client).Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(table)}, 2*time.Minute)
+}
+
+// initDynamoDBClient initialise le client DynamoDB à partir des mêmes variables que le service
+func initDynamoDBClient(ctx context.Context) (*dynamodb.Client, error) {
+	options := []func(*config.LoadOptions) error{
+		config.WithRegion(getEnvOrDefault("AWS_REGION", "us-east-1")),
+	}
+	if accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"); accessKey != "" && secretKey != "" {
+		options = append(options, config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(accessKey, secretKey, "")))
+	}
+
+	awsConfig, err := config.LoadDefaultConfig(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("impossible de charger la configuration AWS: %w", err)
+	}
+
+	endpoint := os.Getenv("DYNAMODB_ENDPOINT")
+	return dynamodb.NewFromConfig(awsConfig, func(o *dynamodb.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+		}
+	}), nil
+}
+
+// valeurChaine retourne la valeur d'un attribut chaîne (vide sinon)
+func valeurChaine(valeur types.AttributeValue) string {
+	if s, ok := valeur.(*types.AttributeValueMemberS); ok {
+		return s.Value
+	}
+	return ""
+}
+
+func getEnvOrDefault(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
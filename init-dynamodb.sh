@@ -160,6 +160,38 @@ main() {
         "AttributeName=idProducto,KeyType=HASH AttributeName=idEvento,KeyType=RANGE" \
         "AttributeName=idProducto,AttributeType=S AttributeName=idEvento,AttributeType=S"
     
+    create_table "sincronizacion_producto" \
+        "AttributeName=idProducto,KeyType=HASH" \
+        "AttributeName=idProducto,AttributeType=S"
+    
+    create_table "puntos_stream_blockchain" \
+        "AttributeName=idShard,KeyType=HASH" \
+        "AttributeName=idShard,AttributeType=S"
+    
+    create_table "intento_verificacion" \
+        "AttributeName=claveEvento,KeyType=HASH AttributeName=idIntento,KeyType=RANGE" \
+        "AttributeName=claveEvento,AttributeType=S AttributeName=idIntento,AttributeType=S"
+    
+    echo -e "${YELLOW}📋 Création de la table tareas_historial...${NC}"
+    aws dynamodb create-table \
+        --table-name tareas_historial \
+        --attribute-definitions AttributeName=taskId,AttributeType=S AttributeName=status,AttributeType=S \
+            AttributeName=idProducto,AttributeType=S AttributeName=createdAt,AttributeType=S \
+        --key-schema AttributeName=taskId,KeyType=HASH \
+        --global-secondary-indexes \
+            'IndexName=status-createdAt-index,KeySchema=[{AttributeName=status,KeyType=HASH},{AttributeName=createdAt,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+            'IndexName=idProducto-createdAt-index,KeySchema=[{AttributeName=idProducto,KeyType=HASH},{AttributeName=createdAt,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+        --billing-mode PAY_PER_REQUEST \
+        --endpoint-url $DYNAMODB_ENDPOINT \
+        --no-cli-pager > /dev/null 2>&1 \
+    && aws dynamodb update-time-to-live \
+        --table-name tareas_historial \
+        --time-to-live-specification Enabled=true,AttributeName=expiraEn \
+        --endpoint-url $DYNAMODB_ENDPOINT \
+        --no-cli-pager > /dev/null 2>&1 \
+    && echo -e "${GREEN}✅ Table tareas_historial créée avec succès${NC}" \
+    || echo -e "${YELLOW}⚠️  Table tareas_historial existe déjà ou erreur${NC}"
+    
     # Attendre que les tables soient actives
     echo -e "${YELLOW}⏳ Attente de l'activation des tables...${NC}"
     sleep 3
@@ -48,6 +48,47 @@ create_table "evento_verificado" \
     'AttributeName=idProducto,KeyType=HASH AttributeName=idEvento,KeyType=RANGE' \
     'AttributeName=idProducto,AttributeType=S AttributeName=idEvento,AttributeType=S'
 
+# Table sincronizacion_producto
+# Clé primaire: idProducto (String)
+create_table "sincronizacion_producto" \
+    'AttributeName=idProducto,KeyType=HASH' \
+    'AttributeName=idProducto,AttributeType=S'
+
+# Table puntos_stream_blockchain
+# Clé primaire: idShard (String)
+create_table "puntos_stream_blockchain" \
+    'AttributeName=idShard,KeyType=HASH' \
+    'AttributeName=idShard,AttributeType=S'
+
+# Table intento_verificacion
+# Clé primaire: claveEvento (String, idProducto#idEvento) + idIntento (String)
+create_table "intento_verificacion" \
+    'AttributeName=claveEvento,KeyType=HASH AttributeName=idIntento,KeyType=RANGE' \
+    'AttributeName=claveEvento,AttributeType=S AttributeName=idIntento,AttributeType=S'
+
+# Table tareas_historial
+# Clé primaire: taskId (String), index par statut et par produit, expiration TTL sur expiraEn
+echo "📋 Création de la table: tareas_historial"
+aws dynamodb create-table \
+    --table-name tareas_historial \
+    --key-schema AttributeName=taskId,KeyType=HASH \
+    --attribute-definitions AttributeName=taskId,AttributeType=S AttributeName=status,AttributeType=S \
+        AttributeName=idProducto,AttributeType=S AttributeName=createdAt,AttributeType=S \
+    --global-secondary-indexes \
+        'IndexName=status-createdAt-index,KeySchema=[{AttributeName=status,KeyType=HASH},{AttributeName=createdAt,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+        'IndexName=idProducto-createdAt-index,KeySchema=[{AttributeName=idProducto,KeyType=HASH},{AttributeName=createdAt,KeyType=RANGE}],Projection={ProjectionType=ALL}' \
+    --billing-mode PAY_PER_REQUEST \
+    --endpoint-url "$ENDPOINT" \
+    --region "$REGION" \
+    --no-cli-pager
+aws dynamodb update-time-to-live \
+    --table-name tareas_historial \
+    --time-to-live-specification Enabled=true,AttributeName=expiraEn \
+    --endpoint-url "$ENDPOINT" \
+    --region "$REGION" \
+    --no-cli-pager
+echo "✅ Table tareas_historial créée"
+
 echo ""
 echo "🎉 Toutes les tables ont été créées avec succès !"
 echo ""
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -17,19 +18,61 @@ type Config struct {
 	DynamoDBTableHistorial string
 	DynamoDBTableEvento    string
 	DynamoDBTableBlockchainEvents string
+	DynamoDBIndexProducto  string // GSI idProducto de la tabla de eventos blockchain (vacío: scan)
+	DynamoDBCreateTables   bool
+	SyncScanSegments       int // Segmentos del scan paralelo de la sincronización global
+	SyncWorkers            int
+	DynamoDBTableSync      string // Puntos de reanudación de la sincronización por producto (vacío: sincronización completa)
+	SyncStalenessWindow    int    // Segundos durante los cuales una sincronización sigue fresca para las lecturas
+	DynamoDBTableVerificaciones string // Registro de los intentos de verificación (vacío: no se conserva)
+	DynamoDBTableTasks     string // Tareas asíncronas (expiración TTL sobre expiraEn)
+	TaskTTLHours           int    // Horas de conservación de una tarea tras su última actualización
+
+	// Stream DynamoDB de la tabla de eventos blockchain
+	BlockchainStreamEnabled       bool
+	BlockchainStreamArn           string // Vacío: se lee de la tabla
+	BlockchainStreamPollInterval  int    // Segundos entre dos lecturas del stream
+	DynamoDBTableStreamCheckpoints string
+	DynamoDBTableActors    string
 	DynamoDBEndpoint       string
 	UseAWSSecrets     bool
 
+	// Stockage
+	StorageBackend    string // dynamodb o memory
+	MemorySeedFile    string
+
+	// Modo de ejecución
+	RunMode         string // default o standalone
+	EventBusBackend string // kafka o memory
+
 	// Kafka
 	KafkaBootstrapServers string
 	KafkaConsumerGroup    string
 	KafkaTopic           string
 	KafkaProducerTopic   string
+	KafkaDLQTopic        string // Cola de mensajes fallidos (vacío: se descartan)
+
+	// Reintentos de un evento con error transitorio antes de la DLQ
+	EventRetryMaxAttempts      int // Intentos en total, incluido el primero
+	EventRetryInitialBackoffMs int
+	EventRetryMaxBackoffMs     int
+	EventDrainTimeout          int // Segundos para terminar los eventos en curso al apagar
+	EventConsumerWorkers       int // Eventos procesados en paralelo (orden conservado por idProducto)
 
 	// Blockchain
 	AlchemyAPIKey     string
 	BlockchainRPCURL  string
 	BlockchainNetwork string
+	LedgerBackend     string // ethereum o local
+	LedgerFile        string
+	RegistryContracts  map[string][]string // Contratos registro autorizados por red
+	RegistryEventTopic string              // Topic 0 del evento de anclaje (opcional)
+	AnchorPrivateKey   string              // Clave del emisor de las transacciones de anclaje (modo lote)
+
+	// Anclaje por lotes Merkle
+	MerkleBatchEnabled  bool
+	MerkleBatchSize     int
+	MerkleBatchInterval int
 
 	// Server
 	ServerPort string
@@ -53,6 +96,13 @@ type Config struct {
 	EnableStrictVerification bool
 	BlockchainTimeout       int
 	MaxRetries             int
+	ConfirmationDepth      int
+	ConfirmationRecheckInterval int
+
+	// Verificación de firmas
+	EnableSignatureVerification bool
+	RequireSignature            bool
+	TrustedActorsFile           string
 }
 
 var AppConfig *Config
@@ -70,19 +120,57 @@ func LoadConfig() (*Config, error) {
 		DynamoDBTableHistorial: getEnvOrDefault("DYNAMODB_TABLE_HISTORIAL", "historial_transparencia"),
 		DynamoDBTableEvento:    getEnvOrDefault("DYNAMODB_TABLE_EVENTO", "evento_verificado"),
 		DynamoDBTableBlockchainEvents: getEnvOrDefault("DYNAMODB_TABLE_BLOCKCHAIN_EVENTS", "blockcahin_medysupyly"),
+		DynamoDBIndexProducto:  getEnvOrDefault("DYNAMODB_INDEX_BLOCKCHAIN_PRODUCTO", "idProducto-index"),
+		DynamoDBCreateTables:   getEnvAsBool("DYNAMODB_CREATE_TABLES", false),
+		SyncScanSegments:       getEnvAsInt("SYNC_SCAN_SEGMENTS", 4),
+		SyncWorkers:            getEnvAsInt("SYNC_WORKERS", 8),
+		DynamoDBTableSync:      getEnvOrDefault("DYNAMODB_TABLE_SYNC", "sincronizacion_producto"),
+		SyncStalenessWindow:    getEnvAsInt("SYNC_STALENESS_WINDOW", 30),
+		DynamoDBTableVerificaciones: getEnvOrDefault("DYNAMODB_TABLE_VERIFICACIONES", "intento_verificacion"),
+		DynamoDBTableTasks:     getEnvOrDefault("DYNAMODB_TABLE_TASKS", "tareas_historial"),
+		TaskTTLHours:           getEnvAsInt("TASK_TTL_HOURS", 72),
+		BlockchainStreamEnabled:        getEnvAsBool("BLOCKCHAIN_STREAM_ENABLED", false),
+		BlockchainStreamArn:            os.Getenv("BLOCKCHAIN_STREAM_ARN"),
+		BlockchainStreamPollInterval:   getEnvAsInt("BLOCKCHAIN_STREAM_POLL_INTERVAL", 1),
+		DynamoDBTableStreamCheckpoints: getEnvOrDefault("DYNAMODB_TABLE_STREAM_CHECKPOINTS", "puntos_stream_blockchain"),
+		DynamoDBTableActors:    getEnvOrDefault("DYNAMODB_TABLE_ACTORS", "actores_confianza"),
 		DynamoDBEndpoint:       os.Getenv("DYNAMODB_ENDPOINT"),
 		UseAWSSecrets:         getEnvAsBool("USE_AWS_SECRETS", false),
 
+		// Stockage
+		StorageBackend: getEnvOrDefault("STORAGE_BACKEND", "dynamodb"),
+		MemorySeedFile: os.Getenv("MEMORY_SEED_FILE"),
+
+		// Modo de ejecución
+		RunMode:         getEnvOrDefault("RUN_MODE", "default"),
+		EventBusBackend: getEnvOrDefault("EVENT_BUS_BACKEND", "kafka"),
+
 		// Kafka
 		KafkaBootstrapServers: getEnvOrDefault("KAFKA_BOOTSTRAP_SERVERS", "localhost:9092"),
 		KafkaConsumerGroup:    getEnvOrDefault("KAFKA_CONSUMER_GROUP", "historial-blockchain-consumer"),
 		KafkaTopic:           getEnvOrDefault("KAFKA_TOPIC", "event.transaccion.blockchain.registered"),
 		KafkaProducerTopic:   getEnvOrDefault("KAFKA_PRODUCER_TOPIC", "event.historial"),
+		KafkaDLQTopic:        getEnvOrDefault("KAFKA_DLQ_TOPIC", ""),
+
+		EventRetryMaxAttempts:      getEnvAsInt("EVENT_RETRY_MAX_ATTEMPTS", 5),
+		EventRetryInitialBackoffMs: getEnvAsInt("EVENT_RETRY_INITIAL_BACKOFF_MS", 200),
+		EventRetryMaxBackoffMs:     getEnvAsInt("EVENT_RETRY_MAX_BACKOFF_MS", 10000),
+		EventDrainTimeout:          getEnvAsInt("EVENT_DRAIN_TIMEOUT", 30),
+		EventConsumerWorkers:       getEnvAsInt("EVENT_CONSUMER_WORKERS", 8),
 
 		// Blockchain
 		AlchemyAPIKey:     os.Getenv("ALCHEMY_API_KEY"),
 		BlockchainRPCURL:  getEnvOrDefault("BLOCKCHAIN_RPC_URL", ""),
 		BlockchainNetwork: getEnvOrDefault("BLOCKCHAIN_NETWORK", "sepolia"),
+		LedgerBackend:     getEnvOrDefault("LEDGER_BACKEND", "ethereum"),
+		LedgerFile:        os.Getenv("LEDGER_FILE"),
+		RegistryEventTopic: os.Getenv("REGISTRY_EVENT_TOPIC"),
+		AnchorPrivateKey:   os.Getenv("ANCHOR_PRIVATE_KEY"),
+
+		// Anclaje por lotes Merkle
+		MerkleBatchEnabled:  getEnvAsBool("MERKLE_BATCH_ENABLED", false),
+		MerkleBatchSize:     getEnvAsInt("MERKLE_BATCH_SIZE", 100),
+		MerkleBatchInterval: getEnvAsInt("MERKLE_BATCH_INTERVAL", 300),
 
 		// Server
 		ServerPort: getEnvOrDefault("SERVER_PORT", "8081"),
@@ -106,14 +194,34 @@ func LoadConfig() (*Config, error) {
 		EnableStrictVerification: getEnvAsBool("ENABLE_STRICT_VERIFICATION", true),
 		BlockchainTimeout:       getEnvAsInt("BLOCKCHAIN_TIMEOUT", 30),
 		MaxRetries:             getEnvAsInt("MAX_RETRIES", 3),
+		ConfirmationDepth:      getEnvAsInt("CONFIRMATION_DEPTH", 1),
+		ConfirmationRecheckInterval: getEnvAsInt("CONFIRMATION_RECHECK_INTERVAL", 60),
+
+		// Verificación de firmas
+		EnableSignatureVerification: getEnvAsBool("ENABLE_SIGNATURE_VERIFICATION", false),
+		RequireSignature:            getEnvAsBool("REQUIRE_SIGNATURE", false),
+		TrustedActorsFile:           os.Getenv("TRUSTED_ACTORS_FILE"),
 	}
 
+	registryContracts, err := parseRegistryContracts(os.Getenv("REGISTRY_CONTRACTS"))
+	if err != nil {
+		return nil, fmt.Errorf("configuración inválida: %w", err)
+	}
+	config.RegistryContracts = registryContracts
+
 	// Construir URL de blockchain si no se proporciona
 	if config.BlockchainRPCURL == "" && config.AlchemyAPIKey != "" {
 		config.BlockchainRPCURL = fmt.Sprintf("https://eth-%s.g.alchemy.com/v2/%s",
 			config.BlockchainNetwork, config.AlchemyAPIKey)
 	}
 
+	// El modo standalone no depende de ningún servicio externo
+	if config.RunMode == "standalone" {
+		config.StorageBackend = "memory"
+		config.LedgerBackend = "local"
+		config.EventBusBackend = "memory"
+	}
+
 	// Validar configuración crítica
 	if err := validateConfig(config); err != nil {
 		return nil, fmt.Errorf("configuración inválida: %w", err)
@@ -124,17 +232,151 @@ func LoadConfig() (*Config, error) {
 }
 
 func validateConfig(config *Config) error {
-	if config.KafkaBootstrapServers == "" {
+	if config.EventBusBackend != "kafka" && config.EventBusBackend != "memory" {
+		return fmt.Errorf("EVENT_BUS_BACKEND debe ser kafka o memory")
+	}
+
+	if config.EventBusBackend == "kafka" && config.KafkaBootstrapServers == "" {
 		return fmt.Errorf("KAFKA_BOOTSTRAP_SERVERS es requerido")
 	}
 
-	if config.BlockchainRPCURL == "" {
+	if config.EventRetryMaxAttempts <= 0 {
+		return fmt.Errorf("EVENT_RETRY_MAX_ATTEMPTS debe ser mayor a 0")
+	}
+
+	if config.EventRetryInitialBackoffMs <= 0 || config.EventRetryMaxBackoffMs < config.EventRetryInitialBackoffMs {
+		return fmt.Errorf("EVENT_RETRY_INITIAL_BACKOFF_MS debe ser mayor a 0 y no superar EVENT_RETRY_MAX_BACKOFF_MS")
+	}
+
+	if config.EventDrainTimeout <= 0 {
+		return fmt.Errorf("EVENT_DRAIN_TIMEOUT debe ser mayor a 0")
+	}
+
+	if config.EventConsumerWorkers <= 0 {
+		return fmt.Errorf("EVENT_CONSUMER_WORKERS debe ser mayor a 0")
+	}
+
+	if config.StorageBackend != "dynamodb" && config.StorageBackend != "memory" {
+		return fmt.Errorf("STORAGE_BACKEND debe ser dynamodb o memory")
+	}
+
+	if config.LedgerBackend != "ethereum" && config.LedgerBackend != "local" {
+		return fmt.Errorf("LEDGER_BACKEND debe ser ethereum o local")
+	}
+
+	if config.LedgerBackend == "ethereum" && config.BlockchainRPCURL == "" {
 		return fmt.Errorf("BLOCKCHAIN_RPC_URL o ALCHEMY_API_KEY es requerido")
 	}
 
+	if config.SyncScanSegments <= 0 {
+		return fmt.Errorf("SYNC_SCAN_SEGMENTS debe ser mayor a 0")
+	}
+
+	if config.SyncWorkers <= 0 {
+		return fmt.Errorf("SYNC_WORKERS debe ser mayor a 0")
+	}
+
+	if config.SyncStalenessWindow < 0 {
+		return fmt.Errorf("SYNC_STALENESS_WINDOW debe ser mayor o igual a 0")
+	}
+
+	if config.TaskTTLHours <= 0 {
+		return fmt.Errorf("TASK_TTL_HOURS debe ser mayor a 0")
+	}
+
+	if config.StorageBackend == "dynamodb" && config.DynamoDBTableTasks == "" {
+		return fmt.Errorf("DYNAMODB_TABLE_TASKS es requerido")
+	}
+
+	if config.BlockchainStreamEnabled && config.StorageBackend != "dynamodb" {
+		return fmt.Errorf("BLOCKCHAIN_STREAM_ENABLED requiere STORAGE_BACKEND=dynamodb")
+	}
+
+	if config.BlockchainStreamPollInterval <= 0 {
+		return fmt.Errorf("BLOCKCHAIN_STREAM_POLL_INTERVAL debe ser mayor a 0")
+	}
+
+	if config.ConfirmationDepth < 0 {
+		return fmt.Errorf("CONFIRMATION_DEPTH debe ser mayor o igual a 0")
+	}
+
+	if config.ConfirmationRecheckInterval <= 0 {
+		return fmt.Errorf("CONFIRMATION_RECHECK_INTERVAL debe ser mayor a 0")
+	}
+
+	if config.RequireSignature && !config.EnableSignatureVerification {
+		return fmt.Errorf("REQUIRE_SIGNATURE requiere ENABLE_SIGNATURE_VERIFICATION")
+	}
+
+	if config.MerkleBatchEnabled {
+		if config.MerkleBatchSize <= 0 {
+			return fmt.Errorf("MERKLE_BATCH_SIZE debe ser mayor a 0")
+		}
+		if config.MerkleBatchInterval <= 0 {
+			return fmt.Errorf("MERKLE_BATCH_INTERVAL debe ser mayor a 0")
+		}
+		if config.LedgerBackend == "ethereum" && config.AnchorPrivateKey == "" {
+			return fmt.Errorf("ANCHOR_PRIVATE_KEY es requerido con MERKLE_BATCH_ENABLED")
+		}
+	}
+
+	if config.RegistryEventTopic != "" && !esHexadecimal(config.RegistryEventTopic, 66) {
+		return fmt.Errorf("REGISTRY_EVENT_TOPIC debe ser un hash hexadecimal de 32 bytes")
+	}
+
 	return nil
 }
 
+// ContratosRegistro devuelve los contratos registro autorizados para la red configurada
+func (c *Config) ContratosRegistro() []string {
+	return c.RegistryContracts[c.BlockchainNetwork]
+}
+
+// parseRegistryContracts interpreta REGISTRY_CONTRACTS con el formato
+// "sepolia=0xabc...,0xdef...;mainnet=0x123..."
+func parseRegistryContracts(value string) (map[string][]string, error) {
+	contratos := make(map[string][]string)
+	if strings.TrimSpace(value) == "" {
+		return contratos, nil
+	}
+
+	for _, entrada := range strings.Split(value, ";") {
+		entrada = strings.TrimSpace(entrada)
+		if entrada == "" {
+			continue
+		}
+
+		red, direcciones, ok := strings.Cut(entrada, "=")
+		red = strings.TrimSpace(red)
+		if !ok || red == "" {
+			return nil, fmt.Errorf("REGISTRY_CONTRACTS debe tener el formato red=0x...,0x...;red=0x...")
+		}
+
+		for _, direccion := range strings.Split(direcciones, ",") {
+			direccion = strings.TrimSpace(direccion)
+			if !esHexadecimal(direccion, 42) {
+				return nil, fmt.Errorf("REGISTRY_CONTRACTS: dirección inválida %q para la red %s", direccion, red)
+			}
+			contratos[red] = append(contratos[red], direccion)
+		}
+	}
+
+	return contratos, nil
+}
+
+// esHexadecimal verifica un valor 0x... de la longitud indicada (prefijo incluido)
+func esHexadecimal(value string, longitud int) bool {
+	if len(value) != longitud || !strings.HasPrefix(value, "0x") {
+		return false
+	}
+	for _, c := range value[2:] {
+		if !strings.ContainsRune("0123456789abcdefABCDEF", c) {
+			return false
+		}
+	}
+	return true
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// ActorHandler gère les requêtes HTTP du registre d'acteurs de confiance
+type ActorHandler struct {
+	actorService *services.ActorService
+}
+
+// NewActorHandler crée une nouvelle instance de ActorHandler
+func NewActorHandler(actorService *services.ActorService) *ActorHandler {
+	return &ActorHandler{
+		actorService: actorService,
+	}
+}
+
+// RegistrarActor maneja POST /api/actors
+func (h *ActorHandler) RegistrarActor(c *gin.Context) {
+	var req models.RegistrarActorRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Données invalides",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	actor, err := h.actorService.RegistrarActor(c.Request.Context(), &req)
+	if err != nil {
+		repondreErreurActor(c, "Erreur enregistrement acteur", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, actor)
+}
+
+// ListarActores maneja GET /api/actors
+func (h *ActorHandler) ListarActores(c *gin.Context) {
+	actores, err := h.actorService.ListarActores(c.Request.Context())
+	if err != nil {
+		repondreErreurActor(c, "Erreur récupération acteurs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"actores": actores,
+		"total":   len(actores),
+	})
+}
+
+// ObtenerActor maneja GET /api/actors/{idActor}
+func (h *ActorHandler) ObtenerActor(c *gin.Context) {
+	actor, err := h.actorService.ObtenerActor(c.Request.Context(), c.Param("idActor"))
+	if err != nil {
+		repondreErreurActor(c, "Erreur récupération acteur", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, actor)
+}
+
+// RotarClave maneja POST /api/actors/{idActor}/keys
+func (h *ActorHandler) RotarClave(c *gin.Context) {
+	var req models.RotarClaveRequest
+
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Données invalides",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	clave, err := h.actorService.RotarClave(c.Request.Context(), c.Param("idActor"), &req)
+	if err != nil {
+		repondreErreurActor(c, "Erreur rotation clé", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, clave)
+}
+
+// RevocarClave maneja POST /api/actors/{idActor}/keys/{idClave}/revoke
+func (h *ActorHandler) RevocarClave(c *gin.Context) {
+	var req models.RevocarClaveRequest
+
+	// Le corps est optionnel: révocation immédiate sans motif
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Données invalides",
+				"details": err.Error(),
+			})
+			return
+		}
+	}
+
+	clave, err := h.actorService.RevocarClave(c.Request.Context(), c.Param("idActor"), c.Param("idClave"), &req)
+	if err != nil {
+		repondreErreurActor(c, "Erreur révocation clé", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, clave)
+}
+
+// repondreErreurActor traduit les erreurs du registre d'acteurs en code HTTP
+func repondreErreurActor(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, services.ErrActorNoEncontrado), errors.Is(err, services.ErrClaveNoEncontrada):
+		status = http.StatusNotFound
+	case errors.Is(err, services.ErrSolicitudInvalida):
+		status = http.StatusBadRequest
+	case errors.Is(err, services.ErrClaveYaRevocada), errors.Is(err, services.ErrActorExistente):
+		status = http.StatusConflict
+	}
+
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
+	})
+}
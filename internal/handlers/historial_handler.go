@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
@@ -60,6 +61,26 @@ func (h *HistorialHandler) ObtenerHistorial(c *gin.Context) {
 	c.JSON(http.StatusOK, historial)
 }
 
+// ListarLotes maneja GET /api/historial/{idProducto}/lotes
+func (h *HistorialHandler) ListarLotes(c *gin.Context) {
+	idProducto := c.Param("idProducto")
+
+	consulta := consultaPaginada(c, 50)
+
+	historiales, suivant, err := h.historialService.ListarHistorialesPorProducto(c.Request.Context(), idProducto, consulta)
+	if err != nil {
+		repondreErreurListe(c, "Erreur récupération historiales", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"idProducto":  idProducto,
+		"historiales": historiales,
+		"total":       len(historiales),
+		"pagination":  paginationReponse(consulta, len(historiales), suivant),
+	})
+}
+
 // ReconstruirHistorial maneja POST /api/historial/reconstruir
 func (h *HistorialHandler) ReconstruirHistorial(c *gin.Context) {
 	var req models.ReconstruirRequest
@@ -119,6 +140,51 @@ func (h *HistorialHandler) ReconstruirHistorial(c *gin.Context) {
 	}
 }
 
+// SynchroniserTout maneja POST /api/historial/sync: synchronisation globale en tâche de fond,
+// avancement consultable via GET /api/historial/tasks/{taskId}
+func (h *HistorialHandler) SynchroniserTout(c *gin.Context) {
+	taskID, err := h.historialService.SynchroniserTousLesEventosBlockchainAsync(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Erreur déclenchement synchronisation",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.ReconstruirResponse{
+		Status: "processing",
+		TaskID: taskID,
+	})
+}
+
+// RejouerDLQ maneja POST /api/historial/dlq/replay: retraite au plus limit messages en lettres mortes
+func (h *HistorialHandler) RejouerDLQ(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(services.MaxRejeuDLQParDefaut)))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "limit invalide",
+		})
+		return
+	}
+
+	resumen, err := h.historialService.RejouerDLQ(c.Request.Context(), limit)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrDLQDesactivada) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{
+			"error":   "Erreur rejeu DLQ",
+			"details": err.Error(),
+			"resumen": resumen,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, resumen)
+}
+
 // VerificarEvento maneja GET /api/historial/{idProducto}/verify/{idEvento}
 func (h *HistorialHandler) VerificarEvento(c *gin.Context) {
 	idProducto := c.Param("idProducto")
@@ -132,6 +198,13 @@ func (h *HistorialHandler) VerificarEvento(c *gin.Context) {
 	}
 
 	evento, err := h.historialService.VerificarEvento(c.Request.Context(), idProducto, idEvento)
+	if errors.Is(err, services.ErrRegistroIndisponible) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"error":   "Registre d'ancrage injoignable, vérification à refaire",
+			"details": err.Error(),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error":   "Erreur vérification événement",
@@ -162,37 +235,86 @@ func (h *HistorialHandler) ObtenerEventos(c *gin.Context) {
 		return
 	}
 
-	// Paramètres de pagination
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "10")
-	
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		page = 1
+	// Paramètres de pagination (cursor opaque renvoyé par la page précédente)
+	consulta := consultaPaginada(c, 10)
+
+	// Obtenir les événements via le service
+	eventos, suivant, err := h.historialService.ObtenerEventosPorProducto(c.Request.Context(), idProducto, tipoEvento, consulta)
+	if err != nil {
+		repondreErreurListe(c, "Erreur récupération événements", err)
+		return
 	}
-	
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 10
+
+	c.JSON(http.StatusOK, gin.H{
+		"eventos":    eventos,
+		"pagination": paginationReponse(consulta, len(eventos), suivant),
+	})
+}
+
+// ObtenerPruebaMerkle maneja GET /api/historial/{idProducto}/events/{idEvento}/proof
+func (h *HistorialHandler) ObtenerPruebaMerkle(c *gin.Context) {
+	idProducto := c.Param("idProducto")
+	idEvento := c.Param("idEvento")
+
+	evento, err := h.historialService.ObtenerPruebaMerkle(c.Request.Context(), idProducto, idEvento)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Erreur récupération preuve Merkle",
+			"details": err.Error(),
+		})
+		return
 	}
 
-	// Obtenir les événements via le service
-	eventos, err := h.historialService.ObtenerEventosPorProducto(c.Request.Context(), idProducto, tipoEvento, page, limit)
+	if evento == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Événement non trouvé",
+		})
+		return
+	}
+
+	if evento.PruebaMerkle == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":                 "Aucune preuve Merkle pour cet événement",
+			"resultadoVerificacion": evento.ResultadoVerificacion,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"idProducto":            evento.IDProducto,
+		"idEvento":              evento.IDEvento,
+		"referenciaBlockchain":  evento.ReferenciaBlockchain,
+		"resultadoVerificacion": evento.ResultadoVerificacion,
+		"pruebaMerkle":          evento.PruebaMerkle,
+	})
+}
+
+// ListarVerificaciones maneja GET /api/historial/{idProducto}/events/{idEvento}/verifications
+func (h *HistorialHandler) ListarVerificaciones(c *gin.Context) {
+	idProducto := c.Param("idProducto")
+	idEvento := c.Param("idEvento")
+
+	intentos, err := h.historialService.ListarVerificaciones(c.Request.Context(), idProducto, idEvento)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Erreur récupération événements",
+			"error":   "Erreur récupération historique des vérifications",
 			"details": err.Error(),
 		})
 		return
 	}
 
+	if intentos == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Événement non trouvé",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"eventos": eventos,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": len(eventos),
-		},
+		"idProducto":     idProducto,
+		"idEvento":       idEvento,
+		"verificaciones": intentos,
+		"total":          len(intentos),
 	})
 }
 
@@ -226,42 +348,94 @@ func (h *HistorialHandler) ObtenerStatusTarea(c *gin.Context) {
 	c.JSON(http.StatusOK, taskStatus)
 }
 
+// ListarTareas maneja GET /api/historial/tasks
+func (h *HistorialHandler) ListarTareas(c *gin.Context) {
+	filtro := services.FiltroTareas{
+		Status:     c.Query("status"),
+		IDProducto: c.Query("idProducto"),
+	}
+	switch filtro.Status {
+	case "", models.TaskStatusProcessing, models.TaskStatusCompleted, models.TaskStatusFailed:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "status doit être processing, completed ou failed",
+		})
+		return
+	}
+
+	// Paramètres de pagination
+	consulta := consultaPaginada(c, 20)
+
+	tareas, suivant, err := h.historialService.ListarTareas(c.Request.Context(), filtro, consulta)
+	if err != nil {
+		repondreErreurListe(c, "Erreur récupération tâches", err)
+		return
+	}
+	if tareas == nil {
+		tareas = []models.TaskStatus{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tareas":     tareas,
+		"pagination": paginationReponse(consulta, len(tareas), suivant),
+	})
+}
+
 // ListarInconsistencias maneja GET /api/historial/inconsistencies
 func (h *HistorialHandler) ListarInconsistencias(c *gin.Context) {
 	// Paramètres de pagination
-	pageStr := c.DefaultQuery("page", "1")
-	limitStr := c.DefaultQuery("limit", "50")
+	consulta := consultaPaginada(c, 50)
 	severidad := c.Query("severidad")
-	
-	page, err := strconv.Atoi(pageStr)
-	if err != nil || page <= 0 {
-		page = 1
-	}
-	
-	limit, err := strconv.Atoi(limitStr)
-	if err != nil || limit <= 0 {
-		limit = 50
-	}
 
 	// Obtenir les inconsistances via le service
-	inconsistencias, err := h.historialService.ListarInconsistencias(c.Request.Context(), severidad, page, limit)
+	inconsistencias, suivant, err := h.historialService.ListarInconsistencias(c.Request.Context(), severidad, consulta)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Erreur récupération inconsistances",
-			"details": err.Error(),
-		})
+		repondreErreurListe(c, "Erreur récupération inconsistances", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"inconsistencias": inconsistencias,
-		"pagination": gin.H{
-			"page":  page,
-			"limit": limit,
-			"total": len(inconsistencias),
-		},
-		"filtres": gin.H{
-			"severidad": severidad,
-		},
+		"pagination":      paginationReponse(consulta, len(inconsistencias), suivant),
+	})
+}
+
+// consultaPaginada lit les paramètres cursor et limit d'une liste
+func consultaPaginada(c *gin.Context, limiteParDefaut int) services.ConsultaPaginada {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(limiteParDefaut)))
+	if err != nil || limit <= 0 {
+		limit = limiteParDefaut
+	}
+	if limit > services.LimitePaginaMaxima {
+		limit = services.LimitePaginaMaxima
+	}
+
+	return services.ConsultaPaginada{
+		Cursor: c.Query("cursor"),
+		Limite: limit,
+	}
+}
+
+// paginationReponse décrit la page renvoyée; nextCursor est absent sur la dernière page
+func paginationReponse(consulta services.ConsultaPaginada, total int, suivant string) gin.H {
+	pagination := gin.H{
+		"limit": consulta.Limite,
+		"total": total,
+	}
+	if suivant != "" {
+		pagination["nextCursor"] = suivant
+	}
+	return pagination
+}
+
+// repondreErreurListe traduit une erreur de lecture paginée (cursor invalide: 400)
+func repondreErreurListe(c *gin.Context, message string, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, services.ErrCursorInvalido) {
+		status = http.StatusBadRequest
+	}
+	c.JSON(status, gin.H{
+		"error":   message,
+		"details": err.Error(),
 	})
 }
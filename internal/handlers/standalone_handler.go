@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// StandaloneHandler expose le bus d'événements en mémoire (mode standalone)
+type StandaloneHandler struct {
+	eventBus *services.MemoryEventBus
+}
+
+// NewStandaloneHandler crée une nouvelle instance de StandaloneHandler
+func NewStandaloneHandler(eventBus *services.MemoryEventBus) *StandaloneHandler {
+	return &StandaloneHandler{
+		eventBus: eventBus,
+	}
+}
+
+// PublicarEvento maneja POST /api/standalone/events
+func (h *StandaloneHandler) PublicarEvento(c *gin.Context) {
+	var event models.TransaccionBlockchainEvent
+
+	if err := c.ShouldBindJSON(&event); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Données invalides",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.eventBus.PublicarTransaccion(c.Request.Context(), &event); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Erreur publication événement",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"status":   "queued",
+		"idEvento": event.IDEvento,
+	})
+}
+
+// ListarPublicados maneja GET /api/standalone/published
+func (h *StandaloneHandler) ListarPublicados(c *gin.Context) {
+	publicados := h.eventBus.Publicados()
+
+	c.JSON(http.StatusOK, gin.H{
+		"publicados": publicados,
+		"total":      len(publicados),
+	})
+}
+
+// ListarDLQ maneja GET /api/standalone/dlq
+func (h *StandaloneHandler) ListarDLQ(c *gin.Context) {
+	dlq := h.eventBus.LettresMortes()
+
+	c.JSON(http.StatusOK, gin.H{
+		"dlq":   dlq,
+		"total": len(dlq),
+	})
+}
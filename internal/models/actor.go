@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// Actor représente un acteur émetteur de la chaîne d'approvisionnement
+type Actor struct {
+	IDActor   string    `json:"idActor" dynamodbav:"idActor"`
+	Nombre    string    `json:"nombre" dynamodbav:"nombre"`
+	TipoActor string    `json:"tipoActor" dynamodbav:"tipoActor"` // fabricante, distribuidor, farmacia
+	CreatedAt time.Time `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt" dynamodbav:"updatedAt"`
+}
+
+// ClaveActor représente une clé publique de confiance d'un acteur émetteur
+type ClaveActor struct {
+	IDActor          string     `json:"idActor" dynamodbav:"idActor"`
+	IDClave          string     `json:"idClave" dynamodbav:"idClave"`
+	Algoritmo        string     `json:"algoritmo" dynamodbav:"algoritmo"`                           // ECDSA_SECP256K1, ED25519 ou X509
+	Direccion        string     `json:"direccion,omitempty" dynamodbav:"direccion,omitempty"`       // Adresse Ethereum (ECDSA secp256k1)
+	ClavePublica     string     `json:"clavePublica,omitempty" dynamodbav:"clavePublica,omitempty"` // Clé publique hex ou base64 (Ed25519)
+	Certificado      string     `json:"certificado,omitempty" dynamodbav:"certificado,omitempty"`   // Certificat PEM (X.509)
+	ValidoDesde      time.Time  `json:"validoDesde" dynamodbav:"validoDesde"`                       // Zéro: valide depuis toujours
+	ValidoHasta      *time.Time `json:"validoHasta,omitempty" dynamodbav:"validoHasta,omitempty"`   // Fixé lors d'une rotation
+	RevocadaEn       *time.Time `json:"revocadaEn,omitempty" dynamodbav:"revocadaEn,omitempty"`
+	MotivoRevocacion string     `json:"motivoRevocacion,omitempty" dynamodbav:"motivoRevocacion,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt" dynamodbav:"createdAt"`
+}
+
+// ActivaEn indique si la clé pouvait signer un événement daté de fecha et reçu par le service à
+// recibidoEn. La fenêtre de validité (rotation) s'applique à la date de l'événement. La révocation
+// s'applique aussi à la date de réception: fecha est fournie par le producteur, et un détenteur de
+// la clé compromise pourrait antidater ses événements. Un recibidoEn nul n'est pas pris en compte.
+func (c *ClaveActor) ActivaEn(fecha, recibidoEn time.Time) bool {
+	if !c.ValidoDesde.IsZero() && fecha.Before(c.ValidoDesde) {
+		return false
+	}
+	if c.ValidoHasta != nil && !fecha.Before(*c.ValidoHasta) {
+		return false
+	}
+	// Une révocation n'invalide que les signatures reçues ou datées après la compromission
+	if c.RevocadaEn != nil && (!fecha.Before(*c.RevocadaEn) || (!recibidoEn.IsZero() && !recibidoEn.Before(*c.RevocadaEn))) {
+		return false
+	}
+	return true
+}
+
+// Algorithmes de signature supportés pour FirmaDigital
+const (
+	AlgoritmoFirmaECDSASecp256k1 = "ECDSA_SECP256K1"
+	AlgoritmoFirmaEd25519        = "ED25519"
+	AlgoritmoFirmaX509           = "X509"
+)
+
+// ActorDetalle représente un acteur avec l'ensemble de ses clés (actives, expirées, révoquées)
+type ActorDetalle struct {
+	Actor
+	Claves []ClaveActor `json:"claves"`
+}
+
+// RegistrarActorRequest représente la requête d'enregistrement d'un acteur
+type RegistrarActorRequest struct {
+	IDActor   string       `json:"idActor" validate:"required"`
+	Nombre    string       `json:"nombre"`
+	TipoActor string       `json:"tipoActor"`
+	Claves    []ClaveActor `json:"claves"`
+}
+
+// RotarClaveRequest représente la requête de rotation de clé (la nouvelle clé remplace les clés actives)
+type RotarClaveRequest struct {
+	Algoritmo    string     `json:"algoritmo" validate:"required"`
+	Direccion    string     `json:"direccion"`
+	ClavePublica string     `json:"clavePublica"`
+	Certificado  string     `json:"certificado"`
+	ValidoDesde  *time.Time `json:"validoDesde"` // Par défaut: maintenant
+}
+
+// RevocarClaveRequest représente la requête de révocation d'une clé
+type RevocarClaveRequest struct {
+	RevocadaEn *time.Time `json:"revocadaEn"` // Date de compromission, par défaut: maintenant
+	Motivo     string     `json:"motivo"`
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // HistorialTransparencia représente l'agrégat racine
 type HistorialTransparencia struct {
@@ -17,6 +20,11 @@ type HistorialTransparencia struct {
 	UpdatedAt           time.Time          `json:"updatedAt" dynamodbav:"updatedAt"`
 }
 
+// LoteGlobal est la valeur de clé de tri de l'historial reconstruit sans filtre de lot. Cette ligne
+// n'est mise à jour que par une reconstruction sans lot, jamais à partir des historiales par lot.
+// (DynamoDB n'accepte pas de clé vide)
+const LoteGlobal = "_GLOBAL"
+
 // EventoVerificado représente un événement vérifié
 type EventoVerificado struct {
 	IDProducto            string            `json:"idProducto" dynamodbav:"idProducto"`
@@ -24,12 +32,20 @@ type EventoVerificado struct {
 	TipoEvento            string            `json:"tipoEvento" dynamodbav:"tipoEvento"`
 	Fecha                 time.Time         `json:"fecha" dynamodbav:"fecha"`
 	Ubicacion             string            `json:"ubicacion" dynamodbav:"ubicacion"`
-	DatosEvento           map[string]interface{} `json:"datosEvento" dynamodbav:"datosEvento"`
+	DatosEvento           map[string]interface{} `json:"datosEvento" dynamodbav:"datosEvento"` // Payload producteur original (haché)
+	Enriquecimiento       map[string]interface{} `json:"enriquecimiento,omitempty" dynamodbav:"enriquecimiento,omitempty"` // Champs ajoutés par le service (non hachés)
 	HashEvento            string            `json:"hashEvento" dynamodbav:"hashEvento"`
+	HashCriptografico     HashCriptografico `json:"hashCriptografico" dynamodbav:"hashCriptografico"`
+	ActorEmisor           string            `json:"actorEmisor,omitempty" dynamodbav:"actorEmisor,omitempty"`
+	FirmaDigital          string            `json:"firmaDigital,omitempty" dynamodbav:"firmaDigital,omitempty"` // Signature du hash par l'acteur émetteur
 	ReferenciaBlockchain  string            `json:"referenciaBlockchain" dynamodbav:"referenciaBlockchain"`
+	BloqueNumero          uint64            `json:"bloqueNumero,omitempty" dynamodbav:"bloqueNumero,omitempty"` // Bloc d'inclusion de la transaction
+	BloqueHash            string            `json:"bloqueHash,omitempty" dynamodbav:"bloqueHash,omitempty"`     // Hash du bloc, pour détecter les réorganisations
+	PruebaMerkle          *PruebaMerkle     `json:"pruebaMerkle,omitempty" dynamodbav:"pruebaMerkle,omitempty"` // Preuve d'inclusion si ancré par lot
 	ResultadoVerificacion string            `json:"resultadoVerificacion" dynamodbav:"resultadoVerificacion"`
 	Observaciones         string            `json:"observaciones" dynamodbav:"observaciones"`
 	RawPayload            string            `json:"rawPayload" dynamodbav:"rawPayload"`
+	Version               int64             `json:"version" dynamodbav:"version"` // Incrémentée à chaque mise à jour de la vérification (verrou optimiste)
 	CreatedAt            time.Time         `json:"createdAt" dynamodbav:"createdAt"`
 }
 
@@ -92,21 +108,56 @@ type ReconstruirResponse struct {
 
 // HashCriptografico value object
 type HashCriptografico struct {
-	Algoritmo  string `json:"algoritmo"`
-	ValorHash  string `json:"valorHash"`
+	Algoritmo  string `json:"algoritmo" dynamodbav:"algoritmo"`
+	ValorHash  string `json:"valorHash" dynamodbav:"valorHash"`
 }
 
-// VerificarIntegridad vérifie l'intégrité du hash
+// VerificarIntegridad vérifie l'intégrité du hash (insensible à la casse et au préfixe 0x)
 func (h *HashCriptografico) VerificarIntegridad(valorLocal string) bool {
-	return h.ValorHash == valorLocal
+	return NormalizarHash(h.ValorHash) == NormalizarHash(valorLocal)
+}
+
+// NormalizarHash met un hash hexadécimal sous forme comparable
+func NormalizarHash(valor string) string {
+	valor = strings.ToLower(strings.TrimSpace(valor))
+	return strings.TrimPrefix(valor, "0x")
 }
 
-// FirmaDigital value object
+// Algorithmes de hash des payloads producteur
+const (
+	AlgoritmoSHA256JCS  = "SHA-256/JCS"  // SHA-256 du JSON canonique RFC 8785 (par défaut)
+	AlgoritmoSHA256JSON = "SHA-256/JSON" // SHA-256 de encoding/json (historique)
+)
+
+// Clés d'enrichissement ajoutées par le service, hors payload haché
+const (
+	EnriquecimientoLote        = "lote"
+	EnriquecimientoActorEmisor = "actorEmisor"
+	EnriquecimientoEstado      = "estado"
+	EnriquecimientoIPFSCid     = "ipfsCid"
+)
+
+// FirmaDigital value object (forme JSON du champ firmaDigital, le certificat est optionnel)
 type FirmaDigital struct {
 	Certificado string `json:"certificado"`
 	Firma       string `json:"firma"`
 }
 
+// PruebaMerkle value object: preuve d'inclusion d'un événement dans un lot dont seule la racine est ancrée
+type PruebaMerkle struct {
+	Raiz       string       `json:"raiz" dynamodbav:"raiz"`             // Racine ancrée (transaction = referenciaBlockchain)
+	Hoja       string       `json:"hoja" dynamodbav:"hoja"`             // Hash du payload producteur de l'événement
+	Indice     int          `json:"indice" dynamodbav:"indice"`         // Position de la feuille dans le lot
+	TamanoLote int          `json:"tamanoLote" dynamodbav:"tamanoLote"` // Nombre de feuilles du lot
+	Hermanos   []NodoMerkle `json:"hermanos" dynamodbav:"hermanos"`     // Chemin de la feuille vers la racine
+}
+
+// NodoMerkle est un nœud frère du chemin d'inclusion
+type NodoMerkle struct {
+	Hash    string `json:"hash" dynamodbav:"hash"`
+	Derecha bool   `json:"derecha" dynamodbav:"derecha"` // Le frère est à droite du nœud courant
+}
+
 // CondicionTransporte value object
 type CondicionTransporte struct {
 	Temperatura     string            `json:"temperatura"`
@@ -114,22 +165,105 @@ type CondicionTransporte struct {
 	RangoPermitido map[string]string `json:"rangoPermitido"`
 }
 
-// TaskStatus représente le statut d'une tâche de reconstruction
+// TaskStatus représente le statut d'une tâche asynchrone (reconstruction ou synchronisation globale)
 type TaskStatus struct {
-	TaskID    string    `json:"taskId"`
-	Status    string    `json:"status"` // processing, completed, failed
-	Result    string    `json:"result,omitempty"`
-	Error     string    `json:"error,omitempty"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
+	TaskID     string                  `json:"taskId" dynamodbav:"taskId"`
+	Tipo       string                  `json:"tipo,omitempty" dynamodbav:"tipo,omitempty"` // reconstruccion, sincronizacion
+	IDProducto string                  `json:"idProducto,omitempty" dynamodbav:"idProducto,omitempty"`
+	Lote       string                  `json:"lote,omitempty" dynamodbav:"lote,omitempty"`
+	Status     string                  `json:"status" dynamodbav:"status"` // processing, completed, failed
+	Result     string                  `json:"result,omitempty" dynamodbav:"result,omitempty"`
+	Error      string                  `json:"error,omitempty" dynamodbav:"error,omitempty"`
+	Progreso   *ProgresoSincronizacion `json:"progreso,omitempty" dynamodbav:"progreso,omitempty"`
+	CreatedAt  time.Time               `json:"createdAt" dynamodbav:"createdAt"`
+	UpdatedAt  time.Time               `json:"updatedAt" dynamodbav:"updatedAt"`
+	ExpiraEn   int64                   `json:"expiraEn,omitempty" dynamodbav:"expiraEn,omitempty"` // Expiration TTL (secondes epoch, 0: jamais)
+}
+
+// Expirada indique si la tâche a dépassé sa date d'expiration (la suppression TTL DynamoDB est différée)
+func (t *TaskStatus) Expirada(maintenant time.Time) bool {
+	return t.ExpiraEn > 0 && t.ExpiraEn <= maintenant.Unix()
 }
 
+// ProgresoSincronizacion décrit l'avancement d'une synchronisation globale depuis la table blockchain
+type ProgresoSincronizacion struct {
+	Segmentos     int        `json:"segmentos"`
+	Leidos        int64      `json:"leidos"`        // Événements lus dans la table source
+	Sincronizados int64      `json:"sincronizados"` // Nouveaux événements vérifiés enregistrés
+	Existentes    int64      `json:"existentes"`    // Événements déjà présents
+	Errores       int64      `json:"errores"`
+	Lotes         int64      `json:"lotes"` // Lots par produit traités par les workers
+	IniciadoEn    time.Time  `json:"iniciadoEn"`
+	TerminadoEn   *time.Time `json:"terminadoEn,omitempty"`
+}
+
+// PuntoSincronizacion est le point de reprise de la synchronisation d'un produit: dernière ligne
+// blockchain vue, ordonnée par (updatedAt, idTransaction)
+type PuntoSincronizacion struct {
+	IDProducto          string    `json:"idProducto" dynamodbav:"idProducto"`
+	UltimoUpdatedAt     string    `json:"ultimoUpdatedAt" dynamodbav:"ultimoUpdatedAt"`
+	UltimoIDTransaction string    `json:"ultimoIdTransaction" dynamodbav:"ultimoIdTransaction"`
+	SincronizadoEn      time.Time `json:"sincronizadoEn" dynamodbav:"sincronizadoEn"`
+}
+
+// Posterior indique si une ligne blockchain est plus récente que le point de reprise. Une ligne sans
+// updatedAt est toujours relue (son ordre est inconnu); la lecture par lot écarte les doublons.
+func (p *PuntoSincronizacion) Posterior(evento BlockchainEvent) bool {
+	if p == nil || evento.UpdatedAt == "" {
+		return true
+	}
+	if evento.UpdatedAt != p.UltimoUpdatedAt {
+		return evento.UpdatedAt > p.UltimoUpdatedAt
+	}
+	return evento.IDTransaction > p.UltimoIDTransaction
+}
+
+// Avanzar déplace le point de reprise sur la ligne si elle est plus récente
+func (p *PuntoSincronizacion) Avanzar(evento BlockchainEvent) {
+	if evento.UpdatedAt == "" || !p.Posterior(evento) {
+		return
+	}
+	p.UltimoUpdatedAt = evento.UpdatedAt
+	p.UltimoIDTransaction = evento.IDTransaction
+}
+
+// IntentoVerificacion trace une tentative de vérification d'un événement. Le journal n'est jamais
+// modifié: EventoVerificado ne garde que le dernier résultat.
+type IntentoVerificacion struct {
+	IDProducto    string    `json:"idProducto" dynamodbav:"idProducto"`
+	IDEvento      string    `json:"idEvento" dynamodbav:"idEvento"`
+	IDIntento     string    `json:"idIntento" dynamodbav:"idIntento"` // Ordonné par date de tentative
+	FechaIntento  time.Time `json:"fechaIntento" dynamodbav:"fechaIntento"`
+	Verificador   string    `json:"verificador" dynamodbav:"verificador"` // Backends utilisés: firma, ethereum, local
+	Origen        string    `json:"origen" dynamodbav:"origen"`           // Déclencheur de la vérification
+	BloqueNumero  uint64    `json:"bloqueNumero,omitempty" dynamodbav:"bloqueNumero,omitempty"`
+	BloqueHash    string    `json:"bloqueHash,omitempty" dynamodbav:"bloqueHash,omitempty"`
+	AlgoritmoHash string    `json:"algoritmoHash,omitempty" dynamodbav:"algoritmoHash,omitempty"`
+	HashCalculado string    `json:"hashCalculado,omitempty" dynamodbav:"hashCalculado,omitempty"`
+	Resultado     string    `json:"resultado" dynamodbav:"resultado"`
+	Observaciones string    `json:"observaciones,omitempty" dynamodbav:"observaciones,omitempty"`
+	Error         string    `json:"error,omitempty" dynamodbav:"error,omitempty"`
+}
+
+// Origines d'une tentative de vérification
+const (
+	OrigenVerificacion   = "VERIFICACION"   // GET /api/historial/:idProducto/verify/:idEvento
+	OrigenReconstruccion = "RECONSTRUCCION" // Reconstruction de l'historial
+	OrigenTransaccion    = "TRANSACCION"    // Événement TransaccionBlockchain reçu
+	OrigenConfirmacion   = "CONFIRMACION"   // Re-vérification des événements en PENDING_CONFIRMATION
+	OrigenStream         = "STREAM"         // Ligne blockchain modifiée lue sur le stream DynamoDB
+)
+
 // Constantes pour les résultats de vérification
 const (
 	VerificacionOK           = "OK"
 	VerificacionHashMismatch = "HASH_MISMATCH" 
 	VerificacionFirmaInvalida = "FIRMA_INVALIDA"
 	VerificacionNotFound     = "NOT_FOUND"
+	VerificacionPendienteConfirmacion = "PENDING_CONFIRMATION" // Hash ancré mais profondeur de confirmation non atteinte
+	VerificacionTxRevertida           = "TX_REVERTED"          // Reçu en échec (status 0)
+	VerificacionContratoIncorrecto    = "WRONG_CONTRACT"       // Transaction hors des contrats registre autorisés
+	VerificacionPendienteAnclaje      = "PENDING_ANCHOR"       // En attente d'ancrage de la racine Merkle de son lot
 )
 
 // Constantes pour les états
@@ -146,6 +280,12 @@ const (
 	TaskStatusFailed     = "failed"
 )
 
+// Types de tâches asynchrones
+const (
+	TipoTareaReconstruccion = "reconstruccion"
+	TipoTareaSincronizacion = "sincronizacion"
+)
+
 // BlockchainEvent représente un événement stocké dans la table blockcahin_medysupyly
 type BlockchainEvent struct {
 	IDTransaction        string            `json:"idTransaction" dynamodbav:"idTransaction"`
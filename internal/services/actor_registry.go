@@ -0,0 +1,182 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// ActorRegistry stocke les acteurs émetteurs et leurs clés publiques de confiance
+type ActorRegistry interface {
+	// GuardarActor crée ou met à jour le profil d'un acteur
+	GuardarActor(ctx context.Context, actor *models.Actor) error
+	// ObtenerActor retourne le profil d'un acteur (nil si inconnu)
+	ObtenerActor(ctx context.Context, idActor string) (*models.Actor, error)
+	// ListarActores retourne tous les acteurs enregistrés
+	ListarActores(ctx context.Context) ([]models.Actor, error)
+	// GuardarClave crée ou met à jour une clé (identifiée par idActor + idClave)
+	GuardarClave(ctx context.Context, clave *models.ClaveActor) error
+	// ObtenerClavesActor retourne toutes les clés d'un acteur, y compris expirées et révoquées
+	ObtenerClavesActor(ctx context.Context, idActor string) ([]models.ClaveActor, error)
+}
+
+// Vérification à la compilation que les implémentations respectent l'interface
+var (
+	_ ActorRegistry = (*MemoryActorRegistry)(nil)
+	_ ActorRegistry = (*DynamoDBActorRegistry)(nil)
+)
+
+// MemoryActorRegistry est un registre d'acteurs en mémoire, chargé depuis un fichier de confiance
+type MemoryActorRegistry struct {
+	mu      sync.RWMutex
+	actores map[string]models.Actor
+	claves  map[string]map[string]models.ClaveActor
+}
+
+// NewMemoryActorRegistry crée un registre d'acteurs vide
+func NewMemoryActorRegistry() *MemoryActorRegistry {
+	return &MemoryActorRegistry{
+		actores: make(map[string]models.Actor),
+		claves:  make(map[string]map[string]models.ClaveActor),
+	}
+}
+
+// RegistrarClave ajoute une clé de confiance pour un acteur (identifiant généré si absent)
+func (mar *MemoryActorRegistry) RegistrarClave(clave models.ClaveActor) {
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	if clave.IDClave == "" {
+		clave.IDClave = fmt.Sprintf("%s-%d", clave.IDActor, len(mar.claves[clave.IDActor])+1)
+	}
+	mar.guardarClave(clave)
+}
+
+// CargarDesdeArchivo charge des clés depuis un fichier JSON (tableau de ClaveActor)
+func (mar *MemoryActorRegistry) CargarDesdeArchivo(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erreur lecture fichier acteurs de confiance: %w", err)
+	}
+
+	var claves []models.ClaveActor
+	if err := json.Unmarshal(data, &claves); err != nil {
+		return fmt.Errorf("erreur parsing fichier acteurs de confiance: %w", err)
+	}
+
+	for _, clave := range claves {
+		mar.RegistrarClave(clave)
+	}
+
+	log.Printf("✅ %d clés d'acteurs de confiance chargées depuis %s", len(claves), path)
+	return nil
+}
+
+// GuardarActor crée ou met à jour le profil d'un acteur
+func (mar *MemoryActorRegistry) GuardarActor(ctx context.Context, actor *models.Actor) error {
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	mar.actores[actor.IDActor] = *actor
+	return nil
+}
+
+// ObtenerActor retourne le profil d'un acteur. Un acteur connu uniquement par ses clés
+// (fichier de confiance) a un profil minimal.
+func (mar *MemoryActorRegistry) ObtenerActor(ctx context.Context, idActor string) (*models.Actor, error) {
+	mar.mu.RLock()
+	defer mar.mu.RUnlock()
+
+	if actor, ok := mar.actores[idActor]; ok {
+		return &actor, nil
+	}
+	if _, ok := mar.claves[idActor]; ok {
+		return &models.Actor{IDActor: idActor}, nil
+	}
+	return nil, nil // Non trouvé
+}
+
+// ListarActores retourne tous les acteurs enregistrés, triés par idActor
+func (mar *MemoryActorRegistry) ListarActores(ctx context.Context) ([]models.Actor, error) {
+	mar.mu.RLock()
+	defer mar.mu.RUnlock()
+
+	actores := make([]models.Actor, 0, len(mar.actores))
+	for _, actor := range mar.actores {
+		actores = append(actores, actor)
+	}
+	for idActor := range mar.claves {
+		if _, ok := mar.actores[idActor]; !ok {
+			actores = append(actores, models.Actor{IDActor: idActor})
+		}
+	}
+
+	sort.Slice(actores, func(i, j int) bool {
+		return actores[i].IDActor < actores[j].IDActor
+	})
+	return actores, nil
+}
+
+// GuardarClave crée ou met à jour une clé
+func (mar *MemoryActorRegistry) GuardarClave(ctx context.Context, clave *models.ClaveActor) error {
+	mar.mu.Lock()
+	defer mar.mu.Unlock()
+
+	mar.guardarClave(copierClave(*clave))
+	return nil
+}
+
+// ObtenerClavesActor retourne les clés d'un acteur, triées par date de début de validité
+func (mar *MemoryActorRegistry) ObtenerClavesActor(ctx context.Context, idActor string) ([]models.ClaveActor, error) {
+	mar.mu.RLock()
+	defer mar.mu.RUnlock()
+
+	claves := make([]models.ClaveActor, 0, len(mar.claves[idActor]))
+	for _, clave := range mar.claves[idActor] {
+		claves = append(claves, copierClave(clave))
+	}
+
+	ordonnerClaves(claves)
+	return claves, nil
+}
+
+// guardarClave enregistre une clé (verrou déjà pris)
+func (mar *MemoryActorRegistry) guardarClave(clave models.ClaveActor) {
+	if _, ok := mar.claves[clave.IDActor]; !ok {
+		mar.claves[clave.IDActor] = make(map[string]models.ClaveActor)
+	}
+	mar.claves[clave.IDActor][clave.IDClave] = clave
+}
+
+// ordonnerClaves trie les clés par début de validité puis par identifiant
+func ordonnerClaves(claves []models.ClaveActor) {
+	sort.Slice(claves, func(i, j int) bool {
+		if !claves[i].ValidoDesde.Equal(claves[j].ValidoDesde) {
+			return claves[i].ValidoDesde.Before(claves[j].ValidoDesde)
+		}
+		return claves[i].IDClave < claves[j].IDClave
+	})
+}
+
+// copierClave copie une clé en détachant les dates optionnelles
+func copierClave(clave models.ClaveActor) models.ClaveActor {
+	clave.ValidoHasta = copierDate(clave.ValidoHasta)
+	clave.RevocadaEn = copierDate(clave.RevocadaEn)
+	return clave
+}
+
+// copierDate copie une date optionnelle
+func copierDate(fecha *time.Time) *time.Time {
+	if fecha == nil {
+		return nil
+	}
+	copie := *fecha
+	return &copie
+}
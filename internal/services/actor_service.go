@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/google/uuid"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// Erreurs du registre d'acteurs, traduites en codes HTTP par les handlers
+var (
+	ErrActorNoEncontrado = errors.New("acteur non trouvé")
+	ErrClaveNoEncontrada = errors.New("clé non trouvée")
+	ErrSolicitudInvalida = errors.New("requête invalide")
+	ErrClaveYaRevocada   = errors.New("clé déjà révoquée")
+	ErrActorExistente    = errors.New("acteur déjà enregistré")
+)
+
+// ActorService gère l'enregistrement des acteurs, la rotation et la révocation de leurs clés
+type ActorService struct {
+	registry ActorRegistry
+}
+
+// NewActorService crée une nouvelle instance de ActorService
+func NewActorService(registry ActorRegistry) *ActorService {
+	return &ActorService{
+		registry: registry,
+	}
+}
+
+// RegistrarActor enregistre un nouvel acteur et ses clés initiales. Un acteur existant est refusé
+// (ErrActorExistente): ses clés se changent par RotarClave, qui clôt les clés ouvertes.
+func (as *ActorService) RegistrarActor(ctx context.Context, req *models.RegistrarActorRequest) (*models.ActorDetalle, error) {
+	if strings.TrimSpace(req.IDActor) == "" {
+		return nil, fmt.Errorf("%w: idActor est requis", ErrSolicitudInvalida)
+	}
+
+	ahora := time.Now().UTC()
+	actor := &models.Actor{
+		IDActor:   req.IDActor,
+		Nombre:    req.Nombre,
+		TipoActor: req.TipoActor,
+		CreatedAt: ahora,
+		UpdatedAt: ahora,
+	}
+
+	existant, err := as.registry.ObtenerActor(ctx, req.IDActor)
+	if err != nil {
+		return nil, err
+	}
+	if existant != nil {
+		return nil, fmt.Errorf("%w: %s (rotation via /api/actors/%s/keys)", ErrActorExistente, req.IDActor, req.IDActor)
+	}
+
+	// Valider toutes les clés avant d'écrire quoi que ce soit
+	claves := make([]models.ClaveActor, 0, len(req.Claves))
+	for _, clave := range req.Claves {
+		clave.IDActor = req.IDActor
+		clave.IDClave = uuid.New().String()
+		clave.RevocadaEn = nil
+		clave.MotivoRevocacion = ""
+		clave.CreatedAt = ahora
+		if err := validerMaterielClave(&clave); err != nil {
+			return nil, err
+		}
+		claves = append(claves, clave)
+	}
+
+	if err := as.registry.GuardarActor(ctx, actor); err != nil {
+		return nil, err
+	}
+	for i := range claves {
+		if err := as.registry.GuardarClave(ctx, &claves[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	log.Printf("✅ Acteur enregistré: %s (%d clés)", actor.IDActor, len(claves))
+	return as.ObtenerActor(ctx, req.IDActor)
+}
+
+// ObtenerActor retourne un acteur avec toutes ses clés
+func (as *ActorService) ObtenerActor(ctx context.Context, idActor string) (*models.ActorDetalle, error) {
+	actor, err := as.registry.ObtenerActor(ctx, idActor)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return nil, ErrActorNoEncontrado
+	}
+
+	claves, err := as.registry.ObtenerClavesActor(ctx, idActor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ActorDetalle{Actor: *actor, Claves: claves}, nil
+}
+
+// ListarActores retourne les acteurs enregistrés
+func (as *ActorService) ListarActores(ctx context.Context) ([]models.Actor, error) {
+	return as.registry.ListarActores(ctx)
+}
+
+// RotarClave ajoute une nouvelle clé et clôt la validité des clés actives à sa date de début.
+// Les signatures antérieures restent vérifiables avec l'ancienne clé.
+func (as *ActorService) RotarClave(ctx context.Context, idActor string, req *models.RotarClaveRequest) (*models.ClaveActor, error) {
+	actor, err := as.registry.ObtenerActor(ctx, idActor)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil {
+		return nil, ErrActorNoEncontrado
+	}
+
+	ahora := time.Now().UTC()
+	validoDesde := ahora
+	if req.ValidoDesde != nil {
+		validoDesde = req.ValidoDesde.UTC()
+	}
+
+	nueva := &models.ClaveActor{
+		IDActor:      idActor,
+		IDClave:      uuid.New().String(),
+		Algoritmo:    req.Algoritmo,
+		Direccion:    req.Direccion,
+		ClavePublica: req.ClavePublica,
+		Certificado:  req.Certificado,
+		ValidoDesde:  validoDesde,
+		CreatedAt:    ahora,
+	}
+	if err := validerMaterielClave(nueva); err != nil {
+		return nil, err
+	}
+
+	claves, err := as.registry.ObtenerClavesActor(ctx, idActor)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clôturer les clés encore ouvertes à la date de début de la nouvelle clé
+	for i := range claves {
+		clave := &claves[i]
+		if clave.RevocadaEn != nil || !clave.ValidoDesde.Before(validoDesde) {
+			continue
+		}
+		if clave.ValidoHasta != nil && !clave.ValidoHasta.After(validoDesde) {
+			continue
+		}
+		fin := validoDesde
+		clave.ValidoHasta = &fin
+		if err := as.registry.GuardarClave(ctx, clave); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := as.registry.GuardarClave(ctx, nueva); err != nil {
+		return nil, err
+	}
+
+	log.Printf("🔑 Rotation de clé pour %s: nouvelle clé %s valide depuis %s", idActor, nueva.IDClave, validoDesde.Format(time.RFC3339))
+	return nueva, nil
+}
+
+// RevocarClave révoque une clé à partir de la date de compromission: les signatures
+// d'événements antérieurs restent valides, les suivantes sont rejetées.
+func (as *ActorService) RevocarClave(ctx context.Context, idActor, idClave string, req *models.RevocarClaveRequest) (*models.ClaveActor, error) {
+	claves, err := as.registry.ObtenerClavesActor(ctx, idActor)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range claves {
+		clave := &claves[i]
+		if clave.IDClave != idClave {
+			continue
+		}
+		if clave.RevocadaEn != nil {
+			return nil, ErrClaveYaRevocada
+		}
+
+		revocadaEn := time.Now().UTC()
+		if req.RevocadaEn != nil {
+			revocadaEn = req.RevocadaEn.UTC()
+		}
+		clave.RevocadaEn = &revocadaEn
+		clave.MotivoRevocacion = req.Motivo
+
+		if err := as.registry.GuardarClave(ctx, clave); err != nil {
+			return nil, err
+		}
+
+		log.Printf("⛔ Clé %s de %s révoquée depuis %s", idClave, idActor, revocadaEn.Format(time.RFC3339))
+		return clave, nil
+	}
+
+	return nil, ErrClaveNoEncontrada
+}
+
+// validerMaterielClave vérifie que la clé publique est exploitable pour son algorithme
+func validerMaterielClave(clave *models.ClaveActor) error {
+	switch clave.Algoritmo {
+	case models.AlgoritmoFirmaECDSASecp256k1:
+		if !common.IsHexAddress(clave.Direccion) {
+			return fmt.Errorf("%w: direccion Ethereum invalide", ErrSolicitudInvalida)
+		}
+	case models.AlgoritmoFirmaEd25519:
+		clavePublica, err := decoderOctets(clave.ClavePublica)
+		if err != nil || len(clavePublica) != ed25519.PublicKeySize {
+			return fmt.Errorf("%w: clavePublica Ed25519 invalide", ErrSolicitudInvalida)
+		}
+	case models.AlgoritmoFirmaX509:
+		if _, err := parserCertificat(clave.Certificado); err != nil {
+			return fmt.Errorf("%w: certificado X.509 invalide: %v", ErrSolicitudInvalida, err)
+		}
+	default:
+		return fmt.Errorf("%w: algorithme de signature non supporté: %s", ErrSolicitudInvalida, clave.Algoritmo)
+	}
+	return nil
+}
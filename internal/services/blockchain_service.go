@@ -2,29 +2,40 @@ package services
 
 import (
 	"context"
-	"crypto/sha256"
+	"crypto/ecdsa"
 	"encoding/hex"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math/big"
+	"strings"
 	"time"
 
-	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethclient"
 
 	"github.com/edinfamous/historial-blockchain/internal/models"
 )
 
-// BlockchainService gère les interactions avec la blockchain
+// BlockchainService gère les interactions avec la blockchain (backend Ethereum du LedgerVerifier)
 type BlockchainService struct {
 	client    *ethclient.Client
 	rpcURL    string
 	timeout   time.Duration
 	maxRetries int
+	reglas    ReglasAnclaje
+	cleAncrage *ecdsa.PrivateKey // Clé du compte qui envoie les transactions d'ancrage (mode lot Merkle)
 }
 
-// NewBlockchainService crée une nouvelle instance de BlockchainService
-func NewBlockchainService(rpcURL string, timeout time.Duration, maxRetries int) (*BlockchainService, error) {
+// signatureFonctionAncrage est la fonction appelée sur le contrat registre pour ancrer une racine
+const signatureFonctionAncrage = "anchor(bytes32)"
+
+// NewBlockchainService crée une nouvelle instance de BlockchainService. Les règles d'ancrage
+// fixent la profondeur de confirmation et les contrats registre acceptés.
+func NewBlockchainService(rpcURL string, timeout time.Duration, maxRetries int, reglas ReglasAnclaje) (*BlockchainService, error) {
 	client, err := ethclient.Dial(rpcURL)
 	if err != nil {
 		return nil, fmt.Errorf("impossible de se connecter à la blockchain: %w", err)
@@ -35,73 +46,260 @@ func NewBlockchainService(rpcURL string, timeout time.Duration, maxRetries int)
 		rpcURL:     rpcURL,
 		timeout:    timeout,
 		maxRetries: maxRetries,
+		reglas:     reglas,
 	}, nil
 }
 
-// VerificarIntegridad vérifie l'intégrité d'un événement contre la blockchain
+// VerificarIntegridad vérifie l'intégrité d'un événement contre le hash ancré dans la transaction
 func (bs *BlockchainService) VerificarIntegridad(ctx context.Context, evento *models.EventoVerificado) error {
 	if evento.ReferenciaBlockchain == "" {
 		return fmt.Errorf("référence blockchain manquante")
 	}
 
+	anclaje, err := bs.obtenerAnclaje(ctx, evento.ReferenciaBlockchain)
+	if errors.Is(err, ethereum.NotFound) {
+		evento.ResultadoVerificacion = models.VerificacionNotFound
+		evento.Observaciones = fmt.Sprintf("Transaction not found: %s", evento.ReferenciaBlockchain)
+		return fmt.Errorf("transaction non trouvée: %w", err)
+	}
+	if err != nil {
+		// Une panne RPC ne dit rien de l'ancrage: pas de verdict
+		return fmt.Errorf("%w: %w", ErrRegistroIndisponible, err)
+	}
+
+	return compararAnclaje(evento, anclaje, bs.reglas)
+}
+
+// obtenerAnclaje lit la transaction et son reçu pour extraire les hashes qu'ils attestent
+func (bs *BlockchainService) obtenerAnclaje(ctx context.Context, referencia string) (*AnclajeTransaccion, error) {
 	// Créer contexte avec timeout
 	ctxWithTimeout, cancel := context.WithTimeout(ctx, bs.timeout)
 	defer cancel()
 
-	// Récupérer la transaction de la blockchain
-	txHash := common.HexToHash(evento.ReferenciaBlockchain)
-	
+	txHash := common.HexToHash(referencia)
+
+	var receipt *types.Receipt
+	var tx *types.Transaction
+	var enAttente bool
+	var bloqueActual uint64
 	var err error
-	
-	// Retry avec backoff exponentiel
+
+	// Retry avec backoff, sauf si la transaction est absente de la chaîne
 	for i := 0; i < bs.maxRetries; i++ {
-		_, err = bs.client.TransactionReceipt(ctxWithTimeout, txHash)
-		if err == nil {
+		tx, enAttente, err = bs.client.TransactionByHash(ctxWithTimeout, txHash)
+		if err == nil && !enAttente {
+			receipt, err = bs.client.TransactionReceipt(ctxWithTimeout, txHash)
+			// Transaction connue sans reçu: pas encore incluse dans un bloc
+			if errors.Is(err, ethereum.NotFound) {
+				enAttente, err = true, nil
+			}
+		}
+		if err == nil && !enAttente {
+			bloqueActual, err = bs.client.BlockNumber(ctxWithTimeout)
+		}
+		if err == nil || errors.Is(err, ethereum.NotFound) {
 			break
 		}
-		
+
 		if i < bs.maxRetries-1 {
 			time.Sleep(time.Duration(i+1) * time.Second)
 		}
 	}
-	
+
 	if err != nil {
-		evento.ResultadoVerificacion = models.VerificacionNotFound
-		evento.Observaciones = fmt.Sprintf("Transaction not found: %v", err)
-		return fmt.Errorf("transaction non trouvée: %w", err)
+		return nil, err
+	}
+	if enAttente {
+		return &AnclajeTransaccion{TxHash: txHash.Hex(), EnAttente: true}, nil
+	}
+
+	// Un appel de création de contrat n'a pas de destinataire
+	destinatario := ""
+	if tx.To() != nil {
+		destinatario = tx.To().Hex()
 	}
 
-	// Calculer le hash local
-	hashLocal, err := bs.calcularHashLocal(evento.DatosEvento)
+	bloqueNumero := receipt.BlockNumber.Uint64()
+	return &AnclajeTransaccion{
+		TxHash:         txHash.Hex(),
+		BloqueNumero:   bloqueNumero,
+		BloqueHash:     receipt.BlockHash.Hex(),
+		Confirmaciones: calcularConfirmaciones(bloqueActual, bloqueNumero),
+		Revertida:      receipt.Status == types.ReceiptStatusFailed,
+		Destinatario:   destinatario,
+		HashesEntrada:  extraireHashesEntrada(tx.Data()),
+		Logs:           extraireLogsAnclaje(receipt.Logs),
+	}, nil
+}
+
+// DefinirCleAncrage configure la clé privée (hex secp256k1) utilisée pour envoyer les transactions d'ancrage
+func (bs *BlockchainService) DefinirCleAncrage(clePriveeHex string) error {
+	cle, err := ethcrypto.HexToECDSA(strings.TrimPrefix(strings.TrimSpace(clePriveeHex), "0x"))
 	if err != nil {
-		evento.ResultadoVerificacion = models.VerificacionHashMismatch
-		evento.Observaciones = fmt.Sprintf("Erreur calcul hash local: %v", err)
-		return fmt.Errorf("erreur calcul hash local: %w", err)
+		return fmt.Errorf("clé d'ancrage invalide: %w", err)
+	}
+	bs.cleAncrage = cle
+	return nil
+}
+
+// AnclarHash envoie une transaction ancrant le hash et attend son inclusion dans un bloc.
+// La transaction appelle anchor(bytes32) sur le premier contrat registre autorisé, ou à défaut
+// est envoyée au compte lui-même avec le hash brut en input data.
+func (bs *BlockchainService) AnclarHash(ctx context.Context, hash string) (string, error) {
+	if bs.cleAncrage == nil {
+		return "", fmt.Errorf("aucune clé d'ancrage configurée")
 	}
 
-	// Comparer les hashs
-	if hashLocal != evento.HashEvento {
-		evento.ResultadoVerificacion = models.VerificacionHashMismatch
-		evento.Observaciones = fmt.Sprintf("Hash mismatch: local=%s, événement=%s", hashLocal, evento.HashEvento)
-		return fmt.Errorf("hash mismatch")
+	octets, err := hex.DecodeString(models.NormalizarHash(hash))
+	if err != nil || len(octets) != common.HashLength {
+		return "", fmt.Errorf("hash à ancrer invalide: %s", hash)
 	}
 
-	evento.ResultadoVerificacion = models.VerificacionOK
-	evento.Observaciones = "Vérification réussie"
-	return nil
+	emetteur := ethcrypto.PubkeyToAddress(bs.cleAncrage.PublicKey)
+	destinataire := emetteur
+	data := octets
+	if len(bs.reglas.ContratosPermitidos) > 0 {
+		destinataire = common.HexToAddress(bs.reglas.ContratosPermitidos[0])
+		data = append(ethcrypto.Keccak256([]byte(signatureFonctionAncrage))[:4], octets...)
+	}
+
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, bs.timeout)
+	defer cancel()
+
+	chainID, err := bs.client.ChainID(ctxWithTimeout)
+	if err != nil {
+		return "", fmt.Errorf("erreur récupération chain ID: %w", err)
+	}
+	nonce, err := bs.client.PendingNonceAt(ctxWithTimeout, emetteur)
+	if err != nil {
+		return "", fmt.Errorf("erreur récupération nonce: %w", err)
+	}
+	gas, err := bs.client.EstimateGas(ctxWithTimeout, ethereum.CallMsg{From: emetteur, To: &destinataire, Data: data})
+	if err != nil {
+		return "", fmt.Errorf("erreur estimation gas: %w", err)
+	}
+	gasTipCap, err := bs.client.SuggestGasTipCap(ctxWithTimeout)
+	if err != nil {
+		return "", fmt.Errorf("erreur estimation pourboire: %w", err)
+	}
+	header, err := bs.client.HeaderByNumber(ctxWithTimeout, nil)
+	if err != nil {
+		return "", fmt.Errorf("erreur récupération bloc courant: %w", err)
+	}
+	if header.BaseFee == nil {
+		return "", fmt.Errorf("réseau sans EIP-1559 non supporté pour l'ancrage")
+	}
+
+	// Plafond = 2 x base fee + pourboire, pour rester inclus si la base fee augmente
+	gasFeeCap := new(big.Int).Add(gasTipCap, new(big.Int).Mul(header.BaseFee, big.NewInt(2)))
+	tx, err := types.SignNewTx(bs.cleAncrage, types.LatestSignerForChainID(chainID), &types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     nonce,
+		GasTipCap: gasTipCap,
+		GasFeeCap: gasFeeCap,
+		Gas:       gas,
+		To:        &destinataire,
+		Data:      data,
+	})
+	if err != nil {
+		return "", fmt.Errorf("erreur signature transaction d'ancrage: %w", err)
+	}
+
+	if err := bs.client.SendTransaction(ctxWithTimeout, tx); err != nil {
+		return "", fmt.Errorf("erreur envoi transaction d'ancrage: %w", err)
+	}
+
+	receipt, err := bs.attendreInclusion(ctx, tx.Hash())
+	if err != nil {
+		return "", err
+	}
+	if receipt.Status == types.ReceiptStatusFailed {
+		return "", fmt.Errorf("transaction d'ancrage revertée: %s", tx.Hash().Hex())
+	}
+
+	return tx.Hash().Hex(), nil
+}
+
+// attendreInclusion interroge le reçu d'une transaction envoyée jusqu'à son inclusion
+func (bs *BlockchainService) attendreInclusion(ctx context.Context, txHash common.Hash) (*types.Receipt, error) {
+	ticker := time.NewTicker(2 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		receipt, err := bs.client.TransactionReceipt(ctx, txHash)
+		if err == nil {
+			return receipt, nil
+		}
+		if !errors.Is(err, ethereum.NotFound) {
+			return nil, fmt.Errorf("erreur attente inclusion %s: %w", txHash.Hex(), err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("transaction d'ancrage %s non incluse: %w", txHash.Hex(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
 }
 
-// calcularHashLocal calcule le hash local des données d'événement
-func (bs *BlockchainService) calcularHashLocal(datosEvento map[string]interface{}) (string, error) {
-	// Convertir en JSON pour calculer le hash
-	jsonData, err := json.Marshal(datosEvento)
+// ObtenerBloqueActual retourne le numéro du dernier bloc de la chaîne
+func (bs *BlockchainService) ObtenerBloqueActual(ctx context.Context) (uint64, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, bs.timeout)
+	defer cancel()
+
+	numero, err := bs.client.BlockNumber(ctxWithTimeout)
 	if err != nil {
-		return "", fmt.Errorf("erreur marshalling JSON: %w", err)
+		return 0, fmt.Errorf("erreur récupération bloc courant: %w", err)
 	}
+	return numero, nil
+}
+
+// ObtenerHashBloque retourne le hash du bloc canonique à la hauteur donnée
+func (bs *BlockchainService) ObtenerHashBloque(ctx context.Context, numero uint64) (string, error) {
+	ctxWithTimeout, cancel := context.WithTimeout(ctx, bs.timeout)
+	defer cancel()
 
-	// Calculer SHA256
-	hash := sha256.Sum256(jsonData)
-	return hex.EncodeToString(hash[:]), nil
+	header, err := bs.client.HeaderByNumber(ctxWithTimeout, new(big.Int).SetUint64(numero))
+	if err != nil {
+		return "", fmt.Errorf("erreur récupération bloc %d: %w", numero, err)
+	}
+	return header.Hash().Hex(), nil
+}
+
+// extraireHashesEntrada extrait le hash candidat de l'input data: input brut (32 octets)
+// ou premier argument bytes32 d'un appel de contrat.
+func extraireHashesEntrada(inputData []byte) []string {
+	switch {
+	case len(inputData) == common.HashLength:
+		return []string{hex.EncodeToString(inputData)}
+	case len(inputData) >= 4+common.HashLength:
+		// Sélecteur de fonction (4 octets) suivi d'un argument bytes32
+		return []string{hex.EncodeToString(inputData[4 : 4+common.HashLength])}
+	}
+	return nil
+}
+
+// extraireLogsAnclaje convertit les logs du reçu en conservant le contrat émetteur et le topic 0
+func extraireLogsAnclaje(logs []*types.Log) []LogAnclaje {
+	resultado := make([]LogAnclaje, 0, len(logs))
+
+	for _, l := range logs {
+		logAnclaje := LogAnclaje{Contrato: l.Address.Hex()}
+		// Le topic 0 est la signature de l'événement, les suivants sont les arguments indexés
+		for i, topic := range l.Topics {
+			if i == 0 {
+				logAnclaje.Topico = topic.Hex()
+				continue
+			}
+			logAnclaje.Hashes = append(logAnclaje.Hashes, hex.EncodeToString(topic.Bytes()))
+		}
+		for offset := 0; offset+common.HashLength <= len(l.Data); offset += common.HashLength {
+			logAnclaje.Hashes = append(logAnclaje.Hashes, hex.EncodeToString(l.Data[offset:offset+common.HashLength]))
+		}
+		resultado = append(resultado, logAnclaje)
+	}
+
+	return resultado
 }
 
 // GetTransactionByHash récupère une transaction par son hash
@@ -139,6 +337,11 @@ func (bs *BlockchainService) VerificarConexion(ctx context.Context) error {
 	return nil
 }
 
+// Nombre identifie le backend Ethereum
+func (bs *BlockchainService) Nombre() string {
+	return "ethereum"
+}
+
 // Close ferme la connexion blockchain
 func (bs *BlockchainService) Close() {
 	if bs.client != nil {
@@ -0,0 +1,445 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	streamstypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/google/uuid"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// ClienteStreams regroupe les appels DynamoDB Streams utilisés par le consumer
+type ClienteStreams interface {
+	DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error)
+	GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error)
+	GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error)
+}
+
+var _ ClienteStreams = (*dynamodbstreams.Client)(nil)
+
+// tamanoPaginaStream est le nombre maximal d'enregistrements lus par GetRecords
+const tamanoPaginaStream = 1000
+
+// ConsumidorStreamBlockchain lit le stream DynamoDB de la table blockcahin_medysupyly et enregistre
+// les lignes insérées ou modifiées dans evento_verificado au fil de l'eau. La séquence du dernier
+// enregistrement traité de chaque shard est conservée: un redémarrage reprend juste après.
+// Un shard enfant n'est lu qu'une fois son parent terminé, pour garder l'ordre des modifications.
+type ConsumidorStreamBlockchain struct {
+	client           ClienteStreams
+	streamArn        string
+	historialService *HistorialService
+	puntos           RegistroPuntosStream
+	intervalo        time.Duration
+
+	// État de lecture, utilisé par une seule boucle à la fois
+	iteradores map[string]string // Itérateur courant de chaque shard ouvert
+	terminados map[string]bool   // Shards fermés entièrement traités
+}
+
+// NewConsumidorStreamBlockchain crée une nouvelle instance de ConsumidorStreamBlockchain
+func NewConsumidorStreamBlockchain(client ClienteStreams, streamArn string, historialService *HistorialService, puntos RegistroPuntosStream, intervalo time.Duration) *ConsumidorStreamBlockchain {
+	return &ConsumidorStreamBlockchain{
+		client:           client,
+		streamArn:        streamArn,
+		historialService: historialService,
+		puntos:           puntos,
+		intervalo:        intervalo,
+		iteradores:       make(map[string]string),
+		terminados:       make(map[string]bool),
+	}
+}
+
+// Demarrer lit le stream jusqu'à l'annulation du contexte
+func (cs *ConsumidorStreamBlockchain) Demarrer(ctx context.Context) {
+	ticker := time.NewTicker(cs.intervalo)
+	defer ticker.Stop()
+
+	for {
+		if _, err := cs.Sondear(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️ Erreur lecture du stream blockchain: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sondear effectue une passe: chaque shard prêt est lu jusqu'au dernier enregistrement disponible.
+// Retourne le nombre d'enregistrements traités; l'erreur d'un shard n'empêche pas la lecture des autres.
+func (cs *ConsumidorStreamBlockchain) Sondear(ctx context.Context) (int, error) {
+	shards, err := cs.listerShards(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Oublier les shards expirés du stream
+	presents := make(map[string]bool, len(shards))
+	for _, shard := range shards {
+		presents[aws.ToString(shard.ShardId)] = true
+	}
+	for idShard := range cs.terminados {
+		if !presents[idShard] {
+			delete(cs.terminados, idShard)
+		}
+	}
+	for idShard := range cs.iteradores {
+		if !presents[idShard] {
+			delete(cs.iteradores, idShard)
+		}
+	}
+
+	traites := 0
+	var erreurs []error
+	for _, shard := range shards {
+		idShard := aws.ToString(shard.ShardId)
+		if cs.terminados[idShard] {
+			continue
+		}
+		if parent := aws.ToString(shard.ParentShardId); parent != "" && presents[parent] && !cs.terminados[parent] {
+			continue // Relu à une prochaine passe, une fois le parent terminé
+		}
+
+		n, err := cs.lireShard(ctx, idShard)
+		traites += n
+		if err != nil {
+			erreurs = append(erreurs, fmt.Errorf("shard %s: %w", idShard, err))
+		}
+	}
+
+	return traites, errors.Join(erreurs...)
+}
+
+// listerShards décrit tous les shards du stream
+func (cs *ConsumidorStreamBlockchain) listerShards(ctx context.Context) ([]streamstypes.Shard, error) {
+	var shards []streamstypes.Shard
+	var depuis *string
+	for {
+		sortie, err := cs.client.DescribeStream(ctx, &dynamodbstreams.DescribeStreamInput{
+			StreamArn:             aws.String(cs.streamArn),
+			ExclusiveStartShardId: depuis,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("erreur description du stream: %w", err)
+		}
+		if sortie.StreamDescription == nil {
+			return shards, nil
+		}
+		shards = append(shards, sortie.StreamDescription.Shards...)
+		depuis = sortie.StreamDescription.LastEvaluatedShardId
+		if depuis == nil {
+			return shards, nil
+		}
+	}
+}
+
+// lireShard traite les enregistrements disponibles d'un shard et enregistre leur séquence
+func (cs *ConsumidorStreamBlockchain) lireShard(ctx context.Context, idShard string) (int, error) {
+	iterateur, ok := cs.iteradores[idShard]
+	if !ok {
+		var err error
+		iterateur, err = cs.ouvrirShard(ctx, idShard)
+		if err != nil {
+			return 0, err
+		}
+		if iterateur == "" {
+			cs.terminados[idShard] = true
+			return 0, nil
+		}
+	}
+
+	traites := 0
+	for iterateur != "" {
+		sortie, err := cs.client.GetRecords(ctx, &dynamodbstreams.GetRecordsInput{
+			ShardIterator: aws.String(iterateur),
+			Limit:         aws.Int32(tamanoPaginaStream),
+		})
+		if err != nil {
+			// Le shard est rouvert depuis la séquence enregistrée à la prochaine passe
+			delete(cs.iteradores, idShard)
+			var expire *streamstypes.ExpiredIteratorException
+			if errors.As(err, &expire) {
+				return traites, nil
+			}
+			return traites, fmt.Errorf("erreur lecture des enregistrements: %w", err)
+		}
+
+		if len(sortie.Records) > 0 {
+			if err := cs.traiterEnregistrements(ctx, sortie.Records); err != nil {
+				delete(cs.iteradores, idShard)
+				return traites, err
+			}
+			traites += len(sortie.Records)
+
+			derniere := sortie.Records[len(sortie.Records)-1].Dynamodb
+			if derniere != nil && derniere.SequenceNumber != nil {
+				// Sans séquence enregistrée, ces enregistrements seront relus: l'enregistrement par lot ignore les existants
+				if err := cs.puntos.GuardarSecuenciaShard(ctx, idShard, *derniere.SequenceNumber); err != nil {
+					log.Printf("⚠️ %v", err)
+				}
+			}
+		}
+
+		if sortie.NextShardIterator == nil {
+			log.Printf("📋 Shard %s du stream blockchain fermé et entièrement traité", idShard)
+			cs.terminados[idShard] = true
+			delete(cs.iteradores, idShard)
+			return traites, nil
+		}
+
+		iterateur = *sortie.NextShardIterator
+		cs.iteradores[idShard] = iterateur
+		if len(sortie.Records) == 0 {
+			break // À jour: la suite à la prochaine passe
+		}
+	}
+
+	return traites, nil
+}
+
+// ouvrirShard obtient un itérateur juste après la séquence enregistrée, ou au début du shard
+func (cs *ConsumidorStreamBlockchain) ouvrirShard(ctx context.Context, idShard string) (string, error) {
+	secuencia, err := cs.puntos.ObtenerSecuenciaShard(ctx, idShard)
+	if err != nil {
+		return "", err
+	}
+
+	entree := &dynamodbstreams.GetShardIteratorInput{
+		StreamArn:         aws.String(cs.streamArn),
+		ShardId:           aws.String(idShard),
+		ShardIteratorType: streamstypes.ShardIteratorTypeTrimHorizon,
+	}
+	if secuencia != "" {
+		entree.ShardIteratorType = streamstypes.ShardIteratorTypeAfterSequenceNumber
+		entree.SequenceNumber = aws.String(secuencia)
+	}
+
+	sortie, err := cs.client.GetShardIterator(ctx, entree)
+	var expiree *streamstypes.TrimmedDataAccessException
+	if err != nil && secuencia != "" && errors.As(err, &expiree) {
+		// Les lignes sorties de la rétention du stream sont rattrapées par la synchronisation à la lecture
+		log.Printf("⚠️ Séquence %s du shard %s hors rétention, reprise au début du shard", secuencia, idShard)
+		entree.ShardIteratorType = streamstypes.ShardIteratorTypeTrimHorizon
+		entree.SequenceNumber = nil
+		sortie, err = cs.client.GetShardIterator(ctx, entree)
+	}
+	if err != nil {
+		return "", fmt.Errorf("erreur ouverture du shard: %w", err)
+	}
+
+	return aws.ToString(sortie.ShardIterator), nil
+}
+
+// traiterEnregistrements enregistre les lignes insérées ou modifiées. Une ligne modifiée dont
+// l'événement existait déjà est revue par revisarModificacionesBlockchain (revérification, ou
+// inconsistance si son contenu ancré a changé); une suppression dans la table source n'efface pas
+// l'historique.
+func (cs *ConsumidorStreamBlockchain) traiterEnregistrements(ctx context.Context, enregistrements []streamstypes.Record) error {
+	var eventos, modifies []models.BlockchainEvent
+	for _, enregistrement := range enregistrements {
+		if enregistrement.EventName != streamstypes.OperationTypeInsert && enregistrement.EventName != streamstypes.OperationTypeModify {
+			continue
+		}
+		if enregistrement.Dynamodb == nil || enregistrement.Dynamodb.NewImage == nil {
+			log.Printf("⚠️ Enregistrement %s sans nouvelle image (StreamViewType NEW_IMAGE requis)", aws.ToString(enregistrement.EventID))
+			continue
+		}
+
+		evento, err := eventoBlockchainDepuisImage(enregistrement.Dynamodb.NewImage)
+		if err != nil {
+			log.Printf("⚠️ Enregistrement %s ignoré: %v", aws.ToString(enregistrement.EventID), err)
+			continue
+		}
+		eventos = append(eventos, *evento)
+		if enregistrement.EventName == streamstypes.OperationTypeModify {
+			modifies = append(modifies, *evento)
+		}
+	}
+	if len(eventos) == 0 {
+		return nil
+	}
+
+	// Les événements des lignes modifiées sont lus avant la synchronisation, qui ignore les existants
+	existants, err := cs.historialService.eventosExistants(ctx, modifies)
+	if err != nil {
+		return err
+	}
+
+	resumen, err := cs.historialService.synchroniserEvenementsBlockchain(ctx, eventos)
+	if err != nil {
+		return err
+	}
+	revision, err := cs.historialService.revisarModificacionesBlockchain(ctx, modifies, existants)
+	if err != nil {
+		return err
+	}
+	log.Printf("📡 Stream blockchain: %d nouveaux, %d existants, %d erreurs, %d revus, %d inconsistances",
+		resumen.nuevos, resumen.existentes, resumen.errores, revision.revisados, revision.inconsistentes)
+	return nil
+}
+
+// resumenRevision compte le sort des lignes modifiées dont l'événement existait déjà
+type resumenRevision struct {
+	revisados      int
+	inconsistentes int
+}
+
+// eventosExistants lit les événements vérifiés des lignes blockchain données, par clé
+func (hs *HistorialService) eventosExistants(ctx context.Context, eventosBlockchain []models.BlockchainEvent) (map[ClaveEvento]models.EventoVerificado, error) {
+	if len(eventosBlockchain) == 0 {
+		return nil, nil
+	}
+
+	claves := make([]ClaveEvento, 0, len(eventosBlockchain))
+	for _, eventoBC := range eventosBlockchain {
+		claves = append(claves, ClaveEvento{IDProducto: eventoBC.IDProducto, IDEvento: eventoBC.IDTransaction})
+	}
+	lus, err := hs.repository.ObtenerEventosLote(ctx, claves)
+	if err != nil {
+		return nil, fmt.Errorf("erreur lecture événements modifiés: %w", err)
+	}
+
+	existants := make(map[ClaveEvento]models.EventoVerificado, len(lus))
+	for _, evento := range lus {
+		existants[ClaveEvento{IDProducto: evento.IDProducto, IDEvento: evento.IDEvento}] = evento
+	}
+	return existants, nil
+}
+
+// revisarModificacionesBlockchain applique les lignes blockchain modifiées à leurs événements déjà
+// enregistrés (existants, lus avant la synchronisation). Une modification du contenu ancré (payload,
+// hash, transaction, signature, émetteur) est publiée comme inconsistance: l'événement enregistré
+// reste la référence. Sinon l'événement est revérifié, ou reçoit le résultat du nouvel état de la
+// ligne sans vérificateur, par la mise à jour contrôlée par version. Seul un registre d'ancrage
+// injoignable interrompt la revue (le lot du stream est relu).
+func (hs *HistorialService) revisarModificacionesBlockchain(ctx context.Context, modifies []models.BlockchainEvent, existants map[ClaveEvento]models.EventoVerificado) (resumenRevision, error) {
+	var resumen resumenRevision
+	detalles := make(map[string][]models.InconsistenciaDetalle)
+
+	for _, eventoBC := range modifies {
+		clave := ClaveEvento{IDProducto: eventoBC.IDProducto, IDEvento: eventoBC.IDTransaction}
+		evento, ok := existants[clave]
+		if !ok {
+			continue // Créé par la synchronisation
+		}
+		candidat, err := hs.convertirBlockchainEventEnEventoVerificado(eventoBC)
+		if err != nil {
+			log.Printf("⚠️ Erreur conversion événement modifié %s: %v", eventoBC.IDTransaction, err)
+			continue
+		}
+
+		if champs := champsAnclesModifies(&evento, candidat); len(champs) > 0 {
+			log.Printf("🚨 Ligne blockchain %s modifiée après enregistrement: %s", eventoBC.IDTransaction, strings.Join(champs, ", "))
+			detalles[eventoBC.IDProducto] = append(detalles[eventoBC.IDProducto], models.InconsistenciaDetalle{
+				IDEvento: eventoBC.IDTransaction,
+				Error:    fmt.Sprintf("ligne blockchain_medysupply modifiée après enregistrement (%s)", strings.Join(champs, ", ")),
+			})
+			resumen.inconsistentes++
+			continue
+		}
+
+		// Un événement en attente d'ancrage est mis à jour par le lot Merkle
+		if evento.ResultadoVerificacion == models.VerificacionPendienteAnclaje {
+			continue
+		}
+
+		if hs.peutVerifier(&evento) {
+			intento, err := hs.verifierEvento(ctx, &evento, models.OrigenStream)
+			hs.tracerIntentos(ctx, intento)
+			if errors.Is(err, ErrRegistroIndisponible) {
+				return resumen, fmt.Errorf("revérification événement %s: %w", evento.IDEvento, err)
+			}
+			if err != nil {
+				log.Printf("⚠️ Échec vérification événement %s: %v", evento.IDEvento, err)
+			}
+			if err := hs.enregistrerVerification(ctx, &evento, models.OrigenStream); err != nil {
+				if errors.Is(err, ErrRegistroIndisponible) {
+					return resumen, err
+				}
+				log.Printf("⚠️ Erreur sauvegarde événement vérifié: %v", err)
+				continue
+			}
+			resumen.revisados++
+			continue
+		}
+
+		if candidat.ResultadoVerificacion == evento.ResultadoVerificacion {
+			continue
+		}
+		evento.ResultadoVerificacion = candidat.ResultadoVerificacion
+		evento.Observaciones = candidat.Observaciones
+		if err := hs.repository.ActualizarResultadoVerificacion(ctx, &evento); err != nil {
+			// Un conflit signifie qu'un autre processus vient d'enregistrer un résultat: il est conservé
+			log.Printf("⚠️ Erreur mise à jour état événement %s: %v", evento.IDEvento, err)
+			continue
+		}
+		resumen.revisados++
+	}
+
+	if hs.eventBus != nil {
+		for idProducto, detallesProducto := range detalles {
+			event := &models.InconsistenciaEvent{
+				SchemaVersion: "1.0",
+				IDProducto:    idProducto,
+				Detalles:      detallesProducto,
+				Timestamp:     time.Now(),
+				CorrelationID: uuid.New().String(),
+			}
+			if err := hs.eventBus.PublishInconsistencia(ctx, event); err != nil {
+				log.Printf("⚠️ Erreur publication événement inconsistance: %v", err)
+			}
+		}
+	}
+
+	return resumen, nil
+}
+
+// champsAnclesModifies liste les champs du contenu ancré qui diffèrent entre l'événement enregistré
+// et la nouvelle image de sa ligne blockchain (le payload est comparé sous forme canonique)
+func champsAnclesModifies(enregistre, candidat *models.EventoVerificado) []string {
+	var champs []string
+	avant, errAvant := CanonicalizarJSON(enregistre.DatosEvento)
+	apres, errApres := CanonicalizarJSON(candidat.DatosEvento)
+	if errAvant != nil || errApres != nil || !bytes.Equal(avant, apres) {
+		champs = append(champs, "datosEvento")
+	}
+	if enregistre.HashEvento != candidat.HashEvento {
+		champs = append(champs, "hashEvento")
+	}
+	if enregistre.ReferenciaBlockchain != candidat.ReferenciaBlockchain {
+		champs = append(champs, "directionBlockchain")
+	}
+	if enregistre.FirmaDigital != candidat.FirmaDigital {
+		champs = append(champs, "firmaDigital")
+	}
+	if enregistre.ActorEmisor != candidat.ActorEmisor {
+		champs = append(champs, "actorEmisor")
+	}
+	return champs
+}
+
+// eventoBlockchainDepuisImage décode l'image d'une ligne blockcahin_medysupyly
+func eventoBlockchainDepuisImage(image map[string]streamstypes.AttributeValue) (*models.BlockchainEvent, error) {
+	item, err := attributevalue.FromDynamoDBStreamsMap(image)
+	if err != nil {
+		return nil, fmt.Errorf("erreur conversion image: %w", err)
+	}
+
+	var evento models.BlockchainEvent
+	if err := attributevalue.UnmarshalMap(item, &evento); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling événement blockchain: %w", err)
+	}
+	return &evento, nil
+}
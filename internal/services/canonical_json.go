@@ -0,0 +1,214 @@
+package services
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// CanonicalizarJSON sérialise une valeur selon le JSON Canonicalization Scheme (RFC 8785):
+// clés triées par unités UTF-16, aucun espace, nombres au format ECMAScript.
+func CanonicalizarJSON(valor interface{}) ([]byte, error) {
+	// Normaliser vers les types JSON génériques (map, slice, float64, string, bool, nil)
+	jsonData, err := json.Marshal(valor)
+	if err != nil {
+		return nil, fmt.Errorf("erreur marshalling JSON: %w", err)
+	}
+
+	var generique interface{}
+	if err := json.Unmarshal(jsonData, &generique); err != nil {
+		return nil, fmt.Errorf("erreur normalisation JSON: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := ecrireCanonique(&buf, generique); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// CalcularHashDatos calcule le hash des données producteur selon l'algorithme demandé
+func CalcularHashDatos(datosEvento map[string]interface{}, algoritmo string) (string, error) {
+	var contenu []byte
+	var err error
+
+	switch algoritmo {
+	case "", models.AlgoritmoSHA256JCS:
+		contenu, err = CanonicalizarJSON(datosEvento)
+	case models.AlgoritmoSHA256JSON:
+		contenu, err = json.Marshal(datosEvento)
+	default:
+		return "", fmt.Errorf("algorithme de hash non supporté: %s", algoritmo)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(contenu)
+	return hex.EncodeToString(hash[:]), nil
+}
+
+// ecrireCanonique écrit récursivement une valeur JSON générique sous forme canonique
+func ecrireCanonique(buf *bytes.Buffer, valor interface{}) error {
+	switch v := valor.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if v {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case float64:
+		nombre, err := formaterNombreES6(v)
+		if err != nil {
+			return err
+		}
+		buf.WriteString(nombre)
+	case string:
+		ecrireChaineCanonique(buf, v)
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, elem := range v {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := ecrireCanonique(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		cles := make([]string, 0, len(v))
+		for k := range v {
+			cles = append(cles, k)
+		}
+		sort.Slice(cles, func(i, j int) bool {
+			return comparerUTF16(cles[i], cles[j]) < 0
+		})
+
+		buf.WriteByte('{')
+		for i, k := range cles {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			ecrireChaineCanonique(buf, k)
+			buf.WriteByte(':')
+			if err := ecrireCanonique(buf, v[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("type JSON non supporté: %T", valor)
+	}
+	return nil
+}
+
+// ecrireChaineCanonique écrit une chaîne avec l'échappement minimal de JSON.stringify
+func ecrireChaineCanonique(buf *bytes.Buffer, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
+
+// formaterNombreES6 formate un nombre comme Number.prototype.toString d'ECMAScript
+func formaterNombreES6(f float64) (string, error) {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return "", fmt.Errorf("nombre non représentable en JSON: %v", f)
+	}
+	if f == 0 {
+		return "0", nil // couvre aussi -0
+	}
+
+	signe := ""
+	if f < 0 {
+		signe = "-"
+		f = -f
+	}
+
+	// Représentation la plus courte: "d.dddde±XX"
+	scientifique := strconv.FormatFloat(f, 'e', -1, 64)
+	mantisse, exposantStr, _ := strings.Cut(scientifique, "e")
+	chiffres := strings.Replace(mantisse, ".", "", 1)
+	exposant, err := strconv.Atoi(exposantStr)
+	if err != nil {
+		return "", fmt.Errorf("erreur formatage nombre: %w", err)
+	}
+
+	k := len(chiffres)
+	n := exposant + 1
+
+	var resultat string
+	switch {
+	case k <= n && n <= 21:
+		resultat = chiffres + strings.Repeat("0", n-k)
+	case 0 < n && n <= 21:
+		resultat = chiffres[:n] + "." + chiffres[n:]
+	case -6 < n && n <= 0:
+		resultat = "0." + strings.Repeat("0", -n) + chiffres
+	default:
+		signeExposant := "+"
+		if n-1 < 0 {
+			signeExposant = "-"
+		}
+		exposantAbs := n - 1
+		if exposantAbs < 0 {
+			exposantAbs = -exposantAbs
+		}
+		if k == 1 {
+			resultat = chiffres + "e" + signeExposant + strconv.Itoa(exposantAbs)
+		} else {
+			resultat = chiffres[:1] + "." + chiffres[1:] + "e" + signeExposant + strconv.Itoa(exposantAbs)
+		}
+	}
+
+	return signe + resultat, nil
+}
+
+// comparerUTF16 compare deux chaînes selon leurs unités de code UTF-16
+func comparerUTF16(a, b string) int {
+	ua := utf16.Encode([]rune(a))
+	ub := utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			if ua[i] < ub[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	return len(ua) - len(ub)
+}
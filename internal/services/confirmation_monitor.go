@@ -0,0 +1,129 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// ConfirmationMonitor re-vérifie périodiquement les événements en PENDING_CONFIRMATION:
+// promotion en OK une fois la profondeur atteinte, rétrogradation en NOT_FOUND si le bloc
+// d'inclusion a été remplacé par une réorganisation. Une transaction encore en attente
+// d'inclusion est vérifiée à nouveau jusqu'à son entrée dans un bloc.
+type ConfirmationMonitor struct {
+	repository               HistorialRepository
+	ledgerVerifier           LedgerVerifier
+	confirmacionesRequeridas uint64
+	intervalo                time.Duration
+}
+
+// NewConfirmationMonitor crée une nouvelle instance de ConfirmationMonitor
+func NewConfirmationMonitor(repository HistorialRepository, ledgerVerifier LedgerVerifier, confirmacionesRequeridas uint64, intervalo time.Duration) *ConfirmationMonitor {
+	return &ConfirmationMonitor{
+		repository:               repository,
+		ledgerVerifier:           ledgerVerifier,
+		confirmacionesRequeridas: confirmacionesRequeridas,
+		intervalo:                intervalo,
+	}
+}
+
+// Demarrer lance la boucle de re-vérification jusqu'à l'annulation du contexte
+func (cm *ConfirmationMonitor) Demarrer(ctx context.Context) {
+	ticker := time.NewTicker(cm.intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := cm.RevisarPendientes(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("⚠️ Erreur re-vérification des confirmations: %v", err)
+			}
+		}
+	}
+}
+
+// RevisarPendientes effectue une passe de re-vérification et retourne le nombre d'événements mis à jour
+func (cm *ConfirmationMonitor) RevisarPendientes(ctx context.Context) (int, error) {
+	eventos, err := cm.repository.ListarEventosPorResultado(ctx, models.VerificacionPendienteConfirmacion)
+	if err != nil {
+		return 0, fmt.Errorf("erreur récupération événements en attente: %w", err)
+	}
+	if len(eventos) == 0 {
+		return 0, nil
+	}
+
+	bloqueActual, err := cm.ledgerVerifier.ObtenerBloqueActual(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	actualizados := 0
+	for i := range eventos {
+		evento := &eventos[i]
+
+		fecha := time.Now().UTC()
+		cambio, err := cm.revisarEvento(ctx, evento, bloqueActual)
+		intento := nouvelIntentoVerificacion(evento, fecha, cm.ledgerVerifier.Nombre(), models.OrigenConfirmacion, err)
+		if errJournal := cm.repository.GuardarIntentosVerificacion(ctx, []models.IntentoVerificacion{intento}); errJournal != nil {
+			log.Printf("⚠️ Erreur journal des vérifications pour %s: %v", evento.IDEvento, errJournal)
+		}
+		if err != nil {
+			log.Printf("⚠️ Erreur re-vérification événement %s: %v", evento.IDEvento, err)
+		}
+		if !cambio {
+			continue
+		}
+
+		if err := cm.repository.ActualizarResultadoVerificacion(ctx, evento); err != nil {
+			if errors.Is(err, ErrConflictoVersion) {
+				log.Printf("🔁 Événement %s modifié entre-temps, revu au prochain passage", evento.IDEvento)
+				continue
+			}
+			log.Printf("⚠️ Erreur mise à jour événement %s: %v", evento.IDEvento, err)
+			continue
+		}
+		actualizados++
+		log.Printf("🔁 Événement %s: %s", evento.IDEvento, evento.ResultadoVerificacion)
+	}
+
+	return actualizados, nil
+}
+
+// revisarEvento compare le bloc d'inclusion enregistré avec la chaîne canonique actuelle. Retourne
+// vrai si l'événement est à enregistrer, même accompagné de l'erreur d'un nouveau verdict.
+func (cm *ConfirmationMonitor) revisarEvento(ctx context.Context, evento *models.EventoVerificado, bloqueActual uint64) (bool, error) {
+	// Transaction pas encore incluse: seule une nouvelle vérification peut trouver son bloc
+	if evento.BloqueHash == "" {
+		err := cm.ledgerVerifier.VerificarIntegridad(ctx, evento)
+		if errors.Is(err, ErrRegistroIndisponible) {
+			return false, err
+		}
+		return evento.BloqueHash != "" || evento.ResultadoVerificacion != models.VerificacionPendienteConfirmacion, err
+	}
+
+	hashCanonique, err := cm.ledgerVerifier.ObtenerHashBloque(ctx, evento.BloqueNumero)
+	if err != nil {
+		return false, err
+	}
+
+	if models.NormalizarHash(hashCanonique) != models.NormalizarHash(evento.BloqueHash) {
+		evento.ResultadoVerificacion = models.VerificacionNotFound
+		evento.Observaciones = fmt.Sprintf("Réorganisation détectée au bloc %d: hash %s remplacé par %s", evento.BloqueNumero, evento.BloqueHash, hashCanonique)
+		return true, nil
+	}
+
+	confirmaciones := calcularConfirmaciones(bloqueActual, evento.BloqueNumero)
+	if confirmaciones < cm.confirmacionesRequeridas {
+		return false, nil
+	}
+
+	evento.ResultadoVerificacion = models.VerificacionOK
+	evento.Observaciones = fmt.Sprintf("Hash ancré confirmé dans le bloc %d (%d confirmations)", evento.BloqueNumero, confirmaciones)
+	return true, nil
+}
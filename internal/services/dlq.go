@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ErrDLQDesactivada indique qu'aucune file de lettres mortes n'est configurée sur le bus
+var ErrDLQDesactivada = errors.New("file de lettres mortes non configurée")
+
+// MaxRejeuDLQParDefaut borne le nombre de messages rejoués par appel quand aucune limite n'est donnée
+const MaxRejeuDLQParDefaut = 100
+
+// Headers ajoutés aux messages envoyés en lettres mortes. Les headers d'origine sont conservés.
+const (
+	headerDLQTopic     = "dlq-topic"
+	headerDLQParticion = "dlq-partition"
+	headerDLQOffset    = "dlq-offset"
+	headerDLQError     = "dlq-error"
+	headerDLQIntentos  = "dlq-intentos"
+	headerDLQMotivo    = "dlq-motivo"
+	headerDLQFecha     = "dlq-fecha"
+	prefixeHeadersDLQ  = "dlq-"
+)
+
+// EnteteMensaje est un header d'un message du bus
+type EnteteMensaje struct {
+	Cle   string `json:"key"`
+	Valor string `json:"value"`
+}
+
+// MensajeDLQ est un message en échec conservé en lettres mortes: octets et headers d'origine,
+// position dans le topic source et cause du dernier échec
+type MensajeDLQ struct {
+	Cle        []byte          `json:"key,omitempty"`
+	Valor      []byte          `json:"value"`
+	Headers    []EnteteMensaje `json:"headers,omitempty"`
+	Topic      string          `json:"topic"`
+	Particion  int             `json:"partition"`
+	Offset     int64           `json:"offset"`
+	Error      string          `json:"error"`
+	Intentos   int             `json:"intentos"` // Tentatives en échec, reprises et rejeux compris
+	Motivo     string          `json:"motivo"`   // MotivoMensajeInvalido, MotivoReintentosAgotados, ...
+	FechaFallo time.Time       `json:"fechaFallo"`
+}
+
+// ResumenRejeuDLQ compte les messages d'un rejeu des lettres mortes
+type ResumenRejeuDLQ struct {
+	Leidos   int `json:"leidos"`
+	Traites  int `json:"traites"`
+	Renvoyes int `json:"renvoyes"` // À nouveau en échec, republiés en lettres mortes
+}
+
+// nouveauMensajeDLQ décrit le premier échec d'un message consommé, à partir de ses champs bruts
+// (le bus en mémoire n'a ni clé ni headers)
+func nouveauMensajeDLQ(topic string, particion int, offset int64, cle, valor []byte, entetes []EnteteMensaje, intentos int, motivo string, err error) MensajeDLQ {
+	return MensajeDLQ{
+		Cle:        cle,
+		Valor:      valor,
+		Headers:    entetes,
+		Topic:      topic,
+		Particion:  particion,
+		Offset:     offset,
+		Error:      err.Error(),
+		Intentos:   intentos,
+		Motivo:     motivo,
+		FechaFallo: time.Now().UTC(),
+	}
+}
+
+// mensajeDLQDepuisEchecKafka décrit le premier échec d'un message lu sur le topic source
+func mensajeDLQDepuisEchecKafka(msg kafka.Message, intentos int, motivo string, err error) MensajeDLQ {
+	return nouveauMensajeDLQ(msg.Topic, msg.Partition, msg.Offset, msg.Key, msg.Value, entetesDepuisKafka(msg.Headers), intentos, motivo, err)
+}
+
+// nouvelEchec met à jour le message après un rejeu en échec
+func (m MensajeDLQ) nouvelEchec(intentos int, motivo string, err error) MensajeDLQ {
+	m.Error = err.Error()
+	m.Intentos += intentos
+	m.Motivo = motivo
+	m.FechaFallo = time.Now().UTC()
+	return m
+}
+
+// messageKafka encode le message pour le topic de lettres mortes: la valeur reste intacte pour
+// pouvoir être rejouée, la description de l'échec voyage dans les headers dlq-*
+func (m MensajeDLQ) messageKafka() kafka.Message {
+	headers := make([]kafka.Header, 0, len(m.Headers)+7)
+	for _, entete := range m.Headers {
+		headers = append(headers, kafka.Header{Key: entete.Cle, Value: []byte(entete.Valor)})
+	}
+	headers = append(headers,
+		kafka.Header{Key: headerDLQTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: headerDLQParticion, Value: []byte(strconv.Itoa(m.Particion))},
+		kafka.Header{Key: headerDLQOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: headerDLQError, Value: []byte(m.Error)},
+		kafka.Header{Key: headerDLQIntentos, Value: []byte(strconv.Itoa(m.Intentos))},
+		kafka.Header{Key: headerDLQMotivo, Value: []byte(m.Motivo)},
+		kafka.Header{Key: headerDLQFecha, Value: []byte(m.FechaFallo.Format(time.RFC3339Nano))},
+	)
+
+	return kafka.Message{
+		Key:     m.Cle,
+		Value:   m.Valor,
+		Headers: headers,
+	}
+}
+
+// mensajeDLQDepuisKafka décode un message lu sur le topic de lettres mortes. Un message déposé
+// sans headers dlq-* est décrit par sa propre position.
+func mensajeDLQDepuisKafka(msg kafka.Message) MensajeDLQ {
+	m := MensajeDLQ{
+		Cle:        msg.Key,
+		Valor:      msg.Value,
+		Topic:      msg.Topic,
+		Particion:  msg.Partition,
+		Offset:     msg.Offset,
+		FechaFallo: msg.Time,
+	}
+
+	for _, header := range msg.Headers {
+		valor := string(header.Value)
+		switch header.Key {
+		case headerDLQTopic:
+			m.Topic = valor
+		case headerDLQParticion:
+			if particion, err := strconv.Atoi(valor); err == nil {
+				m.Particion = particion
+			}
+		case headerDLQOffset:
+			if offset, err := strconv.ParseInt(valor, 10, 64); err == nil {
+				m.Offset = offset
+			}
+		case headerDLQError:
+			m.Error = valor
+		case headerDLQIntentos:
+			if intentos, err := strconv.Atoi(valor); err == nil {
+				m.Intentos = intentos
+			}
+		case headerDLQMotivo:
+			m.Motivo = valor
+		case headerDLQFecha:
+			if fecha, err := time.Parse(time.RFC3339Nano, valor); err == nil {
+				m.FechaFallo = fecha
+			}
+		default:
+			m.Headers = append(m.Headers, EnteteMensaje{Cle: header.Key, Valor: valor})
+		}
+	}
+
+	return m
+}
+
+// entetesDepuisKafka copie les headers d'origine, sans ceux d'un passage précédent en lettres mortes
+func entetesDepuisKafka(headers []kafka.Header) []EnteteMensaje {
+	var entetes []EnteteMensaje
+	for _, header := range headers {
+		if strings.HasPrefix(header.Key, prefixeHeadersDLQ) {
+			continue
+		}
+		entetes = append(entetes, EnteteMensaje{Cle: header.Key, Valor: string(header.Value)})
+	}
+	return entetes
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// Clés de tri de la table des acteurs: le profil et chaque clé partagent la partition idActor
+const (
+	skPerfilActor  = "PERFIL"
+	skPrefijoClave = "CLAVE#"
+)
+
+// DynamoDBActorRegistry stocke les acteurs et leurs clés dans une table DynamoDB
+// (clé de partition idActor, clé de tri sk)
+type DynamoDBActorRegistry struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewDynamoDBActorRegistry crée une nouvelle instance de DynamoDBActorRegistry
+func NewDynamoDBActorRegistry(client *dynamodb.Client, tableName string) *DynamoDBActorRegistry {
+	return &DynamoDBActorRegistry{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// GuardarActor crée ou met à jour le profil d'un acteur
+func (dar *DynamoDBActorRegistry) GuardarActor(ctx context.Context, actor *models.Actor) error {
+	item, err := attributevalue.MarshalMap(actor)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling acteur: %w", err)
+	}
+	item["sk"] = &types.AttributeValueMemberS{Value: skPerfilActor}
+
+	_, err = dar.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(dar.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("erreur sauvegarde acteur: %w", err)
+	}
+
+	log.Printf("✅ Acteur sauvegardé: %s", actor.IDActor)
+	return nil
+}
+
+// ObtenerActor retourne le profil d'un acteur
+func (dar *DynamoDBActorRegistry) ObtenerActor(ctx context.Context, idActor string) (*models.Actor, error) {
+	result, err := dar.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(dar.tableName),
+		Key: map[string]types.AttributeValue{
+			"idActor": &types.AttributeValueMemberS{Value: idActor},
+			"sk":      &types.AttributeValueMemberS{Value: skPerfilActor},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération acteur: %w", err)
+	}
+
+	if result.Item == nil {
+		return nil, nil // Non trouvé
+	}
+
+	var actor models.Actor
+	if err := attributevalue.UnmarshalMap(result.Item, &actor); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling acteur: %w", err)
+	}
+
+	return &actor, nil
+}
+
+// ListarActores retourne tous les profils d'acteurs
+func (dar *DynamoDBActorRegistry) ListarActores(ctx context.Context) ([]models.Actor, error) {
+	var actores []models.Actor
+	var startKey map[string]types.AttributeValue
+
+	for {
+		result, err := dar.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(dar.tableName),
+			FilterExpression: aws.String("sk = :sk"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":sk": &types.AttributeValueMemberS{Value: skPerfilActor},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("erreur scan acteurs: %w", err)
+		}
+
+		var page []models.Actor
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("erreur unmarshalling acteurs: %w", err)
+		}
+		actores = append(actores, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = result.LastEvaluatedKey
+	}
+
+	return actores, nil
+}
+
+// GuardarClave crée ou met à jour une clé d'acteur
+func (dar *DynamoDBActorRegistry) GuardarClave(ctx context.Context, clave *models.ClaveActor) error {
+	item, err := attributevalue.MarshalMap(clave)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling clé acteur: %w", err)
+	}
+	item["sk"] = &types.AttributeValueMemberS{Value: skPrefijoClave + clave.IDClave}
+
+	_, err = dar.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(dar.tableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("erreur sauvegarde clé acteur: %w", err)
+	}
+
+	log.Printf("✅ Clé sauvegardée: %s/%s", clave.IDActor, clave.IDClave)
+	return nil
+}
+
+// ObtenerClavesActor retourne toutes les clés d'un acteur
+func (dar *DynamoDBActorRegistry) ObtenerClavesActor(ctx context.Context, idActor string) ([]models.ClaveActor, error) {
+	var claves []models.ClaveActor
+	var startKey map[string]types.AttributeValue
+
+	for {
+		result, err := dar.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(dar.tableName),
+			KeyConditionExpression: aws.String("idActor = :idActor AND begins_with(sk, :prefijo)"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":idActor": &types.AttributeValueMemberS{Value: idActor},
+				":prefijo": &types.AttributeValueMemberS{Value: skPrefijoClave},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("erreur récupération clés acteur: %w", err)
+		}
+
+		var page []models.ClaveActor
+		if err := attributevalue.UnmarshalListOfMaps(result.Items, &page); err != nil {
+			return nil, fmt.Errorf("erreur unmarshalling clés acteur: %w", err)
+		}
+		claves = append(claves, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		startKey = result.LastEvaluatedKey
+	}
+
+	ordonnerClaves(claves)
+	return claves, nil
+}
@@ -0,0 +1,234 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// Limites des opérations par lot DynamoDB
+const (
+	tamanoLoteLectura   = 100 // BatchGetItem
+	tamanoLoteEscritura = 25  // BatchWriteItem, TransactWriteItems
+
+	// Les éléments non traités (throttling) sont renvoyés avec un backoff exponentiel
+	maxReintentosLote = 8
+	esperaInicialLote = 50 * time.Millisecond
+	esperaMaximaLote  = 2 * time.Second
+)
+
+// ObtenerEventosLote lit des événements vérifiés par BatchGetItem (100 clés par requête)
+func (ddb *DynamoDBService) ObtenerEventosLote(ctx context.Context, claves []ClaveEvento) ([]models.EventoVerificado, error) {
+	claves = dedoublonnerClaves(claves)
+	var eventos []models.EventoVerificado
+
+	for debut := 0; debut < len(claves); debut += tamanoLoteLectura {
+		fin := min(debut+tamanoLoteLectura, len(claves))
+
+		keys := make([]map[string]types.AttributeValue, 0, fin-debut)
+		for _, clave := range claves[debut:fin] {
+			keys = append(keys, map[string]types.AttributeValue{
+				"idProducto": &types.AttributeValueMemberS{Value: clave.IDProducto},
+				"idEvento":   &types.AttributeValueMemberS{Value: clave.IDEvento},
+			})
+		}
+
+		items, err := ddb.lireLot(ctx, map[string]types.KeysAndAttributes{
+			ddb.eventoTableName: {Keys: keys},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		page, err := eventosDepuisItems(items[ddb.eventoTableName])
+		if err != nil {
+			return nil, err
+		}
+		eventos = append(eventos, page...)
+	}
+
+	return eventos, nil
+}
+
+// GuardarEventosLote crée des événements vérifiés par TransactWriteItems (25 éléments par
+// transaction), chacun sous la condition attribute_not_exists(idEvento): un événement inséré ou
+// vérifié entre-temps par un autre processus n'est jamais écrasé. Une transaction est annulée en
+// entier si une condition échoue: les événements déjà présents sont écartés et retournés, le reste
+// de la transaction est renvoyé.
+// BatchWriteItem n'accepte pas de ConditionExpression: avec la seule lecture préalable, un événement
+// écrit entre la lecture et l'écriture serait écrasé. La transaction coûte en revanche deux WCU par
+// élément au lieu d'un, et chaque conflit relance le reste de ses 25 éléments; la lecture préalable
+// (ObtenerEventosLote) garde ces conflits rares.
+func (ddb *DynamoDBService) GuardarEventosLote(ctx context.Context, eventos []models.EventoVerificado) ([]ClaveEvento, error) {
+	eventos = dedoublonnerEventos(eventos)
+	var existentes []ClaveEvento
+
+	for debut := 0; debut < len(eventos); debut += tamanoLoteEscritura {
+		fin := min(debut+tamanoLoteEscritura, len(eventos))
+
+		elements := make([]types.TransactWriteItem, fin-debut)
+		indices := make([]int, 0, fin-debut)
+		for i := debut; i < fin; i++ {
+			if eventos[i].Version == 0 {
+				eventos[i].Version = 1
+			}
+			item, err := attributevalue.MarshalMap(eventos[i])
+			if err != nil {
+				return existentes, fmt.Errorf("erreur marshalling événement %s: %w", eventos[i].IDEvento, err)
+			}
+			elements[i-debut] = types.TransactWriteItem{Put: &types.Put{
+				TableName:           aws.String(ddb.eventoTableName),
+				Item:                item,
+				ConditionExpression: aws.String("attribute_not_exists(idEvento)"),
+			}}
+			indices = append(indices, i)
+		}
+
+		for tentative := 0; len(indices) > 0; tentative++ {
+			if tentative > 0 {
+				if tentative > maxReintentosLote {
+					return existentes, fmt.Errorf("erreur écriture par lot: transaction annulée après %d tentatives", maxReintentosLote)
+				}
+				if err := attendreLot(ctx, tentative); err != nil {
+					return existentes, err
+				}
+			}
+
+			transaction := make([]types.TransactWriteItem, 0, len(indices))
+			for _, i := range indices {
+				transaction = append(transaction, elements[i-debut])
+			}
+
+			_, err := ddb.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: transaction})
+			if err == nil {
+				break
+			}
+
+			var annulee *types.TransactionCanceledException
+			if !errors.As(err, &annulee) {
+				return existentes, fmt.Errorf("erreur écriture par lot: %w", err)
+			}
+
+			// Écarter les événements déjà présents; les autres motifs (conflit avec une autre
+			// transaction, throttling) sont relancés après un backoff
+			restants := indices[:0]
+			ecartes := 0
+			for position, i := range indices {
+				if position < len(annulee.CancellationReasons) && aws.ToString(annulee.CancellationReasons[position].Code) == "ConditionalCheckFailed" {
+					existentes = append(existentes, ClaveEvento{IDProducto: eventos[i].IDProducto, IDEvento: eventos[i].IDEvento})
+					ecartes++
+					continue
+				}
+				restants = append(restants, i)
+			}
+			indices = restants
+			if ecartes > 0 {
+				tentative = -1 // Relance immédiate sans les événements déjà présents
+			}
+		}
+	}
+
+	return existentes, nil
+}
+
+// lireLot exécute un BatchGetItem et relance les clés non traitées
+func (ddb *DynamoDBService) lireLot(ctx context.Context, demandes map[string]types.KeysAndAttributes) (map[string][]map[string]types.AttributeValue, error) {
+	resultat := make(map[string][]map[string]types.AttributeValue)
+
+	for tentative := 0; len(demandes) > 0; tentative++ {
+		if tentative > 0 {
+			if tentative > maxReintentosLote {
+				return nil, fmt.Errorf("erreur lecture par lot: clés non traitées après %d tentatives", maxReintentosLote)
+			}
+			if err := attendreLot(ctx, tentative); err != nil {
+				return nil, err
+			}
+		}
+
+		sortie, err := ddb.client.BatchGetItem(ctx, &dynamodb.BatchGetItemInput{RequestItems: demandes})
+		if err != nil {
+			return nil, fmt.Errorf("erreur lecture par lot: %w", err)
+		}
+		for table, items := range sortie.Responses {
+			resultat[table] = append(resultat[table], items...)
+		}
+		demandes = sortie.UnprocessedKeys
+	}
+
+	return resultat, nil
+}
+
+// ecrireLot exécute un BatchWriteItem et relance les éléments non traités
+func (ddb *DynamoDBService) ecrireLot(ctx context.Context, demandes map[string][]types.WriteRequest) error {
+	for tentative := 0; len(demandes) > 0; tentative++ {
+		if tentative > 0 {
+			if tentative > maxReintentosLote {
+				return fmt.Errorf("erreur écriture par lot: éléments non traités après %d tentatives", maxReintentosLote)
+			}
+			if err := attendreLot(ctx, tentative); err != nil {
+				return err
+			}
+		}
+
+		sortie, err := ddb.client.BatchWriteItem(ctx, &dynamodb.BatchWriteItemInput{RequestItems: demandes})
+		if err != nil {
+			return fmt.Errorf("erreur écriture par lot: %w", err)
+		}
+		demandes = sortie.UnprocessedItems
+	}
+
+	return nil
+}
+
+// attendreLot applique le backoff exponentiel avant une nouvelle tentative
+func attendreLot(ctx context.Context, tentative int) error {
+	attente := esperaInicialLote << (tentative - 1)
+	if attente > esperaMaximaLote {
+		attente = esperaMaximaLote
+	}
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(attente):
+		return nil
+	}
+}
+
+// dedoublonnerClaves retire les clés répétées (refusées par BatchGetItem)
+func dedoublonnerClaves(claves []ClaveEvento) []ClaveEvento {
+	vues := make(map[ClaveEvento]bool, len(claves))
+	uniques := make([]ClaveEvento, 0, len(claves))
+	for _, clave := range claves {
+		if !vues[clave] {
+			vues[clave] = true
+			uniques = append(uniques, clave)
+		}
+	}
+	return uniques
+}
+
+// dedoublonnerEventos garde la dernière version de chaque événement (TransactWriteItems refuse deux
+// opérations sur le même élément)
+func dedoublonnerEventos(eventos []models.EventoVerificado) []models.EventoVerificado {
+	positions := make(map[ClaveEvento]int, len(eventos))
+	uniques := make([]models.EventoVerificado, 0, len(eventos))
+	for _, evento := range eventos {
+		clave := ClaveEvento{IDProducto: evento.IDProducto, IDEvento: evento.IDEvento}
+		if i, ok := positions[clave]; ok {
+			uniques[i] = evento
+			continue
+		}
+		positions[clave] = len(uniques)
+		uniques = append(uniques, evento)
+	}
+	return uniques
+}
@@ -2,13 +2,19 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
 	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/aws/smithy-go"
 
 	"github.com/edinfamous/historial-blockchain/internal/models"
 )
@@ -19,8 +25,27 @@ type DynamoDBService struct {
 	historialTableName         string
 	eventoTableName           string
 	blockchainEventsTableName string
+
+	// Index secondaire global idProducto de la table blockcahin_medysupyly
+	indiceProducto             string
+	indiceProductoAbsentDepuis atomic.Int64
+
+	// Table des points de reprise de la synchronisation par produit (vide: synchronisation complète)
+	puntosTableName string
+
+	// Journal des tentatives de vérification (vide: non conservé)
+	intentosTableName string
+
+	// Table des tâches asynchrones (clé taskId, expiration TTL sur expiraEn)
+	tareasTableName string
 }
 
+// IndiceProductoPorDefecto est le nom par défaut de l'index idProducto de la table blockcahin_medysupyly
+const IndiceProductoPorDefecto = "idProducto-index"
+
+// delaiReessaiIndiceProducto espace les nouvelles tentatives sur un index absent
+const delaiReessaiIndiceProducto = 5 * time.Minute
+
 // NewDynamoDBService crée une nouvelle instance de DynamoDBService
 func NewDynamoDBService(client *dynamodb.Client, historialTableName, eventoTableName, blockchainEventsTableName string) *DynamoDBService {
 	return &DynamoDBService{
@@ -28,13 +53,16 @@ func NewDynamoDBService(client *dynamodb.Client, historialTableName, eventoTable
 		historialTableName:         historialTableName,
 		eventoTableName:           eventoTableName,
 		blockchainEventsTableName: blockchainEventsTableName,
+		indiceProducto:            IndiceProductoPorDefecto,
 	}
 }
 
-// GuardarHistorial sauvegarde l'historial de transparence
+// GuardarHistorial sauvegarde l'historial de transparence sous la clé (idProducto, lote)
 func (ddb *DynamoDBService) GuardarHistorial(ctx context.Context, historial *models.HistorialTransparencia) error {
-	// Convertir vers les attributs DynamoDB
-	item, err := attributevalue.MarshalMap(historial)
+	// Convertir vers les attributs DynamoDB (la clé de tri ne peut pas être vide)
+	stocke := *historial
+	stocke.Lote = claveLoteHistorial(historial.Lote)
+	item, err := attributevalue.MarshalMap(stocke)
 	if err != nil {
 		return fmt.Errorf("erreur marshalling historial: %w", err)
 	}
@@ -49,17 +77,15 @@ func (ddb *DynamoDBService) GuardarHistorial(ctx context.Context, historial *mod
 		return fmt.Errorf("erreur sauvegarde historial: %w", err)
 	}
 
-	log.Printf("✅ Historial sauvegardé: %s - %s", historial.IDProducto, historial.Lote)
+	log.Printf("✅ Historial sauvegardé: %s - %s", historial.IDProducto, stocke.Lote)
 	return nil
 }
 
 // ObtenerHistorial récupère un historial par ID produit et lote
 func (ddb *DynamoDBService) ObtenerHistorial(ctx context.Context, idProducto, lote string) (*models.HistorialTransparencia, error) {
-	// La table utilise seulement idProducto comme clé primaire
-	// Le paramètre lote peut être utilisé pour filtrer après récupération si nécessaire
-
 	key := map[string]types.AttributeValue{
 		"idProducto": &types.AttributeValueMemberS{Value: idProducto},
+		"lote":       &types.AttributeValueMemberS{Value: claveLoteHistorial(lote)},
 	}
 
 	result, err := ddb.client.GetItem(ctx, &dynamodb.GetItemInput{
@@ -80,19 +106,86 @@ func (ddb *DynamoDBService) ObtenerHistorial(ctx context.Context, idProducto, lo
 	if err != nil {
 		return nil, fmt.Errorf("erreur unmarshalling historial: %w", err)
 	}
+	historial.Lote = loteDepuisCleHistorial(historial.Lote)
 
-	// Si un lote spécifique est demandé, vérifier que ça correspond
-	if lote != "" && historial.Lote != lote {
-		return nil, nil // Lote ne correspond pas
+	return &historial, nil
+}
+
+// ListarHistorialesPorProducto liste les historiales de tous les lots d'un produit (Query sur la clé de partition)
+func (ddb *DynamoDBService) ListarHistorialesPorProducto(ctx context.Context, idProducto string) ([]models.HistorialTransparencia, error) {
+	items, err := lireTout(ddb.requeteHistorialesProducto(ctx, idProducto))
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération historiales du produit: %w", err)
 	}
+	return historialesDepuisItems(items)
+}
 
-	return &historial, nil
+// ListarHistorialesPorProductoPagina lit une page des historiales d'un produit
+func (ddb *DynamoDBService) ListarHistorialesPorProductoPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	items, suivant, err := lirePage(consulta, "historiales#"+idProducto, ddb.requeteHistorialesProducto(ctx, idProducto))
+	if err != nil {
+		return nil, "", fmt.Errorf("erreur récupération historiales du produit: %w", err)
+	}
+	historiales, err := historialesDepuisItems(items)
+	return historiales, suivant, err
+}
+
+func (ddb *DynamoDBService) requeteHistorialesProducto(ctx context.Context, idProducto string) requetePage {
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		result, err := ddb.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(ddb.historialTableName),
+			KeyConditionExpression: aws.String("idProducto = :idProducto"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":idProducto": &types.AttributeValueMemberS{Value: idProducto},
+			},
+			ExclusiveStartKey: startKey,
+			Limit:             limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
+}
+
+// historialesDepuisItems décode des historiales et rétablit leur lote métier
+func historialesDepuisItems(items []map[string]types.AttributeValue) ([]models.HistorialTransparencia, error) {
+	historiales := []models.HistorialTransparencia{}
+	if err := attributevalue.UnmarshalListOfMaps(items, &historiales); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling historiales: %w", err)
+	}
+	for i := range historiales {
+		historiales[i].Lote = loteDepuisCleHistorial(historiales[i].Lote)
+	}
+	return historiales, nil
+}
+
+// claveLoteHistorial retourne la valeur de clé de tri stockée pour un lote
+func claveLoteHistorial(lote string) string {
+	if lote == "" {
+		return models.LoteGlobal
+	}
+	return lote
+}
+
+// loteDepuisCleHistorial retourne le lote métier correspondant à une clé de tri stockée
+func loteDepuisCleHistorial(cle string) string {
+	if cle == models.LoteGlobal {
+		return ""
+	}
+	return cle
 }
 
 // GuardarEvento sauvegarde un événement vérifié
 func (ddb *DynamoDBService) GuardarEvento(ctx context.Context, evento *models.EventoVerificado) error {
+	// Un nouvel événement commence à la version 1 (evento.Version reste inchangé pour un doublon)
+	stocke := *evento
+	if stocke.Version == 0 {
+		stocke.Version = 1
+	}
+
 	// Convertir vers les attributs DynamoDB
-	item, err := attributevalue.MarshalMap(evento)
+	item, err := attributevalue.MarshalMap(stocke)
 	if err != nil {
 		return fmt.Errorf("erreur marshalling événement: %w", err)
 	}
@@ -106,37 +199,63 @@ func (ddb *DynamoDBService) GuardarEvento(ctx context.Context, evento *models.Ev
 	
 	if err != nil {
 		// Si l'élément existe déjà, c'est OK (idempotence)
-		if _, ok := err.(*types.ConditionalCheckFailedException); ok {
+		// L'erreur est enveloppée par le SDK (smithy.OperationError): errors.As et non une assertion de type
+		var conditionEchouee *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionEchouee) {
 			log.Printf("⚠️ Événement déjà existant (idempotence): %s", evento.IDEvento)
 			return nil
 		}
 		return fmt.Errorf("erreur sauvegarde événement: %w", err)
 	}
 
+	evento.Version = stocke.Version
 	log.Printf("✅ Événement sauvegardé: %s", evento.IDEvento)
 	return nil
 }
 
 // ObtenerEventos récupère tous les événements pour un produit
 func (ddb *DynamoDBService) ObtenerEventos(ctx context.Context, idProducto string) ([]models.EventoVerificado, error) {
-	result, err := ddb.client.Query(ctx, &dynamodb.QueryInput{
-		TableName:              aws.String(ddb.eventoTableName),
-		KeyConditionExpression: aws.String("idProducto = :idProducto"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":idProducto": &types.AttributeValueMemberS{Value: idProducto},
-		},
-	})
-	
+	items, err := lireTout(ddb.requeteEventos(ctx, idProducto))
 	if err != nil {
 		return nil, fmt.Errorf("erreur récupération événements: %w", err)
 	}
+	return eventosDepuisItems(items)
+}
 
-	var eventos []models.EventoVerificado
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &eventos)
+// ObtenerEventosPagina lit une page des événements d'un produit
+func (ddb *DynamoDBService) ObtenerEventosPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error) {
+	items, suivant, err := lirePage(consulta, "eventos#"+idProducto, ddb.requeteEventos(ctx, idProducto))
 	if err != nil {
-		return nil, fmt.Errorf("erreur unmarshalling événements: %w", err)
+		return nil, "", fmt.Errorf("erreur récupération événements: %w", err)
+	}
+	eventos, err := eventosDepuisItems(items)
+	return eventos, suivant, err
+}
+
+func (ddb *DynamoDBService) requeteEventos(ctx context.Context, idProducto string) requetePage {
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		result, err := ddb.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(ddb.eventoTableName),
+			KeyConditionExpression: aws.String("idProducto = :idProducto"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":idProducto": &types.AttributeValueMemberS{Value: idProducto},
+			},
+			ExclusiveStartKey: startKey,
+			Limit:             limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
 	}
+}
 
+// eventosDepuisItems décode des événements vérifiés
+func eventosDepuisItems(items []map[string]types.AttributeValue) ([]models.EventoVerificado, error) {
+	var eventos []models.EventoVerificado
+	if err := attributevalue.UnmarshalListOfMaps(items, &eventos); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling événements: %w", err)
+	}
 	return eventos, nil
 }
 
@@ -169,122 +288,661 @@ func (ddb *DynamoDBService) ObtenerEvento(ctx context.Context, idProducto, idEve
 	return &evento, nil
 }
 
-// GuardarTaskStatus sauvegarde le statut d'une tâche
-func (ddb *DynamoDBService) GuardarTaskStatus(ctx context.Context, taskStatus *models.TaskStatus) error {
-	// On peut utiliser la même table ou une table dédiée pour les tâches
-	// Ici on utilise la table historial avec un préfixe spécial
-	taskStatus.TaskID = "TASK#" + taskStatus.TaskID
-	
-	item, err := attributevalue.MarshalMap(taskStatus)
+// ActualizarResultadoVerificacion met à jour le résultat de vérification d'un événement existant si sa
+// version n'a pas changé depuis la lecture, puis incrémente evento.Version
+func (ddb *DynamoDBService) ActualizarResultadoVerificacion(ctx context.Context, evento *models.EventoVerificado) error {
+	_, err := ddb.client.UpdateItem(ctx, ddb.miseAJourVerification(evento))
 	if err != nil {
-		return fmt.Errorf("erreur marshalling task status: %w", err)
+		var conditionEchouee *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionEchouee) {
+			if conditionEchouee.Item == nil {
+				return fmt.Errorf("événement non trouvé: %s", evento.IDEvento)
+			}
+			return fmt.Errorf("événement %s: %w", evento.IDEvento, ErrConflictoVersion)
+		}
+		return fmt.Errorf("erreur mise à jour vérification événement: %w", err)
 	}
 
-	_, err = ddb.client.PutItem(ctx, &dynamodb.PutItemInput{
-		TableName: aws.String(ddb.historialTableName),
-		Item:      item,
-	})
-	
-	if err != nil {
-		return fmt.Errorf("erreur sauvegarde task status: %w", err)
+	evento.Version++
+	return nil
+}
+
+// ActualizarResultadosVerificacionLote met à jour plusieurs résultats par TransactWriteItems (25 éléments
+// par transaction). Une transaction est annulée en entier si une condition échoue: les événements en
+// conflit sont écartés et le reste de la transaction est renvoyé. La version des événements mis à jour
+// est incrémentée dans eventos.
+func (ddb *DynamoDBService) ActualizarResultadosVerificacionLote(ctx context.Context, eventos []models.EventoVerificado) ([]ClaveEvento, error) {
+	var conflictos []ClaveEvento
+
+	for debut := 0; debut < len(eventos); debut += tamanoLoteEscritura {
+		fin := min(debut+tamanoLoteEscritura, len(eventos))
+
+		indices := make([]int, 0, fin-debut)
+		for i := debut; i < fin; i++ {
+			indices = append(indices, i)
+		}
+
+		for tentative := 0; len(indices) > 0; tentative++ {
+			if tentative > 0 {
+				if tentative > maxReintentosLote {
+					return conflictos, fmt.Errorf("erreur mise à jour vérifications par lot: transaction annulée après %d tentatives", maxReintentosLote)
+				}
+				if err := attendreLot(ctx, tentative); err != nil {
+					return conflictos, err
+				}
+			}
+
+			elements := make([]types.TransactWriteItem, 0, len(indices))
+			for _, i := range indices {
+				miseAJour := ddb.miseAJourVerification(&eventos[i])
+				elements = append(elements, types.TransactWriteItem{Update: &types.Update{
+					TableName:                 miseAJour.TableName,
+					Key:                       miseAJour.Key,
+					UpdateExpression:          miseAJour.UpdateExpression,
+					ConditionExpression:       miseAJour.ConditionExpression,
+					ExpressionAttributeValues: miseAJour.ExpressionAttributeValues,
+				}})
+			}
+
+			_, err := ddb.client.TransactWriteItems(ctx, &dynamodb.TransactWriteItemsInput{TransactItems: elements})
+			if err == nil {
+				for _, i := range indices {
+					eventos[i].Version++
+				}
+				break
+			}
+
+			var annulee *types.TransactionCanceledException
+			if !errors.As(err, &annulee) {
+				return conflictos, fmt.Errorf("erreur mise à jour vérifications par lot: %w", err)
+			}
+
+			// Écarter les événements dont la condition a échoué; les autres motifs (conflit avec une
+			// autre transaction, throttling) sont relancés après un backoff
+			restants := indices[:0]
+			ecartes := 0
+			for position, i := range indices {
+				if position < len(annulee.CancellationReasons) && aws.ToString(annulee.CancellationReasons[position].Code) == "ConditionalCheckFailed" {
+					conflictos = append(conflictos, ClaveEvento{IDProducto: eventos[i].IDProducto, IDEvento: eventos[i].IDEvento})
+					ecartes++
+					continue
+				}
+				restants = append(restants, i)
+			}
+			indices = restants
+			if ecartes > 0 {
+				tentative = -1 // Relance immédiate sans les événements en conflit
+			}
+		}
 	}
 
-	return nil
+	return conflictos, nil
 }
 
-// ObtenerTaskStatus récupère le statut d'une tâche
-func (ddb *DynamoDBService) ObtenerTaskStatus(ctx context.Context, taskID string) (*models.TaskStatus, error) {
-	key := map[string]types.AttributeValue{
-		"idProducto": &types.AttributeValueMemberS{Value: "TASK#" + taskID},
+// miseAJourVerification construit la mise à jour conditionnelle du résultat de vérification
+func (ddb *DynamoDBService) miseAJourVerification(evento *models.EventoVerificado) *dynamodb.UpdateItemInput {
+	valeurs := map[string]types.AttributeValue{
+		":resultado":     &types.AttributeValueMemberS{Value: evento.ResultadoVerificacion},
+		":observaciones": &types.AttributeValueMemberS{Value: evento.Observaciones},
+		":bloqueNumero":  &types.AttributeValueMemberN{Value: strconv.FormatUint(evento.BloqueNumero, 10)},
+		":bloqueHash":    &types.AttributeValueMemberS{Value: evento.BloqueHash},
+		":nouvelle":      &types.AttributeValueMemberN{Value: strconv.FormatInt(evento.Version+1, 10)},
+		":attendue":      &types.AttributeValueMemberN{Value: strconv.FormatInt(evento.Version, 10)},
 	}
 
-	result, err := ddb.client.GetItem(ctx, &dynamodb.GetItemInput{
-		TableName: aws.String(ddb.historialTableName),
-		Key:       key,
+	// Les événements enregistrés avant le versionnement n'ont pas d'attribut version (lus à 0)
+	condition := "attribute_exists(idEvento) AND version = :attendue"
+	if evento.Version == 0 {
+		condition = "attribute_exists(idEvento) AND (attribute_not_exists(version) OR version = :attendue)"
+	}
+
+	return &dynamodb.UpdateItemInput{
+		TableName: aws.String(ddb.eventoTableName),
+		Key: map[string]types.AttributeValue{
+			"idProducto": &types.AttributeValueMemberS{Value: evento.IDProducto},
+			"idEvento":   &types.AttributeValueMemberS{Value: evento.IDEvento},
+		},
+		UpdateExpression:                    aws.String("SET resultadoVerificacion = :resultado, observaciones = :observaciones, bloqueNumero = :bloqueNumero, bloqueHash = :bloqueHash, version = :nouvelle"),
+		ConditionExpression:                 aws.String(condition),
+		ExpressionAttributeValues:           valeurs,
+		ReturnValuesOnConditionCheckFailure: types.ReturnValuesOnConditionCheckFailureAllOld,
+	}
+}
+
+// GuardarPruebaMerkle enregistre la transaction de la racine, la preuve d'inclusion et le résultat.
+// L'ancrage n'est pas conditionné à la version (le lot Merkle est seul à écrire la preuve) mais
+// l'incrémente, pour faire échouer les mises à jour concurrentes lancées avant l'ancrage.
+func (ddb *DynamoDBService) GuardarPruebaMerkle(ctx context.Context, evento *models.EventoVerificado) error {
+	prueba, err := attributevalue.Marshal(evento.PruebaMerkle)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling preuve Merkle: %w", err)
+	}
+
+	result, err := ddb.client.UpdateItem(ctx, &dynamodb.UpdateItemInput{
+		TableName: aws.String(ddb.eventoTableName),
+		Key: map[string]types.AttributeValue{
+			"idProducto": &types.AttributeValueMemberS{Value: evento.IDProducto},
+			"idEvento":   &types.AttributeValueMemberS{Value: evento.IDEvento},
+		},
+		UpdateExpression:    aws.String("SET referenciaBlockchain = :referencia, pruebaMerkle = :prueba, resultadoVerificacion = :resultado, observaciones = :observaciones, bloqueNumero = :bloqueNumero, bloqueHash = :bloqueHash, version = if_not_exists(version, :zero) + :un"),
+		ConditionExpression: aws.String("attribute_exists(idEvento)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":referencia":    &types.AttributeValueMemberS{Value: evento.ReferenciaBlockchain},
+			":prueba":        prueba,
+			":resultado":     &types.AttributeValueMemberS{Value: evento.ResultadoVerificacion},
+			":observaciones": &types.AttributeValueMemberS{Value: evento.Observaciones},
+			":bloqueNumero":  &types.AttributeValueMemberN{Value: strconv.FormatUint(evento.BloqueNumero, 10)},
+			":bloqueHash":    &types.AttributeValueMemberS{Value: evento.BloqueHash},
+			":zero":          &types.AttributeValueMemberN{Value: "0"},
+			":un":            &types.AttributeValueMemberN{Value: "1"},
+		},
+		ReturnValues: types.ReturnValueUpdatedNew,
 	})
-	
+
 	if err != nil {
-		return nil, fmt.Errorf("erreur récupération task status: %w", err)
+		return fmt.Errorf("erreur sauvegarde preuve Merkle: %w", err)
 	}
 
-	if result.Item == nil {
-		return nil, nil // Non trouvé
+	if version, ok := result.Attributes["version"].(*types.AttributeValueMemberN); ok {
+		if valeur, err := strconv.ParseInt(version.Value, 10, 64); err == nil {
+			evento.Version = valeur
+		}
 	}
 
-	var taskStatus models.TaskStatus
-	err = attributevalue.UnmarshalMap(result.Item, &taskStatus)
+	return nil
+}
+
+// ListarEventosPorResultado liste les événements ayant un résultat de vérification donné
+func (ddb *DynamoDBService) ListarEventosPorResultado(ctx context.Context, resultado string) ([]models.EventoVerificado, error) {
+	items, err := lireTout(ddb.requeteEventosPorResultado(ctx, resultado))
 	if err != nil {
-		return nil, fmt.Errorf("erreur unmarshalling task status: %w", err)
+		return nil, fmt.Errorf("erreur scan événements par résultat: %w", err)
 	}
+	return eventosDepuisItems(items)
+}
 
-	// Nettoyer le préfixe
-	taskStatus.TaskID = taskStatus.TaskID[5:] // Enlever "TASK#"
+// ListarEventosPorResultadoPagina lit une page des événements ayant un résultat de vérification donné
+func (ddb *DynamoDBService) ListarEventosPorResultadoPagina(ctx context.Context, resultado string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error) {
+	items, suivant, err := lirePage(consulta, "eventos-resultado#"+resultado, ddb.requeteEventosPorResultado(ctx, resultado))
+	if err != nil {
+		return nil, "", fmt.Errorf("erreur scan événements par résultat: %w", err)
+	}
+	eventos, err := eventosDepuisItems(items)
+	return eventos, suivant, err
+}
 
-	return &taskStatus, nil
+func (ddb *DynamoDBService) requeteEventosPorResultado(ctx context.Context, resultado string) requetePage {
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(ddb.eventoTableName),
+			FilterExpression: aws.String("resultadoVerificacion = :resultado"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":resultado": &types.AttributeValueMemberS{Value: resultado},
+			},
+			ExclusiveStartKey: startKey,
+			Limit:             limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
 }
 
 // ListarHistorialesInconsistentes liste les historiales avec état inconsistant
 func (ddb *DynamoDBService) ListarHistorialesInconsistentes(ctx context.Context) ([]models.HistorialTransparencia, error) {
-	// Utiliser un GSI sur estadoActual si disponible
-	result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName:        aws.String(ddb.historialTableName),
-		FilterExpression: aws.String("estadoActual = :estado"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":estado": &types.AttributeValueMemberS{Value: models.EstadoInconsistente},
-		},
-	})
-	
+	items, err := lireTout(ddb.requeteHistorialesInconsistentes(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("erreur scan historiales inconsistants: %w", err)
 	}
+	return historialesDepuisItems(items)
+}
 
-	var historiales []models.HistorialTransparencia
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &historiales)
+// ListarHistorialesInconsistentesPagina lit une page des historiales avec état inconsistant
+func (ddb *DynamoDBService) ListarHistorialesInconsistentesPagina(ctx context.Context, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	items, suivant, err := lirePage(consulta, "historiales-inconsistentes", ddb.requeteHistorialesInconsistentes(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("erreur unmarshalling historiales: %w", err)
+		return nil, "", fmt.Errorf("erreur scan historiales inconsistants: %w", err)
 	}
+	historiales, err := historialesDepuisItems(items)
+	return historiales, suivant, err
+}
 
-	return historiales, nil
+func (ddb *DynamoDBService) requeteHistorialesInconsistentes(ctx context.Context) requetePage {
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		// Utiliser un GSI sur estadoActual si disponible
+		result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:        aws.String(ddb.historialTableName),
+			FilterExpression: aws.String("estadoActual = :estado"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":estado": &types.AttributeValueMemberS{Value: models.EstadoInconsistente},
+			},
+			ExclusiveStartKey: startKey,
+			Limit:             limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
 }
 
-// ObtenerEventosBlockchainPorProducto récupère les événements de la table blockcahin_medysupyly pour un produit
+// ObtenerEventosBlockchainPorProducto récupère les événements de la table blockcahin_medysupyly pour un produit.
+// La lecture passe par l'index secondaire global sur idProducto; tant que l'index est absent
+// (ou en cours de construction), elle retombe sur un scan filtré de la table.
 func (ddb *DynamoDBService) ObtenerEventosBlockchainPorProducto(ctx context.Context, idProducto string) ([]models.BlockchainEvent, error) {
-	result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
-		TableName:        aws.String(ddb.blockchainEventsTableName),
-		FilterExpression: aws.String("idProducto = :idProducto"),
-		ExpressionAttributeValues: map[string]types.AttributeValue{
-			":idProducto": &types.AttributeValueMemberS{Value: idProducto},
-		},
-	})
-	
+	return ddb.ObtenerEventosBlockchainDesde(ctx, idProducto, nil)
+}
+
+// ObtenerEventosBlockchainDesde récupère les événements d'un produit postérieurs au point de reprise.
+// L'index est trié par fechaEvento: la condition sur (updatedAt, idTransaction) est un filtre, qui
+// réduit les éléments renvoyés mais pas la capacité de lecture consommée.
+func (ddb *DynamoDBService) ObtenerEventosBlockchainDesde(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) ([]models.BlockchainEvent, error) {
+	if ddb.indiceProductoUtilisable() {
+		items, err := lireTout(ddb.requeteEventosBlockchainIndice(ctx, idProducto, desde))
+		if err == nil {
+			return eventosBlockchainDepuisItems(items)
+		}
+		if !ddb.signalerIndiceAbsent(err) {
+			return nil, fmt.Errorf("erreur récupération événements blockchain: %w", err)
+		}
+	}
+
+	items, err := lireTout(ddb.requeteEventosBlockchainScan(ctx, idProducto, desde))
 	if err != nil {
 		return nil, fmt.Errorf("erreur récupération événements blockchain: %w", err)
 	}
+	return eventosBlockchainDepuisItems(items)
+}
 
-	var eventos []models.BlockchainEvent
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &eventos)
+// ObtenerEventosBlockchainPorProductoPagina lit une page des événements blockchain d'un produit.
+// Un parcours commencé sur l'index (ou par scan) se poursuit de la même façon jusqu'au bout.
+func (ddb *DynamoDBService) ObtenerEventosBlockchainPorProductoPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.BlockchainEvent, string, error) {
+	porteeIndice := "blockchain#" + ddb.indiceProducto + "#" + idProducto
+	porteeScan := "blockchain-scan#" + idProducto
+
+	parIndice := ddb.indiceProductoUtilisable()
+	if consulta.Cursor != "" {
+		portee, err := porteeCurseur(consulta.Cursor)
+		if err != nil {
+			return nil, "", err
+		}
+		parIndice = ddb.indiceProducto != "" && portee == porteeIndice
+	}
+
+	if parIndice {
+		items, suivant, err := lirePage(consulta, porteeIndice, ddb.requeteEventosBlockchainIndice(ctx, idProducto, nil))
+		if err == nil {
+			eventos, err := eventosBlockchainDepuisItems(items)
+			return eventos, suivant, err
+		}
+		// Une page suivante d'un parcours par index ne peut pas reprendre sur un scan
+		if consulta.Cursor != "" || !ddb.signalerIndiceAbsent(err) {
+			return nil, "", fmt.Errorf("erreur récupération événements blockchain: %w", err)
+		}
+	}
+
+	items, suivant, err := lirePage(consulta, porteeScan, ddb.requeteEventosBlockchainScan(ctx, idProducto, nil))
 	if err != nil {
-		return nil, fmt.Errorf("erreur unmarshalling événements blockchain: %w", err)
+		return nil, "", fmt.Errorf("erreur récupération événements blockchain: %w", err)
+	}
+	eventos, err := eventosBlockchainDepuisItems(items)
+	return eventos, suivant, err
+}
+
+// requeteEventosBlockchainIndice interroge l'index idProducto (desde non nil: lignes postérieures seulement)
+func (ddb *DynamoDBService) requeteEventosBlockchainIndice(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) requetePage {
+	valeurs := map[string]types.AttributeValue{
+		":idProducto": &types.AttributeValueMemberS{Value: idProducto},
+	}
+	filtre := filtrePosterieur(desde, valeurs)
+
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		input := &dynamodb.QueryInput{
+			TableName:                 aws.String(ddb.blockchainEventsTableName),
+			IndexName:                 aws.String(ddb.indiceProducto),
+			KeyConditionExpression:    aws.String("idProducto = :idProducto"),
+			ExpressionAttributeValues: valeurs,
+			ExclusiveStartKey:         startKey,
+			Limit:                     limiteRequete(limite),
+		}
+		if filtre != "" {
+			input.FilterExpression = aws.String(filtre)
+		}
+		result, err := ddb.client.Query(ctx, input)
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
+}
+
+// requeteEventosBlockchainScan parcourt toute la table en filtrant sur idProducto
+func (ddb *DynamoDBService) requeteEventosBlockchainScan(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) requetePage {
+	valeurs := map[string]types.AttributeValue{
+		":idProducto": &types.AttributeValueMemberS{Value: idProducto},
+	}
+	filtre := "idProducto = :idProducto"
+	if posterieur := filtrePosterieur(desde, valeurs); posterieur != "" {
+		filtre += " AND " + posterieur
 	}
 
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(ddb.blockchainEventsTableName),
+			FilterExpression:          aws.String(filtre),
+			ExpressionAttributeValues: valeurs,
+			ExclusiveStartKey:         startKey,
+			Limit:                     limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
+}
+
+// filtrePosterieur traduit PuntoSincronizacion.Posterior en expression DynamoDB et ajoute ses valeurs
+// (vide si le point de reprise ne filtre rien)
+func filtrePosterieur(desde *models.PuntoSincronizacion, valeurs map[string]types.AttributeValue) string {
+	if desde == nil || desde.UltimoUpdatedAt == "" {
+		return ""
+	}
+	valeurs[":vide"] = &types.AttributeValueMemberS{Value: ""}
+	valeurs[":ultimoUpdatedAt"] = &types.AttributeValueMemberS{Value: desde.UltimoUpdatedAt}
+	valeurs[":ultimoIdTransaction"] = &types.AttributeValueMemberS{Value: desde.UltimoIDTransaction}
+	return "(attribute_not_exists(updatedAt) OR updatedAt = :vide OR updatedAt > :ultimoUpdatedAt" +
+		" OR (updatedAt = :ultimoUpdatedAt AND idTransaction > :ultimoIdTransaction))"
+}
+
+// signalerIndiceAbsent mémorise l'indisponibilité de l'index si l'erreur la signale
+func (ddb *DynamoDBService) signalerIndiceAbsent(err error) bool {
+	if !esErreurIndiceAbsent(err) {
+		return false
+	}
+	log.Printf("⚠️ Index %s indisponible sur %s, repli sur un scan: %v", ddb.indiceProducto, ddb.blockchainEventsTableName, err)
+	ddb.indiceProductoAbsentDepuis.Store(time.Now().UnixNano())
+	return true
+}
+
+// eventosBlockchainDepuisItems décode des événements de la table blockcahin_medysupyly
+func eventosBlockchainDepuisItems(items []map[string]types.AttributeValue) ([]models.BlockchainEvent, error) {
+	var eventos []models.BlockchainEvent
+	if err := attributevalue.UnmarshalListOfMaps(items, &eventos); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling événements blockchain: %w", err)
+	}
 	return eventos, nil
 }
 
-// ObtenerTousEventosBlockchain récupère tous les événements de la table blockcahin_medysupyly
-func (ddb *DynamoDBService) ObtenerTousEventosBlockchain(ctx context.Context) ([]models.BlockchainEvent, error) {
-	result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+// DefinirIndiceProducto configure le nom de l'index secondaire global idProducto de la table
+// blockcahin_medysupyly (vide: toujours scanner la table)
+func (ddb *DynamoDBService) DefinirIndiceProducto(nombre string) {
+	ddb.indiceProducto = nombre
+	ddb.indiceProductoAbsentDepuis.Store(0)
+}
+
+// indiceProductoUtilisable indique si l'index doit être interrogé. Après un échec, l'index
+// n'est réessayé qu'une fois le délai écoulé (le temps qu'il soit créé ou rempli).
+func (ddb *DynamoDBService) indiceProductoUtilisable() bool {
+	if ddb.indiceProducto == "" {
+		return false
+	}
+	absentDepuis := ddb.indiceProductoAbsentDepuis.Load()
+	return absentDepuis == 0 || time.Since(time.Unix(0, absentDepuis)) > delaiReessaiIndiceProducto
+}
+
+// esErreurIndiceAbsent reconnaît le refus d'une requête sur un index inexistant ou en construction
+func esErreurIndiceAbsent(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "ValidationException" {
+		return false
+	}
+	return strings.Contains(strings.ToLower(apiErr.ErrorMessage()), "index")
+}
+
+// AsegurarTablaEventosBlockchain crée la table blockcahin_medysupyly (clé idTransaction) avec son
+// index idProducto si elle n'existe pas, ou ajoute l'index à une table existante qui ne l'a pas.
+func (ddb *DynamoDBService) AsegurarTablaEventosBlockchain(ctx context.Context) error {
+	if ddb.indiceProducto == "" {
+		return fmt.Errorf("aucun index idProducto configuré")
+	}
+
+	index := types.GlobalSecondaryIndex{
+		IndexName: aws.String(ddb.indiceProducto),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("idProducto"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("fechaEvento"), KeyType: types.KeyTypeRange},
+		},
+		Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+	}
+	attributs := []types.AttributeDefinition{
+		{AttributeName: aws.String("idProducto"), AttributeType: types.ScalarAttributeTypeS},
+		{AttributeName: aws.String("fechaEvento"), AttributeType: types.ScalarAttributeTypeS},
+	}
+
+	description, err := ddb.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
 		TableName: aws.String(ddb.blockchainEventsTableName),
 	})
-	
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			return fmt.Errorf("erreur description table %s: %w", ddb.blockchainEventsTableName, err)
+		}
+
+		_, err = ddb.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+			TableName: aws.String(ddb.blockchainEventsTableName),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("idTransaction"), KeyType: types.KeyTypeHash},
+			},
+			AttributeDefinitions: append([]types.AttributeDefinition{
+				{AttributeName: aws.String("idTransaction"), AttributeType: types.ScalarAttributeTypeS},
+			}, attributs...),
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{index},
+			BillingMode:            types.BillingModePayPerRequest,
+		})
+		if err != nil {
+			return fmt.Errorf("erreur création table %s: %w", ddb.blockchainEventsTableName, err)
+		}
+		log.Printf("✅ Table %s créée avec l'index %s", ddb.blockchainEventsTableName, ddb.indiceProducto)
+		return nil
+	}
+
+	for _, existant := range description.Table.GlobalSecondaryIndexes {
+		if aws.ToString(existant.IndexName) == ddb.indiceProducto {
+			if existant.IndexStatus != types.IndexStatusActive {
+				log.Printf("⏳ Index %s en cours de construction (%s)", ddb.indiceProducto, existant.IndexStatus)
+			}
+			return nil
+		}
+	}
+
+	// Une table à la demande n'accepte pas de débit provisionné pour l'index
+	if description.Table.BillingModeSummary == nil || description.Table.BillingModeSummary.BillingMode != types.BillingModePayPerRequest {
+		index.ProvisionedThroughput = &types.ProvisionedThroughput{
+			ReadCapacityUnits:  aws.Int64(5),
+			WriteCapacityUnits: aws.Int64(5),
+		}
+	}
+
+	_, err = ddb.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName:            aws.String(ddb.blockchainEventsTableName),
+		AttributeDefinitions: attributs,
+		GlobalSecondaryIndexUpdates: []types.GlobalSecondaryIndexUpdate{
+			{Create: &types.CreateGlobalSecondaryIndexAction{
+				IndexName:             index.IndexName,
+				KeySchema:             index.KeySchema,
+				Projection:            index.Projection,
+				ProvisionedThroughput: index.ProvisionedThroughput,
+			}},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("erreur ajout index %s: %w", ddb.indiceProducto, err)
+	}
+	log.Printf("✅ Index %s ajouté à %s (scan en attendant la fin de sa construction)", ddb.indiceProducto, ddb.blockchainEventsTableName)
+	return nil
+}
+
+// ArnStreamEventosBlockchain retourne l'ARN du stream de la table blockcahin_medysupyly. Si le stream
+// est désactivé, il est activé (NEW_IMAGE) quand activer est vrai, sinon une erreur est retournée.
+func (ddb *DynamoDBService) ArnStreamEventosBlockchain(ctx context.Context, activer bool) (string, error) {
+	description, err := ddb.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(ddb.blockchainEventsTableName),
+	})
+	if err != nil {
+		return "", fmt.Errorf("erreur description table %s: %w", ddb.blockchainEventsTableName, err)
+	}
+
+	specification := description.Table.StreamSpecification
+	if specification != nil && aws.ToBool(specification.StreamEnabled) {
+		if specification.StreamViewType == types.StreamViewTypeKeysOnly || specification.StreamViewType == types.StreamViewTypeOldImage {
+			return "", fmt.Errorf("stream de %s sans nouvelle image (%s)", ddb.blockchainEventsTableName, specification.StreamViewType)
+		}
+		return aws.ToString(description.Table.LatestStreamArn), nil
+	}
+	if !activer {
+		return "", fmt.Errorf("stream désactivé sur la table %s", ddb.blockchainEventsTableName)
+	}
+
+	sortie, err := ddb.client.UpdateTable(ctx, &dynamodb.UpdateTableInput{
+		TableName: aws.String(ddb.blockchainEventsTableName),
+		StreamSpecification: &types.StreamSpecification{
+			StreamEnabled:  aws.Bool(true),
+			StreamViewType: types.StreamViewTypeNewImage,
+		},
+	})
+	if err != nil {
+		return "", fmt.Errorf("erreur activation stream %s: %w", ddb.blockchainEventsTableName, err)
+	}
+	log.Printf("✅ Stream activé sur %s", ddb.blockchainEventsTableName)
+	return aws.ToString(sortie.TableDescription.LatestStreamArn), nil
+}
+
+// ObtenerTousEventosBlockchain récupère tous les événements de la table blockcahin_medysupyly
+func (ddb *DynamoDBService) ObtenerTousEventosBlockchain(ctx context.Context) ([]models.BlockchainEvent, error) {
+	items, err := lireTout(ddb.requeteTousEventosBlockchain(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("erreur récupération tous les événements blockchain: %w", err)
 	}
+	return eventosBlockchainDepuisItems(items)
+}
 
-	var eventos []models.BlockchainEvent
-	err = attributevalue.UnmarshalListOfMaps(result.Items, &eventos)
+// ObtenerTousEventosBlockchainPagina lit une page de la table blockcahin_medysupyly
+func (ddb *DynamoDBService) ObtenerTousEventosBlockchainPagina(ctx context.Context, consulta ConsultaPaginada) ([]models.BlockchainEvent, string, error) {
+	items, suivant, err := lirePage(consulta, "blockchain", ddb.requeteTousEventosBlockchain(ctx))
 	if err != nil {
-		return nil, fmt.Errorf("erreur unmarshalling tous les événements blockchain: %w", err)
+		return nil, "", fmt.Errorf("erreur récupération tous les événements blockchain: %w", err)
 	}
+	eventos, err := eventosBlockchainDepuisItems(items)
+	return eventos, suivant, err
+}
 
-	return eventos, nil
+func (ddb *DynamoDBService) requeteTousEventosBlockchain(ctx context.Context) requetePage {
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(ddb.blockchainEventsTableName),
+			ExclusiveStartKey: startKey,
+			Limit:             limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
+}
+
+// ParcourirEventosBlockchain scanne un segment de la table blockcahin_medysupyly page par page
+func (ddb *DynamoDBService) ParcourirEventosBlockchain(ctx context.Context, segmento, totalSegmentos int, traiter func([]models.BlockchainEvent) error) error {
+	var startKey map[string]types.AttributeValue
+
+	for {
+		result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+			TableName:         aws.String(ddb.blockchainEventsTableName),
+			Segment:           aws.Int32(int32(segmento)),
+			TotalSegments:     aws.Int32(int32(totalSegmentos)),
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return fmt.Errorf("erreur scan segment %d/%d: %w", segmento, totalSegmentos, err)
+		}
+
+		eventos, err := eventosBlockchainDepuisItems(result.Items)
+		if err != nil {
+			return err
+		}
+		if len(eventos) > 0 {
+			if err := traiter(eventos); err != nil {
+				return err
+			}
+		}
+
+		if len(result.LastEvaluatedKey) == 0 {
+			return nil
+		}
+		startKey = result.LastEvaluatedKey
+	}
+}
+
+// pageDynamo est le résultat d'une requête Query ou Scan
+type pageDynamo struct {
+	Items    []map[string]types.AttributeValue
+	Derniere map[string]types.AttributeValue // LastEvaluatedKey (vide en fin de parcours)
+}
+
+// requetePage exécute une requête Query ou Scan depuis une clé de départ (limite 0: sans limite)
+type requetePage func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error)
+
+// lireTout enchaîne les pages jusqu'à la fin du parcours
+func lireTout(requete requetePage) ([]map[string]types.AttributeValue, error) {
+	var items []map[string]types.AttributeValue
+	var startKey map[string]types.AttributeValue
+
+	for {
+		page, err := requete(startKey, 0)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, page.Items...)
+
+		if len(page.Derniere) == 0 {
+			return items, nil
+		}
+		startKey = page.Derniere
+	}
+}
+
+// lirePage lit au plus consulta.limite() éléments à partir du cursor. Avec un filtre, une requête
+// peut renvoyer moins d'éléments que demandé: la lecture continue jusqu'à remplir la page.
+func lirePage(consulta ConsultaPaginada, portee string, requete requetePage) ([]map[string]types.AttributeValue, string, error) {
+	startKey, err := decoderCurseur(consulta.Cursor, portee)
+	if err != nil {
+		return nil, "", err
+	}
+
+	limite := consulta.limite()
+	var items []map[string]types.AttributeValue
+	for {
+		page, err := requete(startKey, int32(limite-len(items)))
+		if err != nil {
+			return nil, "", err
+		}
+		items = append(items, page.Items...)
+
+		if len(page.Derniere) == 0 {
+			return items, "", nil
+		}
+		if len(items) >= limite {
+			suivant, err := encoderCurseur(portee, page.Derniere)
+			return items, suivant, err
+		}
+		startKey = page.Derniere
+	}
+}
+
+// limiteRequete convertit une limite en paramètre Limit (nil: pas de limite)
+func limiteRequete(limite int32) *int32 {
+	if limite <= 0 {
+		return nil
+	}
+	return aws.Int32(limite)
 }
@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// DynamoDBRegistroPuntosStream stocke les séquences traitées dans une table DynamoDB
+// (clé de partition idShard)
+type DynamoDBRegistroPuntosStream struct {
+	client    *dynamodb.Client
+	tableName string
+}
+
+// NewDynamoDBRegistroPuntosStream crée une nouvelle instance de DynamoDBRegistroPuntosStream
+func NewDynamoDBRegistroPuntosStream(client *dynamodb.Client, tableName string) *DynamoDBRegistroPuntosStream {
+	return &DynamoDBRegistroPuntosStream{
+		client:    client,
+		tableName: tableName,
+	}
+}
+
+// ObtenerSecuenciaShard retourne la dernière séquence traitée du shard ("" si aucune)
+func (drp *DynamoDBRegistroPuntosStream) ObtenerSecuenciaShard(ctx context.Context, idShard string) (string, error) {
+	result, err := drp.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(drp.tableName),
+		Key: map[string]types.AttributeValue{
+			"idShard": &types.AttributeValueMemberS{Value: idShard},
+		},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return "", fmt.Errorf("erreur récupération séquence du shard %s: %w", idShard, err)
+	}
+
+	secuencia, ok := result.Item["secuencia"].(*types.AttributeValueMemberS)
+	if !ok {
+		return "", nil // Non trouvé
+	}
+	return secuencia.Value, nil
+}
+
+// GuardarSecuenciaShard enregistre la dernière séquence traitée du shard
+func (drp *DynamoDBRegistroPuntosStream) GuardarSecuenciaShard(ctx context.Context, idShard, secuencia string) error {
+	_, err := drp.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(drp.tableName),
+		Item: map[string]types.AttributeValue{
+			"idShard":       &types.AttributeValueMemberS{Value: idShard},
+			"secuencia":     &types.AttributeValueMemberS{Value: secuencia},
+			"actualizadoEn": &types.AttributeValueMemberS{Value: time.Now().UTC().Format(time.RFC3339)},
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("erreur sauvegarde séquence du shard %s: %w", idShard, err)
+	}
+	return nil
+}
+
+// AsegurarTabla crée la table des séquences si elle n'existe pas
+func (drp *DynamoDBRegistroPuntosStream) AsegurarTabla(ctx context.Context) error {
+	_, err := drp.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(drp.tableName),
+	})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("erreur description table %s: %w", drp.tableName, err)
+	}
+
+	_, err = drp.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(drp.tableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("idShard"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("idShard"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return fmt.Errorf("erreur création table %s: %w", drp.tableName, err)
+	}
+	log.Printf("✅ Table %s créée", drp.tableName)
+	return nil
+}
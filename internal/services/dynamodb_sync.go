@@ -0,0 +1,114 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// DefinirTablaPuntosSincronizacion configure la table des points de reprise (clé idProducto).
+// Sans table, chaque synchronisation relit toutes les lignes blockchain du produit.
+func (ddb *DynamoDBService) DefinirTablaPuntosSincronizacion(nombre string) {
+	ddb.puntosTableName = nombre
+}
+
+// ObtenerPuntoSincronizacion lit le point de reprise d'un produit (nil si absent)
+func (ddb *DynamoDBService) ObtenerPuntoSincronizacion(ctx context.Context, idProducto string) (*models.PuntoSincronizacion, error) {
+	if ddb.puntosTableName == "" {
+		return nil, nil
+	}
+
+	result, err := ddb.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(ddb.puntosTableName),
+		Key: map[string]types.AttributeValue{
+			"idProducto": &types.AttributeValueMemberS{Value: idProducto},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération point de synchronisation: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil // Non trouvé
+	}
+
+	var punto models.PuntoSincronizacion
+	if err := attributevalue.UnmarshalMap(result.Item, &punto); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling point de synchronisation: %w", err)
+	}
+	return &punto, nil
+}
+
+// GuardarPuntoSincronizacion enregistre le point de reprise d'un produit. L'écriture est conditionnelle:
+// une synchronisation concurrente plus avancée n'est pas ramenée en arrière.
+func (ddb *DynamoDBService) GuardarPuntoSincronizacion(ctx context.Context, punto *models.PuntoSincronizacion) error {
+	if ddb.puntosTableName == "" {
+		return nil
+	}
+
+	item, err := attributevalue.MarshalMap(punto)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling point de synchronisation: %w", err)
+	}
+
+	_, err = ddb.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(ddb.puntosTableName),
+		Item:      item,
+		ConditionExpression: aws.String("attribute_not_exists(idProducto) OR ultimoUpdatedAt < :ultimoUpdatedAt" +
+			" OR (ultimoUpdatedAt = :ultimoUpdatedAt AND ultimoIdTransaction <= :ultimoIdTransaction)"),
+		ExpressionAttributeValues: map[string]types.AttributeValue{
+			":ultimoUpdatedAt":     &types.AttributeValueMemberS{Value: punto.UltimoUpdatedAt},
+			":ultimoIdTransaction": &types.AttributeValueMemberS{Value: punto.UltimoIDTransaction},
+		},
+	})
+	if err != nil {
+		var conditionFailed *types.ConditionalCheckFailedException
+		if errors.As(err, &conditionFailed) {
+			log.Printf("📋 Point de synchronisation de %s déjà plus avancé", punto.IDProducto)
+			return nil
+		}
+		return fmt.Errorf("erreur sauvegarde point de synchronisation: %w", err)
+	}
+	return nil
+}
+
+// AsegurarTablaPuntosSincronizacion crée la table des points de reprise si elle n'existe pas
+func (ddb *DynamoDBService) AsegurarTablaPuntosSincronizacion(ctx context.Context) error {
+	if ddb.puntosTableName == "" {
+		return nil
+	}
+
+	_, err := ddb.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(ddb.puntosTableName),
+	})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("erreur description table %s: %w", ddb.puntosTableName, err)
+	}
+
+	_, err = ddb.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(ddb.puntosTableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("idProducto"), KeyType: types.KeyTypeHash},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("idProducto"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return fmt.Errorf("erreur création table %s: %w", ddb.puntosTableName, err)
+	}
+	log.Printf("✅ Table %s créée", ddb.puntosTableName)
+	return nil
+}
@@ -0,0 +1,235 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// Index secondaires de la table des tâches, triés par date de création
+const (
+	IndiceTareasEstado   = "status-createdAt-index"
+	IndiceTareasProducto = "idProducto-createdAt-index" // Creux: seules les reconstructions ont un produit
+)
+
+// DefinirTablaTareas configure la table des tâches asynchrones (clé de partition taskId)
+func (ddb *DynamoDBService) DefinirTablaTareas(nombre string) {
+	ddb.tareasTableName = nombre
+}
+
+// GuardarTaskStatus sauvegarde le statut d'une tâche
+func (ddb *DynamoDBService) GuardarTaskStatus(ctx context.Context, taskStatus *models.TaskStatus) error {
+	if ddb.tareasTableName == "" {
+		return fmt.Errorf("aucune table de tâches configurée")
+	}
+
+	item, err := attributevalue.MarshalMap(taskStatus)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling task status: %w", err)
+	}
+
+	_, err = ddb.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(ddb.tareasTableName),
+		Item:      item,
+	})
+	if err != nil {
+		return fmt.Errorf("erreur sauvegarde task status: %w", err)
+	}
+
+	return nil
+}
+
+// ObtenerTaskStatus récupère le statut d'une tâche. La suppression TTL pouvant être différée de
+// plusieurs heures, une tâche expirée encore présente est ignorée.
+func (ddb *DynamoDBService) ObtenerTaskStatus(ctx context.Context, taskID string) (*models.TaskStatus, error) {
+	if ddb.tareasTableName == "" {
+		return nil, nil
+	}
+
+	result, err := ddb.client.GetItem(ctx, &dynamodb.GetItemInput{
+		TableName: aws.String(ddb.tareasTableName),
+		Key: map[string]types.AttributeValue{
+			"taskId": &types.AttributeValueMemberS{Value: taskID},
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération task status: %w", err)
+	}
+	if result.Item == nil {
+		return nil, nil // Non trouvé
+	}
+
+	var taskStatus models.TaskStatus
+	if err := attributevalue.UnmarshalMap(result.Item, &taskStatus); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling task status: %w", err)
+	}
+	if taskStatus.Expirada(time.Now()) {
+		return nil, nil
+	}
+
+	return &taskStatus, nil
+}
+
+// ListarTareas lit une page des tâches non expirées. Avec un filtre, l'index correspondant est
+// interrogé de la plus récente à la plus ancienne; sans filtre, la table est parcourue (ordre non garanti).
+func (ddb *DynamoDBService) ListarTareas(ctx context.Context, filtro FiltroTareas, consulta ConsultaPaginada) ([]models.TaskStatus, string, error) {
+	if ddb.tareasTableName == "" {
+		return nil, "", nil
+	}
+
+	items, suivant, err := lirePage(consulta, "tareas#"+filtro.Status+"#"+filtro.IDProducto, ddb.requeteTareas(ctx, filtro))
+	if err != nil {
+		return nil, "", fmt.Errorf("erreur récupération tâches: %w", err)
+	}
+
+	var tareas []models.TaskStatus
+	if err := attributevalue.UnmarshalListOfMaps(items, &tareas); err != nil {
+		return nil, "", fmt.Errorf("erreur unmarshalling tâches: %w", err)
+	}
+	return tareas, suivant, nil
+}
+
+func (ddb *DynamoDBService) requeteTareas(ctx context.Context, filtro FiltroTareas) requetePage {
+	return func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		var noms map[string]string // status est un mot réservé DynamoDB
+		valeurs := map[string]types.AttributeValue{
+			":maintenant": &types.AttributeValueMemberN{Value: strconv.FormatInt(time.Now().Unix(), 10)},
+		}
+		filtre := "(attribute_not_exists(expiraEn) OR expiraEn > :maintenant)"
+
+		var indice, cle string
+		switch {
+		case filtro.IDProducto != "":
+			indice, cle = IndiceTareasProducto, "idProducto = :idProducto"
+			valeurs[":idProducto"] = &types.AttributeValueMemberS{Value: filtro.IDProducto}
+			if filtro.Status != "" {
+				filtre += " AND #status = :status"
+				noms = map[string]string{"#status": "status"}
+				valeurs[":status"] = &types.AttributeValueMemberS{Value: filtro.Status}
+			}
+		case filtro.Status != "":
+			indice, cle = IndiceTareasEstado, "#status = :status"
+			noms = map[string]string{"#status": "status"}
+			valeurs[":status"] = &types.AttributeValueMemberS{Value: filtro.Status}
+		default:
+			result, err := ddb.client.Scan(ctx, &dynamodb.ScanInput{
+				TableName:                 aws.String(ddb.tareasTableName),
+				FilterExpression:          aws.String(filtre),
+				ExpressionAttributeValues: valeurs,
+				ExclusiveStartKey:         startKey,
+				Limit:                     limiteRequete(limite),
+			})
+			if err != nil {
+				return nil, err
+			}
+			return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+		}
+
+		result, err := ddb.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(ddb.tareasTableName),
+			IndexName:                 aws.String(indice),
+			KeyConditionExpression:    aws.String(cle),
+			FilterExpression:          aws.String(filtre),
+			ExpressionAttributeNames:  noms,
+			ExpressionAttributeValues: valeurs,
+			ScanIndexForward:          aws.Bool(false),
+			ExclusiveStartKey:         startKey,
+			Limit:                     limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	}
+}
+
+// AsegurarTablaTareas crée la table des tâches et ses index si elle n'existe pas, puis active
+// l'expiration TTL sur l'attribut expiraEn
+func (ddb *DynamoDBService) AsegurarTablaTareas(ctx context.Context) error {
+	if ddb.tareasTableName == "" {
+		return nil
+	}
+
+	_, err := ddb.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(ddb.tareasTableName),
+	})
+	if err != nil {
+		var notFound *types.ResourceNotFoundException
+		if !errors.As(err, &notFound) {
+			return fmt.Errorf("erreur description table %s: %w", ddb.tareasTableName, err)
+		}
+
+		indice := func(nombre, particion string) types.GlobalSecondaryIndex {
+			return types.GlobalSecondaryIndex{
+				IndexName: aws.String(nombre),
+				KeySchema: []types.KeySchemaElement{
+					{AttributeName: aws.String(particion), KeyType: types.KeyTypeHash},
+					{AttributeName: aws.String("createdAt"), KeyType: types.KeyTypeRange},
+				},
+				Projection: &types.Projection{ProjectionType: types.ProjectionTypeAll},
+			}
+		}
+
+		_, err = ddb.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+			TableName: aws.String(ddb.tareasTableName),
+			KeySchema: []types.KeySchemaElement{
+				{AttributeName: aws.String("taskId"), KeyType: types.KeyTypeHash},
+			},
+			AttributeDefinitions: []types.AttributeDefinition{
+				{AttributeName: aws.String("taskId"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("status"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("idProducto"), AttributeType: types.ScalarAttributeTypeS},
+				{AttributeName: aws.String("createdAt"), AttributeType: types.ScalarAttributeTypeS},
+			},
+			GlobalSecondaryIndexes: []types.GlobalSecondaryIndex{
+				indice(IndiceTareasEstado, "status"),
+				indice(IndiceTareasProducto, "idProducto"),
+			},
+			BillingMode: types.BillingModePayPerRequest,
+		})
+		if err != nil {
+			return fmt.Errorf("erreur création table %s: %w", ddb.tareasTableName, err)
+		}
+		log.Printf("✅ Table %s créée", ddb.tareasTableName)
+
+		// Le TTL ne peut être activé qu'une fois la table active
+		waiter := dynamodb.NewTableExistsWaiter(ddb.client)
+		if err := waiter.Wait(ctx, &dynamodb.DescribeTableInput{TableName: aws.String(ddb.tareasTableName)}, time.Minute); err != nil {
+			return fmt.Errorf("erreur attente table %s: %w", ddb.tareasTableName, err)
+		}
+	}
+
+	ttl, err := ddb.client.DescribeTimeToLive(ctx, &dynamodb.DescribeTimeToLiveInput{
+		TableName: aws.String(ddb.tareasTableName),
+	})
+	if err != nil {
+		return fmt.Errorf("erreur description TTL de %s: %w", ddb.tareasTableName, err)
+	}
+	if description := ttl.TimeToLiveDescription; description != nil &&
+		(description.TimeToLiveStatus == types.TimeToLiveStatusEnabled || description.TimeToLiveStatus == types.TimeToLiveStatusEnabling) {
+		return nil
+	}
+
+	_, err = ddb.client.UpdateTimeToLive(ctx, &dynamodb.UpdateTimeToLiveInput{
+		TableName: aws.String(ddb.tareasTableName),
+		TimeToLiveSpecification: &types.TimeToLiveSpecification{
+			AttributeName: aws.String("expiraEn"),
+			Enabled:       aws.Bool(true),
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("erreur activation TTL de %s: %w", ddb.tareasTableName, err)
+	}
+	log.Printf("✅ Expiration TTL (expiraEn) activée sur %s", ddb.tareasTableName)
+	return nil
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// DefinirTablaIntentosVerificacion configure la table du journal des tentatives de vérification
+// (clé de partition claveEvento = idProducto#idEvento, clé de tri idIntento).
+// Sans table, les tentatives ne sont pas conservées.
+func (ddb *DynamoDBService) DefinirTablaIntentosVerificacion(nombre string) {
+	ddb.intentosTableName = nombre
+}
+
+// GuardarIntentosVerificacion ajoute des tentatives au journal par BatchWriteItem
+func (ddb *DynamoDBService) GuardarIntentosVerificacion(ctx context.Context, intentos []models.IntentoVerificacion) error {
+	if ddb.intentosTableName == "" {
+		return nil
+	}
+
+	for debut := 0; debut < len(intentos); debut += tamanoLoteEscritura {
+		fin := min(debut+tamanoLoteEscritura, len(intentos))
+
+		requetes := make([]types.WriteRequest, 0, fin-debut)
+		for _, intento := range intentos[debut:fin] {
+			item, err := attributevalue.MarshalMap(intento)
+			if err != nil {
+				return fmt.Errorf("erreur marshalling tentative de vérification %s: %w", intento.IDIntento, err)
+			}
+			item["claveEvento"] = &types.AttributeValueMemberS{Value: claveIntentos(intento.IDProducto, intento.IDEvento)}
+			requetes = append(requetes, types.WriteRequest{PutRequest: &types.PutRequest{Item: item}})
+		}
+
+		if err := ddb.ecrireLot(ctx, map[string][]types.WriteRequest{ddb.intentosTableName: requetes}); err != nil {
+			return fmt.Errorf("erreur sauvegarde tentatives de vérification: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// ListarIntentosVerificacion lit toutes les tentatives d'un événement, dans l'ordre de la clé de tri
+func (ddb *DynamoDBService) ListarIntentosVerificacion(ctx context.Context, idProducto, idEvento string) ([]models.IntentoVerificacion, error) {
+	if ddb.intentosTableName == "" {
+		return nil, nil
+	}
+
+	items, err := lireTout(func(startKey map[string]types.AttributeValue, limite int32) (*pageDynamo, error) {
+		result, err := ddb.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(ddb.intentosTableName),
+			KeyConditionExpression: aws.String("claveEvento = :claveEvento"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":claveEvento": &types.AttributeValueMemberS{Value: claveIntentos(idProducto, idEvento)},
+			},
+			ExclusiveStartKey: startKey,
+			Limit:             limiteRequete(limite),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return &pageDynamo{Items: result.Items, Derniere: result.LastEvaluatedKey}, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération tentatives de vérification: %w", err)
+	}
+
+	var intentos []models.IntentoVerificacion
+	if err := attributevalue.UnmarshalListOfMaps(items, &intentos); err != nil {
+		return nil, fmt.Errorf("erreur unmarshalling tentatives de vérification: %w", err)
+	}
+	return intentos, nil
+}
+
+// claveIntentos construit la clé de partition du journal d'un événement
+func claveIntentos(idProducto, idEvento string) string {
+	return idProducto + "#" + idEvento
+}
+
+// AsegurarTablaIntentosVerificacion crée la table du journal de vérification si elle n'existe pas
+func (ddb *DynamoDBService) AsegurarTablaIntentosVerificacion(ctx context.Context) error {
+	if ddb.intentosTableName == "" {
+		return nil
+	}
+
+	_, err := ddb.client.DescribeTable(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(ddb.intentosTableName),
+	})
+	if err == nil {
+		return nil
+	}
+	var notFound *types.ResourceNotFoundException
+	if !errors.As(err, &notFound) {
+		return fmt.Errorf("erreur description table %s: %w", ddb.intentosTableName, err)
+	}
+
+	_, err = ddb.client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(ddb.intentosTableName),
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("claveEvento"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("idIntento"), KeyType: types.KeyTypeRange},
+		},
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("claveEvento"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("idIntento"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	if err != nil {
+		return fmt.Errorf("erreur création table %s: %w", ddb.intentosTableName, err)
+	}
+	log.Printf("✅ Table %s créée", ddb.intentosTableName)
+	return nil
+}
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// esquemaJSON est le sous-ensemble de JSON Schema (draft 2020-12) utilisé par les schémas
+// embarqués: type, required, properties, additionalProperties, items, enum, minLength, pattern et
+// format date-time. Les autres mots-clés ($schema, $id, title, description...) sont ignorés.
+type esquemaJSON struct {
+	Type                 tiposJSON               `json:"type"`
+	Required             []string                `json:"required"`
+	Properties           map[string]*esquemaJSON `json:"properties"`
+	AdditionalProperties *bool                   `json:"additionalProperties"`
+	Items                *esquemaJSON            `json:"items"`
+	Enum                 []interface{}           `json:"enum"`
+	MinLength            *int                    `json:"minLength"`
+	Pattern              string                  `json:"pattern"`
+	Format               string                  `json:"format"`
+
+	patron *regexp.Regexp
+}
+
+// tiposJSON accepte "type" sous forme de chaîne ou de liste
+type tiposJSON []string
+
+func (t *tiposJSON) UnmarshalJSON(data []byte) error {
+	var unique string
+	if err := json.Unmarshal(data, &unique); err == nil {
+		*t = tiposJSON{unique}
+		return nil
+	}
+	var liste []string
+	if err := json.Unmarshal(data, &liste); err != nil {
+		return fmt.Errorf("type doit être une chaîne ou une liste de chaînes")
+	}
+	*t = liste
+	return nil
+}
+
+// compilerEsquemaJSON lit un schéma et prépare ses expressions régulières
+func compilerEsquemaJSON(data []byte) (*esquemaJSON, error) {
+	var esquema esquemaJSON
+	if err := json.Unmarshal(data, &esquema); err != nil {
+		return nil, fmt.Errorf("schéma JSON illisible: %w", err)
+	}
+	if err := esquema.compiler(); err != nil {
+		return nil, err
+	}
+	return &esquema, nil
+}
+
+func (e *esquemaJSON) compiler() error {
+	if e.Pattern != "" {
+		patron, err := regexp.Compile(e.Pattern)
+		if err != nil {
+			return fmt.Errorf("pattern %q invalide: %w", e.Pattern, err)
+		}
+		e.patron = patron
+	}
+	for _, propriete := range e.Properties {
+		if err := propriete.compiler(); err != nil {
+			return err
+		}
+	}
+	if e.Items != nil {
+		return e.Items.compiler()
+	}
+	return nil
+}
+
+// valider retourne les violations du schéma par le document, décodé avec json.Decoder.UseNumber
+func (e *esquemaJSON) valider(valeur interface{}) []string {
+	var violations []string
+	e.validerChemin(valeur, "$", &violations)
+	return violations
+}
+
+func (e *esquemaJSON) validerChemin(valeur interface{}, chemin string, violations *[]string) {
+	if len(e.Type) > 0 && !e.Type.accepte(valeur) {
+		*violations = append(*violations, fmt.Sprintf("%s: type %s attendu, %s reçu", chemin, strings.Join(e.Type, " ou "), typeJSON(valeur)))
+		return
+	}
+
+	if len(e.Enum) > 0 && !valeurEnumeree(valeur, e.Enum) {
+		*violations = append(*violations, fmt.Sprintf("%s: valeur %v hors de %v", chemin, valeur, e.Enum))
+	}
+
+	switch v := valeur.(type) {
+	case string:
+		if e.MinLength != nil && len([]rune(v)) < *e.MinLength {
+			*violations = append(*violations, fmt.Sprintf("%s: longueur minimale %d", chemin, *e.MinLength))
+		}
+		if e.patron != nil && !e.patron.MatchString(v) {
+			*violations = append(*violations, fmt.Sprintf("%s: ne respecte pas %s", chemin, e.Pattern))
+		}
+		if e.Format == "date-time" {
+			if _, err := time.Parse(time.RFC3339, v); err != nil {
+				*violations = append(*violations, fmt.Sprintf("%s: date-time RFC 3339 attendue", chemin))
+			}
+		}
+
+	case map[string]interface{}:
+		for _, requis := range e.Required {
+			if _, ok := v[requis]; !ok {
+				*violations = append(*violations, fmt.Sprintf("%s.%s: requis", chemin, requis))
+			}
+		}
+
+		cles := make([]string, 0, len(v))
+		for cle := range v {
+			cles = append(cles, cle)
+		}
+		sort.Strings(cles) // Violations dans un ordre stable
+
+		for _, cle := range cles {
+			propriete, connue := e.Properties[cle]
+			switch {
+			case connue:
+				propriete.validerChemin(v[cle], chemin+"."+cle, violations)
+			case e.AdditionalProperties != nil && !*e.AdditionalProperties:
+				*violations = append(*violations, fmt.Sprintf("%s.%s: propriété non autorisée", chemin, cle))
+			}
+		}
+
+	case []interface{}:
+		if e.Items != nil {
+			for i, element := range v {
+				e.Items.validerChemin(element, fmt.Sprintf("%s[%d]", chemin, i), violations)
+			}
+		}
+	}
+}
+
+// accepte indique si la valeur est de l'un des types
+func (t tiposJSON) accepte(valeur interface{}) bool {
+	reel := typeJSON(valeur)
+	for _, attendu := range t {
+		if attendu == reel || (attendu == "number" && reel == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+// typeJSON nomme le type JSON d'une valeur décodée
+func typeJSON(valeur interface{}) string {
+	switch v := valeur.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", valeur)
+	}
+}
+
+// valeurEnumeree compare la valeur aux valeurs permises (nombres comparés par leur texte)
+func valeurEnumeree(valeur interface{}, permises []interface{}) bool {
+	for _, permise := range permises {
+		if nombre, ok := valeur.(json.Number); ok {
+			if fmt.Sprint(permise) == nombre.String() {
+				return true
+			}
+			continue
+		}
+		if reflect.DeepEqual(valeur, permise) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// VersionEsquemaTransaccion est la version de TransaccionBlockchainEvent produite par les upcasters
+const VersionEsquemaTransaccion = "1.0"
+
+// ErrVersionEsquemaDesconocida signale un schemaVersion sans schéma embarqué (message poison,
+// rejouable depuis la DLQ une fois la version prise en charge)
+var ErrVersionEsquemaDesconocida = fmt.Errorf("%w: version de schéma inconnue", ErrMensajeInvalido)
+
+// Schémas JSON de TransaccionBlockchainEvent, un fichier par version: transaccion_blockchain_<version>.json
+//
+//go:embed esquemas/transaccion_blockchain_*.json
+var fichiersEsquemasTransaccion embed.FS
+
+// upcaster traduit un document vers la version suivante
+type upcaster struct {
+	vers     string
+	traduire func(document map[string]interface{}) error
+}
+
+// upcastersTransaccion: une entrée par version antérieure à VersionEsquemaTransaccion
+var upcastersTransaccion = map[string]upcaster{
+	"0.9": {vers: "1.0", traduire: upcasterTransaccion09},
+}
+
+// esquemasTransaccion associe chaque version prise en charge à son schéma compilé
+var esquemasTransaccion = chargerEsquemasTransaccion()
+
+// chargerEsquemasTransaccion compile les schémas embarqués. Ils font partie du binaire: une erreur
+// est une erreur de programmation.
+func chargerEsquemasTransaccion() map[string]*esquemaJSON {
+	fichiers, err := fichiersEsquemasTransaccion.ReadDir("esquemas")
+	if err != nil {
+		panic(fmt.Sprintf("schémas TransaccionBlockchainEvent illisibles: %v", err))
+	}
+
+	esquemas := make(map[string]*esquemaJSON, len(fichiers))
+	for _, fichier := range fichiers {
+		version := strings.TrimSuffix(strings.TrimPrefix(fichier.Name(), "transaccion_blockchain_"), ".json")
+		data, err := fichiersEsquemasTransaccion.ReadFile("esquemas/" + fichier.Name())
+		if err != nil {
+			panic(fmt.Sprintf("schéma %s illisible: %v", fichier.Name(), err))
+		}
+		esquema, err := compilerEsquemaJSON(data)
+		if err != nil {
+			panic(fmt.Sprintf("schéma %s invalide: %v", fichier.Name(), err))
+		}
+		esquemas[version] = esquema
+	}
+
+	// Chaque version antérieure doit pouvoir atteindre la version courante
+	for version := range esquemas {
+		for version != VersionEsquemaTransaccion {
+			etape, ok := upcastersTransaccion[version]
+			if !ok || esquemas[etape.vers] == nil {
+				panic(fmt.Sprintf("aucun upcaster de TransaccionBlockchainEvent %s vers %s", version, VersionEsquemaTransaccion))
+			}
+			version = etape.vers
+		}
+	}
+
+	return esquemas
+}
+
+// VersionesEsquemaTransaccion liste les versions de TransaccionBlockchainEvent acceptées
+func VersionesEsquemaTransaccion() []string {
+	versions := make([]string, 0, len(esquemasTransaccion))
+	for version := range esquemasTransaccion {
+		versions = append(versions, version)
+	}
+	sort.Strings(versions)
+	return versions
+}
+
+// DecodificarEventoTransaccion valide un message TransaccionBlockchainEvent contre le schéma de
+// son schemaVersion, le traduit vers la version courante puis le décode. Toute erreur enveloppe
+// ErrMensajeInvalido.
+func DecodificarEventoTransaccion(value []byte) (*models.TransaccionBlockchainEvent, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber() // Distingue entiers et décimaux pour la validation
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, fmt.Errorf("%w: JSON illisible: %w", ErrMensajeInvalido, err)
+	}
+	if document == nil {
+		return nil, fmt.Errorf("%w: objet JSON attendu", ErrMensajeInvalido)
+	}
+
+	version, _ := document["schemaVersion"].(string)
+	esquema, ok := esquemasTransaccion[version]
+	if !ok {
+		return nil, fmt.Errorf("%w %q (acceptées: %s)", ErrVersionEsquemaDesconocida, version, strings.Join(VersionesEsquemaTransaccion(), ", "))
+	}
+	if err := violationsSchema(version, esquema.valider(document)); err != nil {
+		return nil, err
+	}
+
+	// Traduire jusqu'à la version courante, puis vérifier le résultat
+	for version != VersionEsquemaTransaccion {
+		etape := upcastersTransaccion[version]
+		if err := etape.traduire(document); err != nil {
+			return nil, fmt.Errorf("%w: traduction %s vers %s: %w", ErrMensajeInvalido, version, etape.vers, err)
+		}
+		document["schemaVersion"] = etape.vers
+		version = etape.vers
+		if err := violationsSchema(version, esquemasTransaccion[version].valider(document)); err != nil {
+			return nil, err
+		}
+	}
+
+	courant, err := json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMensajeInvalido, err)
+	}
+	var event models.TransaccionBlockchainEvent
+	if err := json.Unmarshal(courant, &event); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrMensajeInvalido, err)
+	}
+	return &event, nil
+}
+
+// violationsSchema regroupe les violations d'un schéma en une erreur
+func violationsSchema(version string, violations []string) error {
+	if len(violations) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: schéma %s: %s", ErrMensajeInvalido, version, strings.Join(violations, "; "))
+}
+
+// upcasterTransaccion09 traduit 0.9 vers 1.0: datosEvento, transmis comme chaîne JSON, devient un objet
+func upcasterTransaccion09(document map[string]interface{}) error {
+	brut, _ := document["datosEvento"].(string)
+
+	decoder := json.NewDecoder(strings.NewReader(brut))
+	decoder.UseNumber()
+	var datos map[string]interface{}
+	if err := decoder.Decode(&datos); err != nil || datos == nil {
+		return errors.New("datosEvento n'est pas un objet JSON encodé")
+	}
+
+	document["datosEvento"] = datos
+	return nil
+}
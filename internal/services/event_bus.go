@@ -0,0 +1,68 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// EventBus abstrait le transport des événements (Kafka ou broker en mémoire)
+type EventBus interface {
+	// ConsumeEvents consomme les événements TransaccionBlockchain jusqu'à l'annulation du contexte
+	ConsumeEvents(ctx context.Context, handler func(event *models.TransaccionBlockchainEvent) error) error
+	// PublishHistorialReconstruido publie un événement de reconstruction d'historial
+	PublishHistorialReconstruido(ctx context.Context, event *models.HistorialReconstruidoEvent) error
+	// PublishInconsistencia publie un événement d'inconsistance
+	PublishInconsistencia(ctx context.Context, event *models.InconsistenciaEvent) error
+	// RejouerDLQ réinjecte au plus max messages des lettres mortes dans le handler; ceux qui échouent
+	// encore y retournent avec un compteur de tentatives incrémenté (ErrDLQDesactivada sans file)
+	RejouerDLQ(ctx context.Context, max int, handler func(event *models.TransaccionBlockchainEvent) error) (ResumenRejeuDLQ, error)
+	// VerificarConexion vérifie que le broker est joignable
+	VerificarConexion(ctx context.Context) error
+	// Close ferme les connexions du bus
+	Close() error
+}
+
+// Vérification à la compilation que les implémentations respectent l'interface
+var (
+	_ EventBus = (*KafkaService)(nil)
+	_ EventBus = (*MemoryEventBus)(nil)
+)
+
+// Types d'événements publiés
+const (
+	EventTypeHistorialReconstruido   = "event.historial.reconstruido"
+	EventTypeHistorialInconsistencia = "event.historial.inconsistencia"
+)
+
+// traiterMessageTransaccion valide un message brut contre le schéma de sa version, le traduit
+// vers la version courante et le transmet au handler, repris selon la politique en cas d'erreur
+// transitoire. Retourne le nombre de tentatives et, en cas d'échec, le motif et la cause conservés
+// avec le message en lettres mortes.
+func traiterMessageTransaccion(ctx context.Context, value []byte, handler func(event *models.TransaccionBlockchainEvent) error, politica PoliticaReintentos) (int, string, error) {
+	// Valider et parser l'événement
+	event, err := DecodificarEventoTransaccion(value)
+	if err != nil {
+		log.Printf("❌ Erreur parsing événement: %v", err)
+		motivo := MotivoMensajeInvalido
+		if errors.Is(err, ErrVersionEsquemaDesconocida) {
+			motivo = MotivoVersionDesconocida
+		}
+		return 1, motivo, fmt.Errorf("erreur parsing événement: %w", err)
+	}
+
+	// Traiter l'événement
+	intentos, motivo, err := politica.executer(ctx, func() error {
+		return handler(event)
+	})
+	if err != nil {
+		log.Printf("❌ Erreur traitement événement %s (%d tentatives, %s): %v", event.IDEvento, intentos, motivo, err)
+		return intentos, motivo, fmt.Errorf("erreur traitement événement %s: %w", event.IDEvento, err)
+	}
+
+	log.Printf("✅ Événement traité avec succès: %s", event.IDEvento)
+	return intentos, "", nil
+}
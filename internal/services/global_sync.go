@@ -0,0 +1,321 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// ParalelismoSincronizacion règle la synchronisation globale depuis la table blockchain
+type ParalelismoSincronizacion struct {
+	Segmentos         int           // Segments du scan parallèle (un lecteur par segment)
+	Workers           int           // Workers qui enregistrent les lots par produit
+	IntervaloProgreso time.Duration // Période des rapports d'avancement
+}
+
+// Valeurs par défaut de la synchronisation globale
+const (
+	SegmentosSincronizacionPorDefecto = 4
+	WorkersSincronizacionPorDefecto   = 8
+	intervaloProgresoPorDefecto       = 5 * time.Second
+)
+
+// DefinirParalelismoSincronizacion configure la synchronisation globale (0: valeur par défaut)
+func (hs *HistorialService) DefinirParalelismoSincronizacion(paralelismo ParalelismoSincronizacion) {
+	hs.sincronizacion = paralelismo
+}
+
+// loteSincronizacion regroupe les événements d'un produit lus dans une même page
+type loteSincronizacion struct {
+	idProducto string
+	eventos    []models.BlockchainEvent
+}
+
+// compteursSincronizacion sont mis à jour par les lecteurs et les workers en parallèle
+type compteursSincronizacion struct {
+	leidos        atomic.Int64
+	sincronizados atomic.Int64
+	existentes    atomic.Int64
+	errores       atomic.Int64
+	lotes         atomic.Int64
+
+	mu      sync.Mutex
+	puntos  map[string]*models.PuntoSincronizacion // Point de reprise atteint par produit
+	echoues map[string]bool                        // Produits dont un lot n'a pas été enregistré
+}
+
+// avanzarPunto fait avancer le point de reprise d'un produit sur les lignes d'un lot enregistré;
+// un lot en échec bloque le point du produit pour toute la synchronisation
+func (c *compteursSincronizacion) avanzarPunto(lote loteSincronizacion, enregistre bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !enregistre {
+		c.echoues[lote.idProducto] = true
+		return
+	}
+	punto, ok := c.puntos[lote.idProducto]
+	if !ok {
+		punto = &models.PuntoSincronizacion{IDProducto: lote.idProducto}
+		c.puntos[lote.idProducto] = punto
+	}
+	for _, eventoBC := range lote.eventos {
+		punto.Avanzar(eventoBC)
+	}
+}
+
+// SynchroniserTousLesEventosBlockchain synchronise toute la table blockchain par un scan segmenté
+// parallèle. Chaque page lue est découpée en lots par produit, enregistrés par un pool borné de
+// workers; le canal borné entre lecteurs et workers maintient la mémoire constante. rapport (optionnel)
+// reçoit l'avancement à intervalle régulier puis une dernière fois à la fin.
+// Les segments étant lus sans ordre, les points de reprise par produit ne sont enregistrés qu'à la
+// fin d'un parcours complet: un arrêt en cours de route ne fait sauter aucune ligne aux
+// synchronisations suivantes.
+func (hs *HistorialService) SynchroniserTousLesEventosBlockchain(ctx context.Context, rapport func(models.ProgresoSincronizacion)) (*models.ProgresoSincronizacion, error) {
+	segmentos := hs.sincronizacion.Segmentos
+	if segmentos <= 0 {
+		segmentos = SegmentosSincronizacionPorDefecto
+	}
+	workers := hs.sincronizacion.Workers
+	if workers <= 0 {
+		workers = WorkersSincronizacionPorDefecto
+	}
+	intervalo := hs.sincronizacion.IntervaloProgreso
+	if intervalo <= 0 {
+		intervalo = intervaloProgresoPorDefecto
+	}
+
+	log.Printf("🔄 Synchronisation globale des événements blockchain (%d segments, %d workers)", segmentos, workers)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	iniciado := time.Now()
+	compteurs := compteursSincronizacion{
+		puntos:  make(map[string]*models.PuntoSincronizacion),
+		echoues: make(map[string]bool),
+	}
+	instantane := func() models.ProgresoSincronizacion {
+		return models.ProgresoSincronizacion{
+			Segmentos:     segmentos,
+			Leidos:        compteurs.leidos.Load(),
+			Sincronizados: compteurs.sincronizados.Load(),
+			Existentes:    compteurs.existentes.Load(),
+			Errores:       compteurs.errores.Load(),
+			Lotes:         compteurs.lotes.Load(),
+			IniciadoEn:    iniciado,
+		}
+	}
+
+	// Première erreur de lecture: elle interrompt toute la synchronisation
+	var premiereErreur error
+	var erreurOnce sync.Once
+	echouer := func(err error) {
+		erreurOnce.Do(func() {
+			premiereErreur = err
+			cancel()
+		})
+	}
+
+	trabajos := make(chan loteSincronizacion, workers*2)
+
+	// Workers: enregistrent les lots par produit
+	var wgWorkers sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wgWorkers.Add(1)
+		go func() {
+			defer wgWorkers.Done()
+			for lote := range trabajos {
+				hs.synchroniserLot(ctx, lote, &compteurs)
+			}
+		}()
+	}
+
+	// Lecteurs: un scan par segment, chaque page découpée par produit
+	var wgLecteurs sync.WaitGroup
+	for segmento := 0; segmento < segmentos; segmento++ {
+		wgLecteurs.Add(1)
+		go func(segmento int) {
+			defer wgLecteurs.Done()
+			err := hs.repository.ParcourirEventosBlockchain(ctx, segmento, segmentos, func(page []models.BlockchainEvent) error {
+				compteurs.leidos.Add(int64(len(page)))
+				for _, lote := range regrouperParProduit(page) {
+					select {
+					case trabajos <- lote:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				return nil
+			})
+			if err != nil && ctx.Err() == nil {
+				echouer(fmt.Errorf("segment %d: %w", segmento, err))
+			}
+		}(segmento)
+	}
+
+	// Rapports d'avancement
+	termine := make(chan struct{})
+	var wgRapport sync.WaitGroup
+	wgRapport.Add(1)
+	go func() {
+		defer wgRapport.Done()
+		ticker := time.NewTicker(intervalo)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				progreso := instantane()
+				log.Printf("📊 Synchronisation: %d lus, %d nouveaux, %d existants, %d erreurs", progreso.Leidos, progreso.Sincronizados, progreso.Existentes, progreso.Errores)
+				if rapport != nil {
+					rapport(progreso)
+				}
+			case <-termine:
+				return
+			}
+		}
+	}()
+
+	wgLecteurs.Wait()
+	close(trabajos)
+	wgWorkers.Wait()
+	close(termine)
+	wgRapport.Wait()
+
+	progreso := instantane()
+	fin := time.Now()
+	progreso.TerminadoEn = &fin
+	if rapport != nil {
+		rapport(progreso)
+	}
+
+	if premiereErreur != nil {
+		return &progreso, fmt.Errorf("erreur synchronisation globale: %w", premiereErreur)
+	}
+	if err := ctx.Err(); err != nil {
+		return &progreso, err
+	}
+
+	hs.guardarPuntosSincronizacion(ctx, &compteurs, iniciado)
+
+	log.Printf("✅ Synchronisation globale terminée en %s: %d lus, %d nouveaux, %d existants, %d erreurs",
+		fin.Sub(iniciado).Round(time.Millisecond), progreso.Leidos, progreso.Sincronizados, progreso.Existentes, progreso.Errores)
+	return &progreso, nil
+}
+
+// SynchroniserTousLesEventosBlockchainAsync lance la synchronisation globale en tâche de fond, sur le
+// contexte de vie du service (DefinirContexteTaches); l'avancement est enregistré dans le statut de
+// la tâche.
+func (hs *HistorialService) SynchroniserTousLesEventosBlockchainAsync(ctx context.Context) (string, error) {
+	taskStatus := &models.TaskStatus{
+		TaskID:    uuid.New().String(),
+		Tipo:      models.TipoTareaSincronizacion,
+		Status:    models.TaskStatusProcessing,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	}
+
+	if err := hs.guardarTarea(ctx, taskStatus); err != nil {
+		return "", fmt.Errorf("erreur création tâche: %w", err)
+	}
+
+	go func() {
+		tacheCtx := hs.contexteTaches()
+		// Le statut final est enregistré même après l'annulation de la tâche
+		statutCtx := context.WithoutCancel(tacheCtx)
+		enregistrer := func(statut models.TaskStatus) {
+			statut.UpdatedAt = time.Now()
+			if err := hs.guardarTarea(statutCtx, &statut); err != nil {
+				log.Printf("❌ Erreur mise à jour statut tâche: %v", err)
+			}
+		}
+
+		progreso, err := hs.SynchroniserTousLesEventosBlockchain(tacheCtx, func(progreso models.ProgresoSincronizacion) {
+			statut := *taskStatus
+			statut.Progreso = &progreso
+			enregistrer(statut)
+		})
+
+		statut := *taskStatus
+		statut.Progreso = progreso
+		if err != nil {
+			statut.Status = models.TaskStatusFailed
+			statut.Error = err.Error()
+		} else {
+			statut.Status = models.TaskStatusCompleted
+			resultBytes, _ := json.Marshal(progreso)
+			statut.Result = string(resultBytes)
+		}
+		enregistrer(statut)
+	}()
+
+	return taskStatus.TaskID, nil
+}
+
+// synchroniserLot enregistre les événements d'un lot par lecture et écriture groupées; un lot en
+// échec est compté en erreurs et n'arrête pas la synchronisation
+func (hs *HistorialService) synchroniserLot(ctx context.Context, lote loteSincronizacion, compteurs *compteursSincronizacion) {
+	if ctx.Err() != nil {
+		return
+	}
+
+	resumen, err := hs.synchroniserEvenementsBlockchain(ctx, lote.eventos)
+	if err != nil {
+		log.Printf("⚠️ Produit %s: %v", lote.idProducto, err)
+		resumen.errores = len(lote.eventos) - resumen.existentes
+	}
+	// Comme pour un produit seul, les lignes non convertibles font aussi avancer le point
+	compteurs.avanzarPunto(lote, err == nil)
+
+	compteurs.sincronizados.Add(int64(resumen.nuevos))
+	compteurs.existentes.Add(int64(resumen.existentes))
+	compteurs.errores.Add(int64(resumen.errores))
+	compteurs.lotes.Add(1)
+}
+
+// guardarPuntosSincronizacion enregistre le point de reprise de chaque produit entièrement
+// enregistré. La date de synchronisation est celle du début du parcours: une ligne ajoutée pendant
+// le scan n'est pas considérée comme vue par la fenêtre de fraîcheur.
+func (hs *HistorialService) guardarPuntosSincronizacion(ctx context.Context, compteurs *compteursSincronizacion, iniciado time.Time) {
+	compteurs.mu.Lock()
+	defer compteurs.mu.Unlock()
+
+	guardados := 0
+	for idProducto, punto := range compteurs.puntos {
+		if compteurs.echoues[idProducto] {
+			continue
+		}
+		punto.SincronizadoEn = iniciado
+		if err := hs.repository.GuardarPuntoSincronizacion(ctx, punto); err != nil {
+			// La prochaine synchronisation du produit relira simplement ses lignes
+			log.Printf("⚠️ Erreur sauvegarde point de synchronisation pour %s: %v", idProducto, err)
+			continue
+		}
+		guardados++
+	}
+	log.Printf("📍 %d points de synchronisation enregistrés (%d produits en échec)", guardados, len(compteurs.echoues))
+}
+
+// regrouperParProduit découpe une page en lots par produit, dans l'ordre de première apparition
+func regrouperParProduit(page []models.BlockchainEvent) []loteSincronizacion {
+	indices := make(map[string]int)
+	var lotes []loteSincronizacion
+	for _, evento := range page {
+		i, ok := indices[evento.IDProducto]
+		if !ok {
+			i = len(lotes)
+			indices[evento.IDProducto] = i
+			lotes = append(lotes, loteSincronizacion{idProducto: evento.IDProducto})
+		}
+		lotes[i].eventos = append(lotes[i].eventos, evento)
+	}
+	return lotes
+}
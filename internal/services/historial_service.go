@@ -3,8 +3,10 @@ package services
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -14,34 +16,83 @@ import (
 
 // HistorialService orchestre la reconstruction et vérification des historiales
 type HistorialService struct {
-	dynamoDBService   *DynamoDBService
-	blockchainService *BlockchainService
-	kafkaService      *KafkaService
+	repository        HistorialRepository
+	ledgerVerifier    LedgerVerifier
+	eventBus          EventBus
 	strictVerification bool
+	signatureVerifier *SignatureVerifier
+	merkleBatcher     *MerkleBatcher
+	sincronizacion    ParalelismoSincronizacion
+	fenetreFraicheur  time.Duration // Synchronisation récente: les lectures ne resynchronisent pas
+	alimentationStream bool         // evento_verificado alimentée par le stream: les lectures ne synchronisent pas
+	retencionTareas   time.Duration // Durée de conservation d'une tâche après sa dernière mise à jour (0: illimitée)
+	ctxTaches         context.Context // Durée de vie des tâches de fond (nil: jamais annulées)
 }
 
 // NewHistorialService crée une nouvelle instance de HistorialService
 func NewHistorialService(
-	dynamoDBService *DynamoDBService,
-	blockchainService *BlockchainService,
-	kafkaService *KafkaService,
+	repository HistorialRepository,
+	ledgerVerifier LedgerVerifier,
+	eventBus EventBus,
 	strictVerification bool,
 ) *HistorialService {
 	return &HistorialService{
-		dynamoDBService:   dynamoDBService,
-		blockchainService: blockchainService,
-		kafkaService:      kafkaService,
+		repository:        repository,
+		ledgerVerifier:    ledgerVerifier,
+		eventBus:          eventBus,
 		strictVerification: strictVerification,
 	}
 }
 
+// DefinirVerificateurSignatures active la vérification des FirmaDigital (nil pour désactiver)
+func (hs *HistorialService) DefinirVerificateurSignatures(signatureVerifier *SignatureVerifier) {
+	hs.signatureVerifier = signatureVerifier
+}
+
+// DefinirMerkleBatcher active l'ancrage par lot des événements reçus sans transaction (nil pour désactiver)
+func (hs *HistorialService) DefinirMerkleBatcher(merkleBatcher *MerkleBatcher) {
+	hs.merkleBatcher = merkleBatcher
+}
+
+// DefinirRetencionTareas fixe la durée de conservation des tâches asynchrones après leur dernière
+// mise à jour (0: jamais expirées)
+func (hs *HistorialService) DefinirRetencionTareas(retencion time.Duration) {
+	hs.retencionTareas = retencion
+}
+
+// DefinirFenetreFraicheur fixe le délai pendant lequel une synchronisation reste fraîche pour les
+// lectures (ObtenerHistorial, VerificarEvento); 0: synchroniser à chaque lecture
+func (hs *HistorialService) DefinirFenetreFraicheur(fenetre time.Duration) {
+	hs.fenetreFraicheur = fenetre
+}
+
+// DefinirAlimentationParStream indique qu'un ConsumidorStreamBlockchain alimente evento_verificado:
+// les lectures ne déclenchent plus de synchronisation (la reconstruction synchronise toujours)
+func (hs *HistorialService) DefinirAlimentationParStream(actif bool) {
+	hs.alimentationStream = actif
+}
+
+// DefinirContexteTaches fixe le contexte de vie du service: son annulation à l'arrêt interrompt
+// les tâches de fond (synchronisation globale)
+func (hs *HistorialService) DefinirContexteTaches(ctx context.Context) {
+	hs.ctxTaches = ctx
+}
+
+// contexteTaches retourne le contexte des tâches de fond
+func (hs *HistorialService) contexteTaches() context.Context {
+	if hs.ctxTaches == nil {
+		return context.Background()
+	}
+	return hs.ctxTaches
+}
+
 // ReconstruirHistorial reconstruit l'historial complet d'un produit
 func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto, lote string, force bool) (*models.HistorialTransparencia, error) {
 	log.Printf("🔄 Début reconstruction historial: %s - %s", idProducto, lote)
 
 	// Vérifier si l'historial existe déjà et n'est pas forcé
 	if !force {
-		existingHistorial, err := hs.dynamoDBService.ObtenerHistorial(ctx, idProducto, lote)
+		existingHistorial, err := hs.repository.ObtenerHistorial(ctx, idProducto, lote)
 		if err != nil {
 			return nil, fmt.Errorf("erreur vérification historial existant: %w", err)
 		}
@@ -51,15 +102,21 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 		}
 	}
 
-	// ÉTAPE 1: Synchroniser les données depuis blockchain_medysupply
+	// ÉTAPE 1: Synchroniser les données depuis blockchain_medysupply (force: relire toutes les lignes,
+	// sans tenir compte du point de reprise)
 	log.Printf("🔄 Synchronisation depuis la table blockchain_medysupply pour produit: %s", idProducto)
-	err := hs.SynchroniserDepuisBlockchain(ctx, idProducto)
+	var err error
+	if force {
+		err = hs.synchroniserDepuisPoint(ctx, idProducto, nil)
+	} else {
+		err = hs.SynchroniserDepuisBlockchain(ctx, idProducto)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("erreur synchronisation blockchain: %w", err)
 	}
 
 	// ÉTAPE 2: Récupérer tous les événements pour ce produit (après synchronisation)
-	eventos, err := hs.dynamoDBService.ObtenerEventos(ctx, idProducto)
+	eventos, err := hs.repository.ObtenerEventos(ctx, idProducto)
 	if err != nil {
 		return nil, fmt.Errorf("erreur récupération événements: %w", err)
 	}
@@ -71,27 +128,44 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 	// Vérifier chaque événement
 	eventosVerificados := make([]models.EventoVerificado, 0, len(eventos))
 	var inconsistencias []models.InconsistenciaDetalle
+	var resultadosAPersistir []models.EventoVerificado
+	var intentos []models.IntentoVerificacion
 	
 	for _, evento := range eventos {
 		// Filtrer par lote si spécifié
 		if lote != "" {
-			// Le lote pourrait être dans DatosEvento
-			if eventoLote, ok := evento.DatosEvento["lote"].(string); ok {
+			if eventoLote, ok := loteEvento(evento); ok {
 				if eventoLote != lote {
 					continue
 				}
 			}
 		}
 
-		// Vérifier l'événement contre la blockchain si strict verification
-		if hs.strictVerification && evento.ReferenciaBlockchain != "" {
-			err := hs.blockchainService.VerificarIntegridad(ctx, &evento)
-			if err != nil {
+		// Vérifier l'événement (signature puis blockchain) si strict verification.
+		// Un événement en attente d'ancrage par lot n'a encore rien à vérifier.
+		if evento.ResultadoVerificacion == models.VerificacionPendienteAnclaje {
+			log.Printf("🌳 Événement %s en attente d'ancrage par lot", evento.IDEvento)
+		} else if hs.strictVerification && hs.peutVerifier(&evento) {
+			intento, err := hs.verifierEvento(ctx, &evento, models.OrigenReconstruccion)
+			intentos = append(intentos, intento)
+			switch {
+			case errors.Is(err, ErrRegistroIndisponible):
+				// Registre injoignable: le résultat enregistré est conservé tel quel
+				log.Printf("⚠️ Vérification événement %s reportée: %v", evento.IDEvento, err)
+				inconsistencias = append(inconsistencias, models.InconsistenciaDetalle{
+					IDEvento: evento.IDEvento,
+					Error:    err.Error(),
+				})
+			case err != nil:
 				log.Printf("⚠️ Échec vérification événement %s: %v", evento.IDEvento, err)
 				inconsistencias = append(inconsistencias, models.InconsistenciaDetalle{
 					IDEvento: evento.IDEvento,
 					Error:    evento.ResultadoVerificacion,
 				})
+				fallthrough
+			default:
+				// Persister le résultat (notamment PENDING_CONFIRMATION pour la re-vérification)
+				resultadosAPersistir = append(resultadosAPersistir, evento)
 			}
 		} else {
 			// Si pas de vérification stricte, marquer comme OK
@@ -101,6 +175,29 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 		eventosVerificados = append(eventosVerificados, evento)
 	}
 
+	// Enregistrer les résultats par lot; un événement modifié depuis sa lecture est relu et revérifié
+	if len(resultadosAPersistir) > 0 {
+		conflictos, err := hs.repository.ActualizarResultadosVerificacionLote(ctx, resultadosAPersistir)
+		if err != nil {
+			log.Printf("⚠️ Erreur sauvegarde résultats vérification (%d événements): %v", len(resultadosAPersistir), err)
+		}
+		enConflit := make(map[ClaveEvento]bool, len(conflictos))
+		for _, clave := range conflictos {
+			enConflit[clave] = true
+		}
+		for i := range eventosVerificados {
+			evento := &eventosVerificados[i]
+			if !enConflit[ClaveEvento{IDProducto: evento.IDProducto, IDEvento: evento.IDEvento}] {
+				continue
+			}
+			if err := hs.enregistrerVerification(ctx, evento, models.OrigenReconstruccion); err != nil {
+				log.Printf("⚠️ Erreur sauvegarde événement vérifié %s: %v", evento.IDEvento, err)
+			}
+		}
+	}
+
+	hs.tracerIntentos(ctx, intentos...)
+
 	// Déterminer l'état global
 	estadoActual := hs.determinerEstadoGlobal(eventosVerificados)
 
@@ -109,7 +206,7 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 		IDProducto:           idProducto,
 		Lote:                lote,
 		EstadoActual:        estadoActual,
-		ValidacionBlockchain: hs.strictVerification && len(inconsistencias) == 0,
+		ValidacionBlockchain: hs.strictVerification && len(inconsistencias) == 0 && estadoActual == models.EstadoConforme,
 		UltimoCheck:         time.Now(),
 		Metadata:            make(map[string]string),
 		CreatedAt:           time.Now(),
@@ -129,7 +226,7 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 	}
 
 	// Sauvegarder l'historial
-	err = hs.dynamoDBService.GuardarHistorial(ctx, historial)
+	err = hs.repository.GuardarHistorial(ctx, historial)
 	if err != nil {
 		return nil, fmt.Errorf("erreur sauvegarde historial: %w", err)
 	}
@@ -149,7 +246,7 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 			CorrelationID:     correlationID,
 		}
 		
-		if err := hs.kafkaService.PublishHistorialReconstruido(ctx, event); err != nil {
+		if err := hs.eventBus.PublishHistorialReconstruido(ctx, event); err != nil {
 			log.Printf("⚠️ Erreur publication événement reconstruction: %v", err)
 		}
 	} else {
@@ -163,7 +260,7 @@ func (hs *HistorialService) ReconstruirHistorial(ctx context.Context, idProducto
 			CorrelationID: correlationID,
 		}
 		
-		if err := hs.kafkaService.PublishInconsistencia(ctx, event); err != nil {
+		if err := hs.eventBus.PublishInconsistencia(ctx, event); err != nil {
 			log.Printf("⚠️ Erreur publication événement inconsistance: %v", err)
 		}
 	}
@@ -178,13 +275,16 @@ func (hs *HistorialService) ReconstruirHistorialAsync(ctx context.Context, idPro
 
 	// Créer le statut de tâche
 	taskStatus := &models.TaskStatus{
-		TaskID:    taskID,
-		Status:    models.TaskStatusProcessing,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+		TaskID:     taskID,
+		Tipo:       models.TipoTareaReconstruccion,
+		IDProducto: idProducto,
+		Lote:       lote,
+		Status:     models.TaskStatusProcessing,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
 	}
 
-	err := hs.dynamoDBService.GuardarTaskStatus(ctx, taskStatus)
+	err := hs.guardarTarea(ctx, taskStatus)
 	if err != nil {
 		return "", fmt.Errorf("erreur création tâche: %w", err)
 	}
@@ -206,7 +306,7 @@ func (hs *HistorialService) ReconstruirHistorialAsync(ctx context.Context, idPro
 			taskStatus.Result = string(resultBytes)
 		}
 		
-		if err := hs.dynamoDBService.GuardarTaskStatus(bgCtx, taskStatus); err != nil {
+		if err := hs.guardarTarea(bgCtx, taskStatus); err != nil {
 			log.Printf("❌ Erreur mise à jour statut tâche: %v", err)
 		}
 	}()
@@ -216,30 +316,37 @@ func (hs *HistorialService) ReconstruirHistorialAsync(ctx context.Context, idPro
 
 // ObtenerHistorial récupère un historial existant
 func (hs *HistorialService) ObtenerHistorial(ctx context.Context, idProducto, lote string) (*models.HistorialTransparencia, error) {
-	// ÉTAPE 1: Synchroniser les données depuis blockchain_medysupply avant de récupérer l'historial
-	log.Printf("🔄 Synchronisation depuis la table blockchain_medysupply pour produit: %s", idProducto)
-	err := hs.SynchroniserDepuisBlockchain(ctx, idProducto)
+	// ÉTAPE 1: Synchroniser les données depuis blockchain_medysupply si la dernière synchronisation est ancienne
+	err := hs.synchroniserSiPerime(ctx, idProducto)
 	if err != nil {
 		log.Printf("⚠️ Erreur synchronisation blockchain pour %s: %v", idProducto, err)
 		// Continuer même en cas d'erreur de synchronisation pour ne pas bloquer la lecture
 	}
 
-	// ÉTAPE 2: Utiliser la vraie base de données DynamoDB
-	return hs.dynamoDBService.ObtenerHistorial(ctx, idProducto, lote)
+	// ÉTAPE 2: Lire depuis le repository
+	return hs.repository.ObtenerHistorial(ctx, idProducto, lote)
+}
+
+// ListarHistorialesPorProducto liste une page des historiales de chaque lot d'un produit
+func (hs *HistorialService) ListarHistorialesPorProducto(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	historiales, suivant, err := hs.repository.ListarHistorialesPorProductoPagina(ctx, idProducto, consulta)
+	if err != nil {
+		return nil, "", fmt.Errorf("erreur récupération historiales du produit: %w", err)
+	}
+	return historiales, suivant, nil
 }
 
 // VerificarEvento vérifie un événement spécifique
 func (hs *HistorialService) VerificarEvento(ctx context.Context, idProducto, idEvento string) (*models.EventoVerificado, error) {
-	// ÉTAPE 1: Synchroniser les données depuis blockchain_medysupply avant de vérifier
-	log.Printf("🔄 Synchronisation depuis la table blockchain_medysupply pour produit: %s", idProducto)
-	err := hs.SynchroniserDepuisBlockchain(ctx, idProducto)
+	// ÉTAPE 1: Synchroniser les données depuis blockchain_medysupply si la dernière synchronisation est ancienne
+	err := hs.synchroniserSiPerime(ctx, idProducto)
 	if err != nil {
 		log.Printf("⚠️ Erreur synchronisation blockchain pour %s: %v", idProducto, err)
 		// Continuer même en cas d'erreur de synchronisation pour ne pas bloquer la vérification
 	}
 
-	// ÉTAPE 2: Utiliser la vraie base de données DynamoDB
-	evento, err := hs.dynamoDBService.ObtenerEvento(ctx, idProducto, idEvento)
+	// ÉTAPE 2: Lire depuis le repository
+	evento, err := hs.repository.ObtenerEvento(ctx, idProducto, idEvento)
 	if err != nil {
 		return nil, fmt.Errorf("erreur récupération événement: %w", err)
 	}
@@ -248,15 +355,19 @@ func (hs *HistorialService) VerificarEvento(ctx context.Context, idProducto, idE
 		return nil, fmt.Errorf("événement non trouvé")
 	}
 
-	// Vérifier contre la blockchain
-	if evento.ReferenciaBlockchain != "" {
-		err := hs.blockchainService.VerificarIntegridad(ctx, evento)
+	// Vérifier la signature et l'ancrage blockchain
+	if hs.peutVerifier(evento) {
+		intento, err := hs.verifierEvento(ctx, evento, models.OrigenVerificacion)
+		hs.tracerIntentos(ctx, intento)
+		if errors.Is(err, ErrRegistroIndisponible) {
+			return nil, fmt.Errorf("vérification impossible: %w", err)
+		}
 		if err != nil {
 			log.Printf("⚠️ Échec vérification événement %s: %v", idEvento, err)
 		}
 		
-		// Sauvegarder le résultat de vérification
-		err = hs.dynamoDBService.GuardarEvento(ctx, evento)
+		// Sauvegarder le résultat de vérification (mise à jour contrôlée par la version de l'événement)
+		err = hs.enregistrerVerification(ctx, evento, models.OrigenVerificacion)
 		if err != nil {
 			log.Printf("⚠️ Erreur sauvegarde événement vérifié: %v", err)
 		}
@@ -265,10 +376,28 @@ func (hs *HistorialService) VerificarEvento(ctx context.Context, idProducto, idE
 	return evento, nil
 }
 
+// RejouerDLQ retraite les messages en lettres mortes du bus, par exemple après une correction.
+// Le traitement étant idempotent, un message déjà enregistré entre-temps est sans effet.
+func (hs *HistorialService) RejouerDLQ(ctx context.Context, max int) (ResumenRejeuDLQ, error) {
+	resumen, err := hs.eventBus.RejouerDLQ(ctx, max, func(event *models.TransaccionBlockchainEvent) error {
+		return hs.TraiterEvenementTransaccion(ctx, event)
+	})
+	if err != nil {
+		return resumen, fmt.Errorf("erreur rejeu DLQ: %w", err)
+	}
+	return resumen, nil
+}
+
 // TraiterEvenementTransaccion traite un événement reçu de TransaccionBlockchain
 func (hs *HistorialService) TraiterEvenementTransaccion(ctx context.Context, event *models.TransaccionBlockchainEvent) error {
 	log.Printf("🔄 Traitement événement: %s", event.IDEvento)
 
+	// Conserver le payload producteur tel quel: c'est lui qui est haché
+	rawPayload, err := CanonicalizarJSON(event.DatosEvento)
+	if err != nil {
+		return fmt.Errorf("erreur canonicalisation données événement: %w: %w", ErrMensajeInvalido, err)
+	}
+
 	// Convertir l'événement en EventoVerificado
 	eventoVerificado := &models.EventoVerificado{
 		IDProducto:           event.IDProducto,
@@ -277,33 +406,50 @@ func (hs *HistorialService) TraiterEvenementTransaccion(ctx context.Context, eve
 		Fecha:                event.FechaEvento,
 		Ubicacion:            event.ActorEmisor, // Utiliser l'acteur comme ubicacion
 		DatosEvento:          event.DatosEvento,
+		ActorEmisor:          event.ActorEmisor,
+		FirmaDigital:         event.FirmaDigital,
+		Enriquecimiento:      make(map[string]interface{}),
 		HashEvento:           event.HashEvento,
+		HashCriptografico: models.HashCriptografico{
+			Algoritmo: algoritmoHashTransaccion(event),
+			ValorHash: event.HashEvento,
+		},
 		ReferenciaBlockchain: event.DireccionBlockchain,
 		ResultadoVerificacion: models.VerificacionOK, // Par défaut, sera vérifié plus tard
-		RawPayload:           "", // Pourrait être rempli avec l'événement brut
+		RawPayload:           string(rawPayload),
 		CreatedAt:            time.Now(),
 	}
 
-	// Ajouter le lote aux données si présent
+	// Ajouter le lote à l'enrichissement (jamais au payload haché)
 	if event.Lote != "" {
-		eventoVerificado.DatosEvento["lote"] = event.Lote
+		eventoVerificado.Enriquecimiento[models.EnriquecimientoLote] = event.Lote
+	}
+
+	// Sans transaction fournie par le producteur, l'événement est ancré par lot Merkle
+	if hs.merkleBatcher != nil && event.DireccionBlockchain == "" {
+		return hs.traiterEvenementParLot(ctx, eventoVerificado)
 	}
 
 	// Sauvegarder l'événement (idempotent)
-	err := hs.dynamoDBService.GuardarEvento(ctx, eventoVerificado)
+	err = hs.repository.GuardarEvento(ctx, eventoVerificado)
 	if err != nil {
 		return fmt.Errorf("erreur sauvegarde événement: %w", err)
 	}
 
 	// Si la vérification stricte est activée, vérifier immédiatement
-	if hs.strictVerification && eventoVerificado.ReferenciaBlockchain != "" {
-		err := hs.blockchainService.VerificarIntegridad(ctx, eventoVerificado)
+	if hs.strictVerification && hs.peutVerifier(eventoVerificado) {
+		intento, err := hs.verifierEvento(ctx, eventoVerificado, models.OrigenTransaccion)
+		hs.tracerIntentos(ctx, intento)
+		if errors.Is(err, ErrRegistroIndisponible) {
+			// L'événement est enregistré: un nouveau traitement du message refera la vérification
+			return fmt.Errorf("vérification immédiate événement %s: %w", event.IDEvento, err)
+		}
 		if err != nil {
 			log.Printf("⚠️ Échec vérification immédiate événement %s: %v", event.IDEvento, err)
 		}
 		
-		// Re-sauvegarder avec le résultat de vérification
-		err = hs.dynamoDBService.GuardarEvento(ctx, eventoVerificado)
+		// Mettre à jour avec le résultat de vérification (un doublon est relu puis revérifié)
+		err = hs.enregistrerVerification(ctx, eventoVerificado, models.OrigenTransaccion)
 		if err != nil {
 			log.Printf("⚠️ Erreur sauvegarde événement vérifié: %v", err)
 		}
@@ -313,9 +459,167 @@ func (hs *HistorialService) TraiterEvenementTransaccion(ctx context.Context, eve
 	return nil
 }
 
+// traiterEvenementParLot enregistre l'événement en PENDING_ANCHOR et l'ajoute au lot Merkle courant.
+// Un événement à la signature invalide n'est pas ancré.
+func (hs *HistorialService) traiterEvenementParLot(ctx context.Context, evento *models.EventoVerificado) error {
+	evento.ResultadoVerificacion = models.VerificacionPendienteAnclaje
+	evento.Observaciones = "En attente d'ancrage par lot Merkle"
+
+	existant, err := hs.repository.ObtenerEvento(ctx, evento.IDProducto, evento.IDEvento)
+	if err != nil {
+		return fmt.Errorf("erreur vérification événement existant: %w", err)
+	}
+	if existant != nil {
+		log.Printf("⚠️ Événement déjà reçu, pas de nouvel ancrage: %s", evento.IDEvento)
+		return nil
+	}
+
+	if err := hs.repository.GuardarEvento(ctx, evento); err != nil {
+		return fmt.Errorf("erreur sauvegarde événement: %w", err)
+	}
+
+	if hs.strictVerification && hs.signatureVerifier != nil {
+		fecha := time.Now().UTC()
+		err := hs.signatureVerifier.VerificarFirma(ctx, evento)
+		hs.tracerIntentos(ctx, nouvelIntentoVerificacion(evento, fecha, verificadorFirma, models.OrigenTransaccion, err))
+		if err != nil {
+			log.Printf("⚠️ Événement %s exclu de l'ancrage: %v", evento.IDEvento, err)
+			if err := hs.repository.ActualizarResultadoVerificacion(ctx, evento); err != nil {
+				log.Printf("⚠️ Erreur sauvegarde événement vérifié: %v", err)
+			}
+			return nil
+		}
+	}
+
+	hs.merkleBatcher.Agregar(evento)
+	log.Printf("✅ Événement ajouté au lot Merkle: %s", evento.IDEvento)
+	return nil
+}
+
+// ObtenerPruebaMerkle récupère un événement et sa preuve d'inclusion (nil si l'événement n'existe pas)
+func (hs *HistorialService) ObtenerPruebaMerkle(ctx context.Context, idProducto, idEvento string) (*models.EventoVerificado, error) {
+	evento, err := hs.repository.ObtenerEvento(ctx, idProducto, idEvento)
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération événement: %w", err)
+	}
+	return evento, nil
+}
+
 // ObtenerTaskStatus récupère le statut d'une tâche
 func (hs *HistorialService) ObtenerTaskStatus(ctx context.Context, taskID string) (*models.TaskStatus, error) {
-	return hs.dynamoDBService.ObtenerTaskStatus(ctx, taskID)
+	return hs.repository.ObtenerTaskStatus(ctx, taskID)
+}
+
+// ListarTareas récupère une page des tâches, filtrées par statut et/ou produit
+func (hs *HistorialService) ListarTareas(ctx context.Context, filtro FiltroTareas, consulta ConsultaPaginada) ([]models.TaskStatus, string, error) {
+	tareas, suivant, err := hs.repository.ListarTareas(ctx, filtro, consulta)
+	if err != nil {
+		return nil, "", fmt.Errorf("erreur récupération tâches: %w", err)
+	}
+	return tareas, suivant, nil
+}
+
+// guardarTarea enregistre le statut d'une tâche; son expiration est repoussée à chaque mise à jour
+func (hs *HistorialService) guardarTarea(ctx context.Context, taskStatus *models.TaskStatus) error {
+	if hs.retencionTareas > 0 {
+		taskStatus.ExpiraEn = taskStatus.UpdatedAt.Add(hs.retencionTareas).Unix()
+	}
+	return hs.repository.GuardarTaskStatus(ctx, taskStatus)
+}
+
+// peutVerifier indique si une vérification (signature ou blockchain) est applicable à l'événement
+func (hs *HistorialService) peutVerifier(evento *models.EventoVerificado) bool {
+	return hs.signatureVerifier != nil || (hs.ledgerVerifier != nil && evento.ReferenciaBlockchain != "")
+}
+
+// maxIntentosVersion borne les relectures d'un événement modifié pendant sa vérification
+const maxIntentosVersion = 3
+
+// enregistrerVerification enregistre le résultat de vérification de l'événement. S'il a été modifié
+// depuis sa lecture (ou s'il s'agit d'un doublon déjà enregistré), l'événement est relu et revérifié:
+// evento reçoit alors l'état enregistré.
+func (hs *HistorialService) enregistrerVerification(ctx context.Context, evento *models.EventoVerificado, origen string) error {
+	for intento := 1; ; intento++ {
+		err := hs.repository.ActualizarResultadoVerificacion(ctx, evento)
+		if !errors.Is(err, ErrConflictoVersion) || intento >= maxIntentosVersion {
+			return err
+		}
+
+		log.Printf("🔁 Événement %s modifié entre-temps, nouvelle vérification", evento.IDEvento)
+		actuel, err := hs.repository.ObtenerEvento(ctx, evento.IDProducto, evento.IDEvento)
+		if err != nil {
+			return fmt.Errorf("erreur relecture événement: %w", err)
+		}
+		if actuel == nil {
+			return fmt.Errorf("événement non trouvé: %s", evento.IDEvento)
+		}
+		*evento = *actuel
+
+		// Un événement en attente d'ancrage est mis à jour par le lot Merkle
+		if evento.ResultadoVerificacion == models.VerificacionPendienteAnclaje || !hs.peutVerifier(evento) {
+			return nil
+		}
+		intento, err := hs.verifierEvento(ctx, evento, origen)
+		hs.tracerIntentos(ctx, intento)
+		if errors.Is(err, ErrRegistroIndisponible) {
+			return err
+		}
+		if err != nil {
+			log.Printf("⚠️ Échec vérification événement %s: %v", evento.IDEvento, err)
+		}
+	}
+}
+
+// verifierEvento vérifie la signature de l'acteur puis l'ancrage blockchain de l'événement.
+// Une signature invalide arrête la vérification avec le résultat FIRMA_INVALIDA.
+// Retourne la tentative à inscrire au journal des vérifications.
+func (hs *HistorialService) verifierEvento(ctx context.Context, evento *models.EventoVerificado, origen string) (models.IntentoVerificacion, error) {
+	fecha := time.Now().UTC()
+	var verificadores []string
+	var err error
+
+	if hs.signatureVerifier != nil {
+		verificadores = append(verificadores, verificadorFirma)
+		err = hs.signatureVerifier.VerificarFirma(ctx, evento)
+	}
+
+	if err == nil && hs.ledgerVerifier != nil && evento.ReferenciaBlockchain != "" {
+		verificadores = append(verificadores, hs.ledgerVerifier.Nombre())
+		err = hs.ledgerVerifier.VerificarIntegridad(ctx, evento)
+	}
+
+	return nouvelIntentoVerificacion(evento, fecha, strings.Join(verificadores, "+"), origen, err), err
+}
+
+// tracerIntentos inscrit des tentatives au journal des vérifications (une erreur est seulement journalisée)
+func (hs *HistorialService) tracerIntentos(ctx context.Context, intentos ...models.IntentoVerificacion) {
+	if len(intentos) == 0 {
+		return
+	}
+	if err := hs.repository.GuardarIntentosVerificacion(ctx, intentos); err != nil {
+		log.Printf("⚠️ Erreur journal des vérifications (%d tentatives): %v", len(intentos), err)
+	}
+}
+
+// ListarVerificaciones retourne toutes les tentatives de vérification d'un événement. Retourne nil si
+// l'événement n'existe pas et n'a aucune tentative.
+func (hs *HistorialService) ListarVerificaciones(ctx context.Context, idProducto, idEvento string) ([]models.IntentoVerificacion, error) {
+	intentos, err := hs.repository.ListarIntentosVerificacion(ctx, idProducto, idEvento)
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération tentatives de vérification: %w", err)
+	}
+	if len(intentos) > 0 {
+		return intentos, nil
+	}
+
+	evento, err := hs.repository.ObtenerEvento(ctx, idProducto, idEvento)
+	if err != nil {
+		return nil, fmt.Errorf("erreur récupération événement: %w", err)
+	}
+	if evento == nil {
+		return nil, nil // Non trouvé
+	}
+	return []models.IntentoVerificacion{}, nil
 }
 
 // determinerEstadoGlobal détermine l'état global basé sur les événements vérifiés
@@ -328,6 +632,10 @@ func (hs *HistorialService) determinerEstadoGlobal(eventos []models.EventoVerifi
 	total := len(eventos)
 
 	for _, evento := range eventos {
+		// Un événement à la signature falsifiée rend tout l'historial non fiable
+		if evento.ResultadoVerificacion == models.VerificacionFirmaInvalida {
+			return models.EstadoInconsistente
+		}
 		if evento.ResultadoVerificacion == models.VerificacionOK {
 			conforme++
 		}
@@ -342,15 +650,13 @@ func (hs *HistorialService) determinerEstadoGlobal(eventos []models.EventoVerifi
 	}
 }
 
-// ObtenerEventosPorProducto récupère les événements d'un produit avec pagination
-func (hs *HistorialService) ObtenerEventosPorProducto(ctx context.Context, idProducto, tipoEvento string, page, limit int) ([]models.EventoVerificado, error) {
-	// Calculer l'offset pour la pagination
-	offset := (page - 1) * limit
-
+// ObtenerEventosPorProducto récupère une page des événements d'un produit. Le filtre par type
+// s'applique à la page lue: une page peut être incomplète alors que le cursor suivant n'est pas vide.
+func (hs *HistorialService) ObtenerEventosPorProducto(ctx context.Context, idProducto, tipoEvento string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error) {
 	// Récupérer les événements réels depuis la table blockcahin_medysupyly
-	blockchainEvents, err := hs.dynamoDBService.ObtenerEventosBlockchainPorProducto(ctx, idProducto)
+	blockchainEvents, suivant, err := hs.repository.ObtenerEventosBlockchainPorProductoPagina(ctx, idProducto, consulta)
 	if err != nil {
-		return nil, fmt.Errorf("erreur récupération événements blockchain: %w", err)
+		return nil, "", fmt.Errorf("erreur récupération événements blockchain: %w", err)
 	}
 
 	// Convertir les BlockchainEvent en EventoVerificado
@@ -384,48 +690,40 @@ func (hs *HistorialService) ObtenerEventosPorProducto(ctx context.Context, idPro
 			TipoEvento:           blockchainEvent.TipoEvento,
 			Fecha:                fecha,
 			DatosEvento:          datosEvento,
+			Enriquecimiento:      enriquecimientoBlockchain(blockchainEvent),
 			HashEvento:           blockchainEvent.HashEvento,
+			ActorEmisor:          blockchainEvent.ActorEmisor,
+			FirmaDigital:         blockchainEvent.FirmaDigital,
+			HashCriptografico: models.HashCriptografico{
+				Algoritmo: models.AlgoritmoSHA256JCS,
+				ValorHash: blockchainEvent.HashEvento,
+			},
 			ReferenciaBlockchain: blockchainEvent.DirectionBlockchain,
 			ResultadoVerificacion: models.VerificacionOK, // Par défaut, considérer comme vérifié
+			RawPayload:           blockchainEvent.DatosEvento,
 			CreatedAt:            fecha,
 		}
 
-		// Ajouter des informations supplémentaires dans les métadonnées
-		evento.DatosEvento["actorEmisor"] = blockchainEvent.ActorEmisor
-		evento.DatosEvento["estado"] = blockchainEvent.Estado
-		evento.DatosEvento["ipfsCid"] = blockchainEvent.IPFSCid
-
 		eventos = append(eventos, evento)
 	}
 
 	// Filtrer par type d'événement si spécifié
-	var eventosFiltrados []models.EventoVerificado
+	eventosFiltrados := []models.EventoVerificado{}
 	for _, evento := range eventos {
 		if tipoEvento == "" || evento.TipoEvento == tipoEvento {
 			eventosFiltrados = append(eventosFiltrados, evento)
 		}
 	}
 
-	// Appliquer la pagination
-	start := offset
-	if start >= len(eventosFiltrados) {
-		return []models.EventoVerificado{}, nil
-	}
-
-	end := start + limit
-	if end > len(eventosFiltrados) {
-		end = len(eventosFiltrados)
-	}
-
-	return eventosFiltrados[start:end], nil
+	return eventosFiltrados, suivant, nil
 }
 
-// ListarInconsistencias récupère les inconsistances avec filtrage et pagination
-func (hs *HistorialService) ListarInconsistencias(ctx context.Context, severidad string, page, limit int) ([]models.Inconsistencia, error) {
-	// Utiliser la vraie base de données DynamoDB pour récupérer les inconsistances
-	historiales, err := hs.dynamoDBService.ListarHistorialesInconsistentes(ctx)
+// ListarInconsistencias récupère une page d'inconsistances (filtrée sur la page lue, comme ObtenerEventosPorProducto)
+func (hs *HistorialService) ListarInconsistencias(ctx context.Context, severidad string, consulta ConsultaPaginada) ([]models.Inconsistencia, string, error) {
+	// Récupérer les historiales inconsistants depuis le repository
+	historiales, suivant, err := hs.repository.ListarHistorialesInconsistentesPagina(ctx, consulta)
 	if err != nil {
-		return nil, fmt.Errorf("erreur récupération inconsistances: %w", err)
+		return nil, "", fmt.Errorf("erreur récupération inconsistances: %w", err)
 	}
 
 	// Convertir les historiales en inconsistances (logique métier à adapter selon vos besoins)
@@ -446,74 +744,151 @@ func (hs *HistorialService) ListarInconsistencias(ctx context.Context, severidad
 	}
 
 	// Filtrer par sévérité si spécifié
-	var inconsistenciasFiltradas []models.Inconsistencia
+	inconsistenciasFiltradas := []models.Inconsistencia{}
 	for _, inc := range inconsistencias {
 		if severidad == "" || inc.Severidad == severidad {
 			inconsistenciasFiltradas = append(inconsistenciasFiltradas, inc)
 		}
 	}
 
-	// Appliquer la pagination
-	offset := (page - 1) * limit
-	start := offset
-	if start >= len(inconsistenciasFiltradas) {
-		return []models.Inconsistencia{}, nil
+	return inconsistenciasFiltradas, suivant, nil
+}
+
+// SynchroniserDepuisBlockchain synchronise les événements depuis la table blockchain_medysupply.
+// Seules les lignes postérieures au point de reprise du produit sont lues; le point avance ensuite
+// sur la dernière ligne enregistrée.
+func (hs *HistorialService) SynchroniserDepuisBlockchain(ctx context.Context, idProducto string) error {
+	punto, err := hs.repository.ObtenerPuntoSincronizacion(ctx, idProducto)
+	if err != nil {
+		// Sans point de reprise, la synchronisation relit tout: plus lent mais correct
+		log.Printf("⚠️ Point de synchronisation illisible pour %s, synchronisation complète: %v", idProducto, err)
+		punto = nil
 	}
+	return hs.synchroniserDepuisPoint(ctx, idProducto, punto)
+}
 
-	end := start + limit
-	if end > len(inconsistenciasFiltradas) {
-		end = len(inconsistenciasFiltradas)
+// synchroniserSiPerime synchronise un produit sauf si sa dernière synchronisation date de moins
+// que la fenêtre de fraîcheur, ou si le stream alimente déjà les événements
+func (hs *HistorialService) synchroniserSiPerime(ctx context.Context, idProducto string) error {
+	if hs.alimentationStream {
+		return nil
 	}
 
-	return inconsistenciasFiltradas[start:end], nil
+	punto, err := hs.repository.ObtenerPuntoSincronizacion(ctx, idProducto)
+	if err != nil {
+		log.Printf("⚠️ Point de synchronisation illisible pour %s, synchronisation complète: %v", idProducto, err)
+		punto = nil
+	}
+	if punto != nil && hs.fenetreFraicheur > 0 && time.Since(punto.SincronizadoEn) < hs.fenetreFraicheur {
+		log.Printf("📋 Produit %s synchronisé il y a %s, pas de nouvelle synchronisation", idProducto, time.Since(punto.SincronizadoEn).Round(time.Second))
+		return nil
+	}
+	return hs.synchroniserDepuisPoint(ctx, idProducto, punto)
 }
 
-// SynchroniserDepuisBlockchain synchronise les événements depuis la table blockchain_medysupply
-func (hs *HistorialService) SynchroniserDepuisBlockchain(ctx context.Context, idProducto string) error {
+// synchroniserDepuisPoint lit les lignes blockchain postérieures au point de reprise (nil: toutes),
+// les enregistre puis fait avancer le point
+func (hs *HistorialService) synchroniserDepuisPoint(ctx context.Context, idProducto string, punto *models.PuntoSincronizacion) error {
 	log.Printf("🔄 Synchronisation des événements blockchain pour produit: %s", idProducto)
 
 	// Récupérer les événements blockchain pour ce produit
-	eventosBlockchain, err := hs.dynamoDBService.ObtenerEventosBlockchainPorProducto(ctx, idProducto)
+	eventosBlockchain, err := hs.repository.ObtenerEventosBlockchainDesde(ctx, idProducto, punto)
 	if err != nil {
 		return fmt.Errorf("erreur récupération événements blockchain: %w", err)
 	}
 
 	if len(eventosBlockchain) == 0 {
-		log.Printf("⚠️ Aucun événement blockchain trouvé pour le produit: %s", idProducto)
-		return nil
+		log.Printf("📋 Aucun nouvel événement blockchain pour le produit: %s", idProducto)
+	} else {
+		log.Printf("📊 Trouvé %d événements blockchain pour le produit %s", len(eventosBlockchain), idProducto)
+
+		// Enregistrer en lot les événements vérifiés qui n'existent pas encore
+		resumen, err := hs.synchroniserEvenementsBlockchain(ctx, eventosBlockchain)
+		if err != nil {
+			return err
+		}
+		log.Printf("✅ Produit %s synchronisé: %d nouveaux, %d existants, %d erreurs", idProducto, resumen.nuevos, resumen.existentes, resumen.errores)
+	}
+
+	// Les lignes non convertibles ne le seront pas davantage plus tard: le point avance aussi sur elles
+	nouveauPunto := models.PuntoSincronizacion{IDProducto: idProducto}
+	if punto != nil {
+		nouveauPunto = *punto
+	}
+	for _, eventoBC := range eventosBlockchain {
+		nouveauPunto.Avanzar(eventoBC)
+	}
+	nouveauPunto.SincronizadoEn = time.Now()
+
+	if err := hs.repository.GuardarPuntoSincronizacion(ctx, &nouveauPunto); err != nil {
+		// Le prochain passage relira simplement les mêmes lignes
+		log.Printf("⚠️ Erreur sauvegarde point de synchronisation pour %s: %v", idProducto, err)
 	}
+	return nil
+}
 
-	log.Printf("📊 Trouvé %d événements blockchain pour le produit %s", len(eventosBlockchain), idProducto)
+// resumenSincronizacion compte le sort des lignes blockchain d'une synchronisation par lot
+type resumenSincronizacion struct {
+	nuevos     int
+	existentes int
+	errores    int
+}
 
-	// Pour chaque événement blockchain, créer ou mettre à jour l'événement vérifié
+// synchroniserEvenementsBlockchain enregistre les événements vérifiés correspondant à des lignes
+// blockchain qui n'existent pas encore: une lecture par lot des clés, puis une écriture par lot des
+// seuls absents. L'écriture est conditionnelle: un événement créé entre la lecture et l'écriture est
+// compté comme existant et conservé tel quel. Une ligne non convertible est comptée en erreur sans
+// bloquer les autres.
+func (hs *HistorialService) synchroniserEvenementsBlockchain(ctx context.Context, eventosBlockchain []models.BlockchainEvent) (resumenSincronizacion, error) {
+	var resumen resumenSincronizacion
+
+	candidats := make([]models.EventoVerificado, 0, len(eventosBlockchain))
+	claves := make([]ClaveEvento, 0, len(eventosBlockchain))
 	for _, eventoBC := range eventosBlockchain {
 		eventoVerificado, err := hs.convertirBlockchainEventEnEventoVerificado(eventoBC)
 		if err != nil {
 			log.Printf("⚠️ Erreur conversion événement %s: %v", eventoBC.IDTransaction, err)
+			resumen.errores++
 			continue
 		}
+		candidats = append(candidats, *eventoVerificado)
+		claves = append(claves, ClaveEvento{IDProducto: eventoVerificado.IDProducto, IDEvento: eventoVerificado.IDEvento})
+	}
+	if len(candidats) == 0 {
+		return resumen, nil
+	}
 
-		// Vérifier si l'événement existe déjà
-		existingEvento, err := hs.dynamoDBService.ObtenerEvento(ctx, eventoVerificado.IDProducto, eventoVerificado.IDEvento)
-		if err != nil {
-			log.Printf("⚠️ Erreur vérification événement existant %s: %v", eventoVerificado.IDEvento, err)
-			continue
-		}
+	// Vérifier en une fois quels événements existent déjà
+	existants, err := hs.repository.ObtenerEventosLote(ctx, claves)
+	if err != nil {
+		return resumen, fmt.Errorf("erreur vérification événements existants: %w", err)
+	}
+	presents := make(map[ClaveEvento]bool, len(existants))
+	for _, evento := range existants {
+		presents[ClaveEvento{IDProducto: evento.IDProducto, IDEvento: evento.IDEvento}] = true
+	}
 
-		if existingEvento == nil {
-			// Sauvegarder le nouvel événement
-			err = hs.dynamoDBService.GuardarEvento(ctx, eventoVerificado)
-			if err != nil {
-				log.Printf("⚠️ Erreur sauvegarde événement %s: %v", eventoVerificado.IDEvento, err)
-				continue
-			}
-			log.Printf("✅ Événement synchronisé: %s", eventoVerificado.IDEvento)
-		} else {
-			log.Printf("📋 Événement déjà existant: %s", eventoVerificado.IDEvento)
+	var nouveaux []models.EventoVerificado
+	for i, evento := range candidats {
+		if presents[claves[i]] {
+			resumen.existentes++
+			continue
 		}
+		// Une même ligne lue deux fois n'est écrite qu'une fois
+		presents[claves[i]] = true
+		nouveaux = append(nouveaux, evento)
+	}
+	if len(nouveaux) == 0 {
+		return resumen, nil
 	}
 
-	return nil
+	existentes, err := hs.repository.GuardarEventosLote(ctx, nouveaux)
+	resumen.existentes += len(existentes)
+	if err != nil {
+		return resumen, fmt.Errorf("erreur sauvegarde événements: %w", err)
+	}
+	resumen.nuevos = len(nouveaux) - len(existentes)
+	return resumen, nil
 }
 
 // convertirBlockchainEventEnEventoVerificado convertit un BlockchainEvent en EventoVerificado
@@ -539,11 +914,6 @@ func (hs *HistorialService) convertirBlockchainEventEnEventoVerificado(eventoBC
 		datosEvento = make(map[string]interface{})
 	}
 
-	// Ajouter des informations supplémentaires aux données
-	datosEvento["actorEmisor"] = eventoBC.ActorEmisor
-	datosEvento["estado"] = eventoBC.Estado
-	datosEvento["ipfsCid"] = eventoBC.IPFSCid
-
 	// Déterminer le résultat de vérification basé sur l'état
 	var resultadoVerificacion string
 	switch eventoBC.Estado {
@@ -564,49 +934,55 @@ func (hs *HistorialService) convertirBlockchainEventEnEventoVerificado(eventoBC
 		Fecha:                 fechaEvento,
 		Ubicacion:             eventoBC.ActorEmisor, // Utiliser l'acteur émetteur comme localisation
 		DatosEvento:           datosEvento,
+		Enriquecimiento:       enriquecimientoBlockchain(eventoBC),
 		HashEvento:            eventoBC.HashEvento,
+		ActorEmisor:           eventoBC.ActorEmisor,
+		FirmaDigital:          eventoBC.FirmaDigital,
+		HashCriptografico: models.HashCriptografico{
+			Algoritmo: models.AlgoritmoSHA256JCS,
+			ValorHash: eventoBC.HashEvento,
+		},
 		ReferenciaBlockchain:  eventoBC.DirectionBlockchain,
 		ResultadoVerificacion: resultadoVerificacion,
 		Observaciones:         fmt.Sprintf("Synchronisé depuis blockchain_medysupply - État: %s", eventoBC.Estado),
 		RawPayload:            eventoBC.DatosEvento,
-		CreatedAt:            time.Now(),
+		CreatedAt:            fechaRecepcionBlockchain(eventoBC),
 	}
 
 	return eventoVerificado, nil
 }
 
-// SynchroniserTousLesEventosBlockchain synchronise tous les événements blockchain
-func (hs *HistorialService) SynchroniserTousLesEventosBlockchain(ctx context.Context) error {
-	log.Printf("🔄 Synchronisation globale des événements blockchain")
-
-	// Récupérer tous les événements blockchain
-	eventosBlockchain, err := hs.dynamoDBService.ObtenerTousEventosBlockchain(ctx)
-	if err != nil {
-		return fmt.Errorf("erreur récupération tous les événements blockchain: %w", err)
+// fechaRecepcionBlockchain retourne la date d'écriture de la ligne blockchain_medysupply (réception
+// de l'événement en amont), ou maintenant si elle est absente ou illisible
+func fechaRecepcionBlockchain(eventoBC models.BlockchainEvent) time.Time {
+	if fecha, err := time.Parse(time.RFC3339Nano, eventoBC.CreatedAt); err == nil {
+		return fecha
 	}
+	return time.Now()
+}
 
-	if len(eventosBlockchain) == 0 {
-		log.Printf("⚠️ Aucun événement blockchain trouvé")
-		return nil
+// enriquecimientoBlockchain construit les champs d'enrichissement d'une ligne blockchain_medysupply
+func enriquecimientoBlockchain(eventoBC models.BlockchainEvent) map[string]interface{} {
+	return map[string]interface{}{
+		models.EnriquecimientoActorEmisor: eventoBC.ActorEmisor,
+		models.EnriquecimientoEstado:      eventoBC.Estado,
+		models.EnriquecimientoIPFSCid:     eventoBC.IPFSCid,
 	}
+}
 
-	log.Printf("📊 Trouvé %d événements blockchain au total", len(eventosBlockchain))
-
-	// Grouper par produit
-	eventosPorProducto := make(map[string][]models.BlockchainEvent)
-	for _, evento := range eventosBlockchain {
-		eventosPorProducto[evento.IDProducto] = append(eventosPorProducto[evento.IDProducto], evento)
+// algoritmoHashTransaccion retourne l'algorithme de hash déclaré par le producteur (JCS par défaut)
+func algoritmoHashTransaccion(event *models.TransaccionBlockchainEvent) string {
+	if algoritmo, ok := event.Metadatos["algoritmoHash"].(string); ok && algoritmo != "" {
+		return algoritmo
 	}
+	return models.AlgoritmoSHA256JCS
+}
 
-	// Synchroniser par produit
-	for idProducto, eventos := range eventosPorProducto {
-		log.Printf("🔄 Synchronisation pour produit: %s (%d événements)", idProducto, len(eventos))
-		err := hs.SynchroniserDepuisBlockchain(ctx, idProducto)
-		if err != nil {
-			log.Printf("⚠️ Erreur synchronisation produit %s: %v", idProducto, err)
-			continue
-		}
+// loteEvento retourne le lote d'un événement (enrichissement, puis payload producteur)
+func loteEvento(evento models.EventoVerificado) (string, bool) {
+	if lote, ok := evento.Enriquecimiento[models.EnriquecimientoLote].(string); ok {
+		return lote, true
 	}
-
-	return nil
+	lote, ok := evento.DatosEvento["lote"].(string)
+	return lote, ok
 }
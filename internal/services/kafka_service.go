@@ -4,7 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"errors"
+	"io"
 	"log"
+	"sync"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -12,7 +15,7 @@ import (
 	"github.com/edinfamous/historial-blockchain/internal/models"
 )
 
-// KafkaService gère les interactions avec Kafka
+// KafkaService gère les interactions avec Kafka (backend Kafka de l'EventBus)
 type KafkaService struct {
 	reader          *kafka.Reader
 	writer          *kafka.Writer
@@ -20,19 +23,32 @@ type KafkaService struct {
 	consumerGroup    string
 	topic           string
 	producerTopic   string
+	dlqTopic        string        // Lettres mortes (vide: messages en échec ignorés)
+	dlqWriter       *kafka.Writer
+	rejeu           sync.Mutex    // Un seul rejeu des lettres mortes à la fois
+	politica        PoliticaReintentos
+	workers         int // Messages traités en parallèle (ordre conservé par idProducto)
 }
 
+// intervalleCommitKafka regroupe les validations d'offsets: les messages traités sont validés au
+// plus toutes les secondes, et à la fermeture du reader
+const intervalleCommitKafka = time.Second
+
+// attenteRejeuDLQ borne l'attente d'un message pendant un rejeu: au-delà, la file est considérée vide
+const attenteRejeuDLQ = 10 * time.Second
+
 // NewKafkaService crée une nouvelle instance de KafkaService
 func NewKafkaService(bootstrapServers, consumerGroup, topic, producerTopic string) *KafkaService {
 	// Configuration du consumer
 	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:     []string{bootstrapServers},
-		Topic:       topic,
-		GroupID:     consumerGroup,
-		MinBytes:    10e3, // 10KB
-		MaxBytes:    10e6, // 10MB
-		MaxWait:     1 * time.Second,
-		StartOffset: kafka.LastOffset,
+		Brokers:        []string{bootstrapServers},
+		Topic:          topic,
+		GroupID:        consumerGroup,
+		MinBytes:       10e3, // 10KB
+		MaxBytes:       10e6, // 10MB
+		MaxWait:        1 * time.Second,
+		StartOffset:    kafka.LastOffset,
+		CommitInterval: intervalleCommitKafka, // Validations regroupées (voir ConsumeEvents)
 	})
 
 	// Configuration du producer
@@ -52,57 +68,216 @@ func NewKafkaService(bootstrapServers, consumerGroup, topic, producerTopic strin
 		consumerGroup:    consumerGroup,
 		topic:           topic,
 		producerTopic:   producerTopic,
+		politica:        PoliticaReintentosPorDefecto(),
+		workers:         ConcurrenciaConsumoPorDefecto,
+	}
+}
+
+// DefinirTopicDLQ active la file de lettres mortes: les messages illisibles ou dont le traitement
+// échoue y sont publiés avec leur position d'origine et la cause de l'échec
+func (ks *KafkaService) DefinirTopicDLQ(topic string) {
+	ks.dlqTopic = topic
+	if topic == "" {
+		ks.dlqWriter = nil
+		return
+	}
+
+	// La clé d'origine est conservée: Hash garde l'ordre des messages d'un même producteur
+	ks.dlqWriter = &kafka.Writer{
+		Addr:         kafka.TCP(ks.bootstrapServers),
+		Topic:        topic,
+		Balancer:     &kafka.Hash{},
+		BatchTimeout: 100 * time.Millisecond,
+		RequiredAcks: kafka.RequireAll,
+		Async:        false,
 	}
 }
 
-// ConsumeEvents consomme les événements de TransaccionBlockchain
+// DefinirPoliticaReintentos fixe les reprises d'un message en erreur transitoire avant lettres mortes
+func (ks *KafkaService) DefinirPoliticaReintentos(politica PoliticaReintentos) {
+	ks.politica = politica
+}
+
+// DefinirConcurrencia fixe le nombre de workers du consumer (1: traitement séquentiel)
+func (ks *KafkaService) DefinirConcurrencia(workers int) {
+	ks.workers = workers
+}
+
+// ConsumeEvents consomme les événements de TransaccionBlockchain (livraison au moins une fois).
+// Les messages sont répartis par idProducto sur les workers: ceux d'un même produit sont traités
+// dans l'ordre, les produits différents en parallèle. Un offset n'est validé qu'une fois son
+// message et tous les précédents de la partition traités ou confiés à la DLQ. À l'annulation du
+// contexte, la lecture s'arrête, les messages en cours sont terminés et ceux en attente seront
+// relivrés; les validations en attente sont envoyées à la fermeture du reader (Close).
 func (ks *KafkaService) ConsumeEvents(ctx context.Context, handler func(event *models.TransaccionBlockchainEvent) error) error {
-	log.Printf("🎧 Début de consommation des événements depuis le topic: %s", ks.topic)
+	log.Printf("🎧 Début de consommation des événements depuis le topic: %s (%d workers)", ks.topic, max(ks.workers, 1))
+
+	pool := nouveauPoolOrdonne(ks.workers)
+	defer pool.fermer()
+	suivi := nouveauSuiviOffsets()
 
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("🛑 Arrêt de la consommation d'événements")
-			return ctx.Err()
-		default:
-			// Lire le message suivant
-			msg, err := ks.reader.ReadMessage(ctx)
-			if err != nil {
-				log.Printf("❌ Erreur lecture message Kafka: %v", err)
-				continue
+		// Lire le message suivant sans valider son offset
+		msg, err := ks.reader.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				log.Println("🛑 Arrêt de la consommation d'événements")
+				return ctx.Err()
 			}
+			if errors.Is(err, io.EOF) {
+				return nil // Reader fermé
+			}
+			log.Printf("❌ Erreur lecture message Kafka: %v", err)
+			if err := attendre(ctx, time.Second); err != nil {
+				return err
+			}
+			continue
+		}
 
-			log.Printf("📨 Message reçu: partition=%d offset=%d key=%s", 
-				msg.Partition, msg.Offset, string(msg.Key))
+		log.Printf("📨 Message reçu: partition=%d offset=%d key=%s",
+			msg.Partition, msg.Offset, string(msg.Key))
 
-			// Parser l'événement
-			var event models.TransaccionBlockchainEvent
-			err = json.Unmarshal(msg.Value, &event)
-			if err != nil {
-				log.Printf("❌ Erreur parsing événement: %v", err)
-				continue
+		enCours := suivi.ajouter(msg)
+		err = pool.soumettre(ctx, cleOrdre(msg.Value, msg.Key), func() {
+			if ctx.Err() != nil || !ks.traiterMessage(ctx, msg, handler) {
+				log.Printf("⏸️ Message partition=%d offset=%d non validé, relivré au prochain démarrage", msg.Partition, msg.Offset)
+				return
 			}
 
-			// Traiter l'événement
-			if err := handler(&event); err != nil {
-				log.Printf("❌ Erreur traitement événement %s: %v", event.IDEvento, err)
-				// En production, envoyer vers DLQ
-				continue
+			// Validation regroupée par le reader: ne bloque pas sur le broker. Le contexte d'arrêt
+			// ne doit pas empêcher de valider un message déjà traité.
+			if aValider, ok := suivi.terminer(enCours); ok {
+				if err := ks.reader.CommitMessages(context.WithoutCancel(ctx), aValider); err != nil {
+					log.Printf("❌ Erreur validation offset partition=%d offset=%d: %v", aValider.Partition, aValider.Offset, err)
+				}
 			}
+		})
+		if err != nil {
+			log.Println("🛑 Arrêt de la consommation d'événements")
+			return err
+		}
+	}
+}
+
+// traiterMessage traite un message. Retourne false si l'arrêt a interrompu le traitement avant
+// que le message ne soit traité ou confié à la DLQ.
+func (ks *KafkaService) traiterMessage(ctx context.Context, msg kafka.Message, handler func(event *models.TransaccionBlockchainEvent) error) bool {
+	intentos, motivo, err := traiterMessageTransaccion(ctx, msg.Value, handler, ks.politica)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false
+		}
+		if err := ks.envoyerDLQ(ctx, mensajeDLQDepuisEchecKafka(msg, intentos, motivo, err)); err != nil {
+			return false
+		}
+	}
+	return true
+}
 
-			log.Printf("✅ Événement traité avec succès: %s", event.IDEvento)
+// envoyerDLQ publie un message en échec sur le topic de lettres mortes, en reprenant jusqu'à
+// l'annulation du contexte: le message n'est validé sur le topic source qu'une fois publié.
+// Sans DLQ configurée, le message est ignoré.
+func (ks *KafkaService) envoyerDLQ(ctx context.Context, mensaje MensajeDLQ) error {
+	if ks.dlqWriter == nil {
+		log.Printf("⚠️ Message ignoré (aucune DLQ configurée): partition=%d offset=%d", mensaje.Particion, mensaje.Offset)
+		return nil
+	}
+
+	for tentative := 1; ; tentative++ {
+		err := ks.dlqWriter.WriteMessages(ctx, mensaje.messageKafka())
+		if err == nil {
+			break
+		}
+		log.Printf("❌ Erreur publication DLQ partition=%d offset=%d (tentative %d): %v", mensaje.Particion, mensaje.Offset, tentative, err)
+		if err := attendre(ctx, ks.politica.espera(tentative)); err != nil {
+			return err
 		}
 	}
+
+	log.Printf("📮 Message envoyé en DLQ: topic=%s partition=%d offset=%d tentatives=%d motif=%s",
+		ks.dlqTopic, mensaje.Particion, mensaje.Offset, mensaje.Intentos, mensaje.Motivo)
+	return nil
+}
+
+// RejouerDLQ relit le topic de lettres mortes avec un groupe de consommateurs dédié et réinjecte
+// les messages dans le handler. Une partition est épuisée au premier message renvoyé pendant ce
+// rejeu: ses messages suivants sont ignorés sans validation, les autres partitions continuent. Le
+// rejeu s'arrête après max messages, quand toutes les partitions sont épuisées ou quand la file
+// est vide (relancer pour poursuivre).
+func (ks *KafkaService) RejouerDLQ(ctx context.Context, max int, handler func(event *models.TransaccionBlockchainEvent) error) (ResumenRejeuDLQ, error) {
+	var resumen ResumenRejeuDLQ
+	if ks.dlqWriter == nil {
+		return resumen, ErrDLQDesactivada
+	}
+	if max <= 0 {
+		max = MaxRejeuDLQParDefaut
+	}
+
+	ks.rejeu.Lock()
+	defer ks.rejeu.Unlock()
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     []string{ks.bootstrapServers},
+		Topic:       ks.dlqTopic,
+		GroupID:     ks.consumerGroup + "-dlq-replay",
+		MinBytes:    1,
+		MaxBytes:    10e6, // 10MB
+		MaxWait:     1 * time.Second,
+		StartOffset: kafka.FirstOffset,
+	})
+	defer reader.Close()
+
+	debut := time.Now()
+	particiones := ks.nombreParticionesDLQ(ctx) // 0: inconnu, seule la file vide arrête le rejeu
+	epuisees := make(map[int]bool)
+	log.Printf("🔁 Rejeu de la DLQ %s (max %d messages)", ks.dlqTopic, max)
+
+	for resumen.Leidos < max && (particiones == 0 || len(epuisees) < particiones) {
+		attente, cancel := context.WithTimeout(ctx, attenteRejeuDLQ)
+		msg, err := reader.FetchMessage(attente)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break // File vide
+			}
+			return resumen, fmt.Errorf("erreur lecture DLQ: %w", err)
+		}
+
+		// Renvoyé par ce rejeu: la partition est épuisée, ce message et les suivants ne sont pas
+		// validés et seront relus au prochain rejeu
+		mensaje := mensajeDLQDepuisKafka(msg)
+		if epuisees[msg.Partition] || !mensaje.FechaFallo.Before(debut) {
+			epuisees[msg.Partition] = true
+			continue
+		}
+		resumen.Leidos++
+
+		if intentos, motivo, errTraitement := traiterMessageTransaccion(ctx, mensaje.Valor, handler, ks.politica); errTraitement != nil {
+			if err := ks.dlqWriter.WriteMessages(ctx, mensaje.nouvelEchec(intentos, motivo, errTraitement).messageKafka()); err != nil {
+				return resumen, fmt.Errorf("erreur republication DLQ: %w", err)
+			}
+			resumen.Renvoyes++
+		} else {
+			resumen.Traites++
+		}
+
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return resumen, fmt.Errorf("erreur validation offset DLQ: %w", err)
+		}
+	}
+
+	log.Printf("✅ Rejeu DLQ terminé: %d lus, %d traités, %d renvoyés", resumen.Leidos, resumen.Traites, resumen.Renvoyes)
+	return resumen, nil
 }
 
 // PublishHistorialReconstruido publie un événement de reconstruction d'historial
 func (ks *KafkaService) PublishHistorialReconstruido(ctx context.Context, event *models.HistorialReconstruidoEvent) error {
-	return ks.publishEvent(ctx, "event.historial.reconstruido", event)
+	return ks.publishEvent(ctx, EventTypeHistorialReconstruido, event)
 }
 
 // PublishInconsistencia publie un événement d'inconsistance
 func (ks *KafkaService) PublishInconsistencia(ctx context.Context, event *models.InconsistenciaEvent) error {
-	return ks.publishEvent(ctx, "event.historial.inconsistencia", event)
+	return ks.publishEvent(ctx, EventTypeHistorialInconsistencia, event)
 }
 
 // publishEvent publie un événement générique
@@ -156,6 +331,12 @@ func (ks *KafkaService) Close() error {
 		}
 	}
 
+	if ks.dlqWriter != nil {
+		if err := ks.dlqWriter.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("erreur fermeture writer DLQ: %w", err))
+		}
+	}
+
 	if len(errs) > 0 {
 		return fmt.Errorf("erreurs fermeture Kafka: %v", errs)
 	}
@@ -163,6 +344,23 @@ func (ks *KafkaService) Close() error {
 	return nil
 }
 
+// nombreParticionesDLQ retourne le nombre de partitions du topic de lettres mortes (0 si inconnu)
+func (ks *KafkaService) nombreParticionesDLQ(ctx context.Context) int {
+	conn, err := kafka.DialContext(ctx, "tcp", ks.bootstrapServers)
+	if err != nil {
+		log.Printf("⚠️ Partitions de la DLQ %s inconnues: %v", ks.dlqTopic, err)
+		return 0
+	}
+	defer conn.Close()
+
+	partitions, err := conn.ReadPartitions(ks.dlqTopic)
+	if err != nil {
+		log.Printf("⚠️ Partitions de la DLQ %s inconnues: %v", ks.dlqTopic, err)
+		return 0
+	}
+	return len(partitions)
+}
+
 // VerificarConexion vérifie la connexion à Kafka
 func (ks *KafkaService) VerificarConexion(ctx context.Context) error {
 	// Essayer de créer une connexion temporaire
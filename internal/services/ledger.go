@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// LedgerVerifier vérifie les événements contre un registre d'ancrage (blockchain ou simulé)
+type LedgerVerifier interface {
+	// VerificarIntegridad vérifie l'événement et renseigne ResultadoVerificacion / Observaciones
+	VerificarIntegridad(ctx context.Context, evento *models.EventoVerificado) error
+	// ObtenerBloqueActual retourne le numéro du dernier bloc connu
+	ObtenerBloqueActual(ctx context.Context) (uint64, error)
+	// ObtenerHashBloque retourne le hash canonique du bloc à la hauteur donnée
+	ObtenerHashBloque(ctx context.Context, numero uint64) (string, error)
+	// VerificarConexion vérifie que le registre est joignable
+	VerificarConexion(ctx context.Context) error
+	// Close libère les ressources du backend
+	Close()
+	// Nombre identifie le backend dans le journal des vérifications (ethereum, local)
+	Nombre() string
+}
+
+// ErrRegistroIndisponible signale un registre injoignable (timeout, erreur RPC): aucun verdict n'est
+// renseigné sur l'événement, la vérification est à refaire plus tard
+var ErrRegistroIndisponible = errors.New("registre d'ancrage injoignable")
+
+// Vérification à la compilation que les implémentations respectent l'interface
+var (
+	_ LedgerVerifier = (*BlockchainService)(nil)
+	_ LedgerVerifier = (*LocalLedger)(nil)
+)
+
+// AnclajeTransaccion décrit ce qu'une transaction atteste réellement sur le registre
+type AnclajeTransaccion struct {
+	TxHash         string
+	BloqueNumero   uint64
+	BloqueHash     string
+	EnAttente      bool         // Transaction connue du nœud mais sans reçu: pas encore incluse
+	Confirmaciones uint64       // Nombre de blocs depuis l'inclusion, bloc d'inclusion compris
+	Revertida      bool         // Statut du reçu en échec: la transaction n'atteste rien
+	Destinatario   string       // Adresse du contrat appelé (vide pour une création de contrat)
+	HashesEntrada  []string     // Hashes de 32 octets lus dans l'input data
+	Logs           []LogAnclaje // Logs émis, avec les hashes qu'ils portent
+}
+
+// LogAnclaje décrit un log émis par la transaction
+type LogAnclaje struct {
+	Contrato string   // Adresse du contrat émetteur
+	Topico   string   // Topic 0 (signature de l'événement)
+	Hashes   []string // Topics indexés et mots de 32 octets des data
+}
+
+// ReglasAnclaje regroupe les règles d'acceptation d'une transaction d'ancrage
+type ReglasAnclaje struct {
+	ConfirmacionesRequeridas uint64
+	ContratosPermitidos      []string // Contrats registre acceptés (vide: tout contrat)
+	TopicoEvento             string   // Topic 0 attendu sur les logs d'ancrage (vide: tout topic)
+}
+
+// contratoPermitido indique si une adresse fait partie des contrats registre acceptés
+func (r ReglasAnclaje) contratoPermitido(direccion string) bool {
+	if len(r.ContratosPermitidos) == 0 {
+		return true
+	}
+	for _, contrato := range r.ContratosPermitidos {
+		if direccion != "" && models.NormalizarHash(contrato) == models.NormalizarHash(direccion) {
+			return true
+		}
+	}
+	return false
+}
+
+// hashesCandidats retourne les hashes attestés par un contrat accepté, et indique si
+// la transaction a touché au moins un contrat accepté (appel direct ou log émis).
+// Avec un topic d'ancrage, seuls les logs comptent: l'input data d'un appel au contrat ne dit pas
+// quelle fonction a été appelée ni si elle a réellement ancré le hash.
+func (r ReglasAnclaje) hashesCandidats(anclaje *AnclajeTransaccion) ([]string, bool) {
+	var hashes []string
+	contratoValido := false
+
+	if r.contratoPermitido(anclaje.Destinatario) {
+		contratoValido = true
+		if r.TopicoEvento == "" {
+			hashes = append(hashes, anclaje.HashesEntrada...)
+		}
+	}
+
+	for _, l := range anclaje.Logs {
+		if !r.contratoPermitido(l.Contrato) {
+			continue
+		}
+		contratoValido = true
+		if r.TopicoEvento != "" && models.NormalizarHash(l.Topico) != models.NormalizarHash(r.TopicoEvento) {
+			continue
+		}
+		hashes = append(hashes, l.Hashes...)
+	}
+
+	return hashes, contratoValido
+}
+
+// calcularConfirmaciones retourne le nombre de confirmations d'un bloc par rapport à la tête de chaîne
+func calcularConfirmaciones(bloqueActual, bloqueNumero uint64) uint64 {
+	if bloqueActual < bloqueNumero {
+		return 0
+	}
+	return bloqueActual - bloqueNumero + 1
+}
+
+// calcularHashLocalEvento recalcule le hash du payload producteur selon l'algorithme enregistré
+func calcularHashLocalEvento(evento *models.EventoVerificado) (string, string, error) {
+	algoritmo := evento.HashCriptografico.Algoritmo
+	if algoritmo == "" {
+		algoritmo = models.AlgoritmoSHA256JCS
+	}
+
+	hashLocal, err := CalcularHashDatos(evento.DatosEvento, algoritmo)
+	return algoritmo, hashLocal, err
+}
+
+// compararAnclaje compare le hash recalculé localement (ou la racine de sa preuve Merkle) avec le
+// hash ancré dans la transaction. Une transaction pas encore incluse, ou un hash correct dans un bloc
+// sous la profondeur requise, donne PENDING_CONFIRMATION.
+func compararAnclaje(evento *models.EventoVerificado, anclaje *AnclajeTransaccion, reglas ReglasAnclaje) error {
+	// Rien à comparer avant l'inclusion: le moniteur de confirmations reprendra l'événement
+	if anclaje.EnAttente {
+		evento.BloqueNumero = 0
+		evento.BloqueHash = ""
+		evento.ResultadoVerificacion = models.VerificacionPendienteConfirmacion
+		evento.Observaciones = fmt.Sprintf("Transaction %s en attente d'inclusion dans un bloc", anclaje.TxHash)
+		return nil
+	}
+
+	// Un reçu en échec n'ancre rien, même si l'input data contient le hash
+	if anclaje.Revertida {
+		evento.ResultadoVerificacion = models.VerificacionTxRevertida
+		evento.Observaciones = fmt.Sprintf("Transaction %s revertée dans le bloc %d", anclaje.TxHash, anclaje.BloqueNumero)
+		return fmt.Errorf("transaction revertée: %s", anclaje.TxHash)
+	}
+
+	// La transaction doit viser (ou faire émettre) un contrat registre autorisé
+	hashesAnclados, contratoValido := reglas.hashesCandidats(anclaje)
+	if !contratoValido {
+		evento.ResultadoVerificacion = models.VerificacionContratoIncorrecto
+		evento.Observaciones = fmt.Sprintf("Transaction %s adressée à %s, hors des contrats registre autorisés", anclaje.TxHash, anclaje.Destinatario)
+		return fmt.Errorf("contrat non autorisé: %s", anclaje.Destinatario)
+	}
+
+	// Calculer le hash local sur le payload producteur (hors enrichissement)
+	algoritmo, hashLocal, err := calcularHashLocalEvento(evento)
+	if err != nil {
+		evento.ResultadoVerificacion = models.VerificacionHashMismatch
+		evento.Observaciones = fmt.Sprintf("Erreur calcul hash local: %v", err)
+		return fmt.Errorf("erreur calcul hash local: %w", err)
+	}
+
+	// La transaction existe mais n'atteste aucun hash
+	if len(hashesAnclados) == 0 {
+		evento.ResultadoVerificacion = models.VerificacionHashMismatch
+		evento.Observaciones = fmt.Sprintf("Transaction %s trouvée mais aucun hash ancré", anclaje.TxHash)
+		return fmt.Errorf("aucun hash ancré dans la transaction %s", anclaje.TxHash)
+	}
+
+	// Ancrage par lot: la feuille doit être incluse sous la racine, et c'est la racine qui est ancrée
+	hashLocalCripto := models.HashCriptografico{Algoritmo: algoritmo, ValorHash: hashLocal}
+	hashAttendu := hashLocalCripto
+	if evento.PruebaMerkle != nil {
+		incluido, err := VerificarPruebaMerkle(hashLocal, evento.PruebaMerkle)
+		if err != nil || !incluido {
+			evento.ResultadoVerificacion = models.VerificacionHashMismatch
+			evento.Observaciones = fmt.Sprintf("Preuve Merkle invalide: hash local %s non inclus sous la racine %s", hashLocal, evento.PruebaMerkle.Raiz)
+			return fmt.Errorf("preuve Merkle invalide")
+		}
+		hashAttendu = models.HashCriptografico{Algoritmo: algoritmo, ValorHash: evento.PruebaMerkle.Raiz}
+	}
+
+	for _, hashAnclado := range hashesAnclados {
+		if hashAttendu.VerificarIntegridad(hashAnclado) {
+			// Mémoriser le bloc pour la re-vérification en cas de réorganisation
+			evento.BloqueNumero = anclaje.BloqueNumero
+			evento.BloqueHash = anclaje.BloqueHash
+			if anclaje.Confirmaciones < reglas.ConfirmacionesRequeridas {
+				evento.ResultadoVerificacion = models.VerificacionPendienteConfirmacion
+				evento.Observaciones = fmt.Sprintf("Hash ancré (%s) dans le bloc %d, %d/%d confirmations", algoritmo, anclaje.BloqueNumero, anclaje.Confirmaciones, reglas.ConfirmacionesRequeridas)
+				return nil
+			}
+			evento.ResultadoVerificacion = models.VerificacionOK
+			evento.Observaciones = fmt.Sprintf("Hash ancré vérifié (%s) dans le bloc %d", algoritmo, anclaje.BloqueNumero)
+			if !hashLocalCripto.VerificarIntegridad(evento.HashEvento) {
+				evento.Observaciones += fmt.Sprintf(" - hash déclaré différent: %s", evento.HashEvento)
+			}
+			return nil
+		}
+	}
+
+	// La transaction existe mais le hash ancré diffère des données
+	evento.ResultadoVerificacion = models.VerificacionHashMismatch
+	evento.Observaciones = fmt.Sprintf("Hash mismatch (%s): local=%s, ancré=%s", algoritmo, hashAttendu.ValorHash, strings.Join(hashesAnclados, ","))
+	return fmt.Errorf("hash mismatch")
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// TransaccionLocal représente un reçu de transaction enregistré dans le registre local
+type TransaccionLocal struct {
+	TxHash       string `json:"txHash"`
+	HashAnclado  string `json:"hashAnclado"` // Hash du payload attesté par la transaction
+	BloqueNumero uint64 `json:"bloqueNumero"`
+	BloqueHash   string `json:"bloqueHash,omitempty"` // Par défaut: hash déterministe dérivé du numéro
+	Revertida    bool   `json:"revertida"`
+	EnAttente    bool   `json:"enAttente,omitempty"` // Transaction émise mais pas encore incluse (BloqueNumero ignoré)
+	Contrato     string `json:"contrato,omitempty"` // Contrat appelé, ou émetteur du log si Topico est renseigné
+	Topico       string `json:"topico,omitempty"`   // Topic 0 du log d'ancrage (vide: ancrage dans l'input data)
+}
+
+// LocalLedger est un registre déterministe en mémoire qui remplace la blockchain (tests, CI)
+type LocalLedger struct {
+	mu            sync.RWMutex
+	transacciones map[string]TransaccionLocal
+	bloques       map[uint64]string
+	bloqueActual  uint64
+	reglas        ReglasAnclaje
+}
+
+// NewLocalLedger crée un registre local vide
+func NewLocalLedger() *LocalLedger {
+	return &LocalLedger{
+		transacciones: make(map[string]TransaccionLocal),
+		bloques:       make(map[uint64]string),
+	}
+}
+
+// DefinirConfirmaciones fixe la profondeur de confirmation requise pour un résultat OK
+func (ll *LocalLedger) DefinirConfirmaciones(confirmacionesRequeridas uint64) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	ll.reglas.ConfirmacionesRequeridas = confirmacionesRequeridas
+}
+
+// DefinirContratosPermitidos restreint l'ancrage aux contrats registre donnés et, si renseigné, au topic d'événement
+func (ll *LocalLedger) DefinirContratosPermitidos(contratos []string, topico string) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	ll.reglas.ContratosPermitidos = append([]string(nil), contratos...)
+	ll.reglas.TopicoEvento = topico
+}
+
+// RegistrarTransaccion ajoute (ou remplace) un reçu de transaction et avance la tête de chaîne si besoin
+func (ll *LocalLedger) RegistrarTransaccion(tx TransaccionLocal) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	if !tx.EnAttente {
+		if tx.BloqueHash == "" {
+			tx.BloqueHash = hashBloqueLocal(tx.BloqueNumero)
+		}
+		ll.bloques[tx.BloqueNumero] = tx.BloqueHash
+		if tx.BloqueNumero > ll.bloqueActual {
+			ll.bloqueActual = tx.BloqueNumero
+		}
+	}
+	ll.transacciones[normaliserTxHash(tx.TxHash)] = tx
+}
+
+// DefinirBloqueActual fixe la tête de chaîne simulée (ajout de blocs de confirmation)
+func (ll *LocalLedger) DefinirBloqueActual(numero uint64) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	ll.bloqueActual = numero
+}
+
+// ReorganizarBloque remplace le bloc à la hauteur donnée: ses transactions disparaissent de la chaîne
+func (ll *LocalLedger) ReorganizarBloque(numero uint64, nuevoHash string) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	ll.bloques[numero] = nuevoHash
+	for clave, tx := range ll.transacciones {
+		if tx.BloqueNumero == numero {
+			delete(ll.transacciones, clave)
+		}
+	}
+}
+
+// EliminarTransaccion retire un reçu, la transaction devient introuvable
+func (ll *LocalLedger) EliminarTransaccion(txHash string) {
+	ll.mu.Lock()
+	defer ll.mu.Unlock()
+
+	delete(ll.transacciones, normaliserTxHash(txHash))
+}
+
+// CargarDesdeArchivo charge des reçus depuis un fichier JSON (tableau de TransaccionLocal)
+func (ll *LocalLedger) CargarDesdeArchivo(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erreur lecture fichier registre local: %w", err)
+	}
+
+	var transacciones []TransaccionLocal
+	if err := json.Unmarshal(data, &transacciones); err != nil {
+		return fmt.Errorf("erreur parsing fichier registre local: %w", err)
+	}
+
+	for _, tx := range transacciones {
+		ll.RegistrarTransaccion(tx)
+	}
+
+	log.Printf("✅ %d transactions chargées dans le registre local depuis %s", len(transacciones), path)
+	return nil
+}
+
+// VerificarIntegridad vérifie un événement contre les reçus enregistrés
+func (ll *LocalLedger) VerificarIntegridad(ctx context.Context, evento *models.EventoVerificado) error {
+	if evento.ReferenciaBlockchain == "" {
+		return fmt.Errorf("référence blockchain manquante")
+	}
+
+	ll.mu.RLock()
+	tx, ok := ll.transacciones[normaliserTxHash(evento.ReferenciaBlockchain)]
+	ll.mu.RUnlock()
+
+	if !ok {
+		evento.ResultadoVerificacion = models.VerificacionNotFound
+		evento.Observaciones = fmt.Sprintf("Transaction not found: %s", evento.ReferenciaBlockchain)
+		return fmt.Errorf("transaction non trouvée: %s", evento.ReferenciaBlockchain)
+	}
+
+	if tx.EnAttente {
+		return compararAnclaje(evento, &AnclajeTransaccion{TxHash: tx.TxHash, EnAttente: true}, ReglasAnclaje{})
+	}
+
+	ll.mu.RLock()
+	anclaje := &AnclajeTransaccion{
+		TxHash:         tx.TxHash,
+		BloqueNumero:   tx.BloqueNumero,
+		BloqueHash:     tx.BloqueHash,
+		Confirmaciones: calcularConfirmaciones(ll.bloqueActual, tx.BloqueNumero),
+		Revertida:      tx.Revertida,
+		Destinatario:   tx.Contrato,
+	}
+	reglas := ll.reglas
+	ll.mu.RUnlock()
+
+	// Avec un topic, le hash est porté par un log émis par le contrat, sinon par l'input data
+	var hashes []string
+	if tx.HashAnclado != "" {
+		hashes = []string{tx.HashAnclado}
+	}
+	if tx.Topico != "" {
+		anclaje.Logs = []LogAnclaje{{Contrato: tx.Contrato, Topico: tx.Topico, Hashes: hashes}}
+	} else {
+		anclaje.HashesEntrada = hashes
+	}
+
+	return compararAnclaje(evento, anclaje, reglas)
+}
+
+// AnclarHash simule une transaction d'ancrage incluse dans un nouveau bloc, adressée au premier
+// contrat registre autorisé (et émise en log si un topic d'ancrage est configuré)
+func (ll *LocalLedger) AnclarHash(ctx context.Context, hash string) (string, error) {
+	ll.mu.RLock()
+	numero := ll.bloqueActual + 1
+	contrato := ""
+	if len(ll.reglas.ContratosPermitidos) > 0 {
+		contrato = ll.reglas.ContratosPermitidos[0]
+	}
+	topico := ll.reglas.TopicoEvento
+	ll.mu.RUnlock()
+
+	txHash := fmt.Sprintf("0x%x", sha256.Sum256([]byte(fmt.Sprintf("%s:%d", models.NormalizarHash(hash), numero))))
+	ll.RegistrarTransaccion(TransaccionLocal{
+		TxHash:       txHash,
+		HashAnclado:  hash,
+		BloqueNumero: numero,
+		Contrato:     contrato,
+		Topico:       topico,
+	})
+
+	return txHash, nil
+}
+
+// ObtenerBloqueActual retourne la tête de chaîne simulée
+func (ll *LocalLedger) ObtenerBloqueActual(ctx context.Context) (uint64, error) {
+	ll.mu.RLock()
+	defer ll.mu.RUnlock()
+
+	return ll.bloqueActual, nil
+}
+
+// ObtenerHashBloque retourne le hash du bloc à la hauteur donnée
+func (ll *LocalLedger) ObtenerHashBloque(ctx context.Context, numero uint64) (string, error) {
+	ll.mu.RLock()
+	defer ll.mu.RUnlock()
+
+	if numero > ll.bloqueActual {
+		return "", fmt.Errorf("bloc %d non trouvé", numero)
+	}
+	if hash, ok := ll.bloques[numero]; ok {
+		return hash, nil
+	}
+	return hashBloqueLocal(numero), nil
+}
+
+// hashBloque retourne le hash déterministe par défaut d'un bloc simulé
+func hashBloqueLocal(numero uint64) string {
+	return fmt.Sprintf("0x%064x", numero)
+}
+
+// VerificarConexion est toujours réussie pour le registre local
+func (ll *LocalLedger) VerificarConexion(ctx context.Context) error {
+	return nil
+}
+
+// Close n'a rien à libérer pour le registre local
+func (ll *LocalLedger) Close() {}
+
+// Nombre identifie le registre local
+func (ll *LocalLedger) Nombre() string {
+	return "local"
+}
+
+// normaliserTxHash uniformise un hash de transaction pour les recherches
+func normaliserTxHash(txHash string) string {
+	return strings.ToLower(strings.TrimSpace(txHash))
+}
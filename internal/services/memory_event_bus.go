@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// MensajePublicado représente un message émis sur le bus en mémoire
+type MensajePublicado struct {
+	EventType string          `json:"eventType"`
+	Payload   json.RawMessage `json:"payload"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// topicMemoire identifie le bus en mémoire dans la position des lettres mortes
+const topicMemoire = "memory"
+
+// MemoryEventBus est un broker en mémoire basé sur des channels (tests, mode standalone)
+type MemoryEventBus struct {
+	entrants   chan []byte
+	mu         sync.RWMutex
+	publicados []MensajePublicado
+	consommes  int64        // Offset du prochain message consommé
+	dlq        []MensajeDLQ // Lettres mortes, dans l'ordre des échecs
+	politica   PoliticaReintentos
+	workers    int // Séquentiel par défaut (ordre de traitement déterministe pour les tests)
+	closeOnce  sync.Once
+	closed     chan struct{}
+}
+
+// NewMemoryEventBus crée un bus en mémoire avec une file d'entrée de taille donnée
+func NewMemoryEventBus(capacite int) *MemoryEventBus {
+	if capacite <= 0 {
+		capacite = 100
+	}
+
+	return &MemoryEventBus{
+		entrants: make(chan []byte, capacite),
+		closed:   make(chan struct{}),
+		politica: PoliticaReintentosPorDefecto(),
+		workers:  1,
+	}
+}
+
+// DefinirPoliticaReintentos fixe les reprises d'un message en erreur transitoire avant lettres mortes
+func (mb *MemoryEventBus) DefinirPoliticaReintentos(politica PoliticaReintentos) {
+	mb.politica = politica
+}
+
+// DefinirConcurrencia fixe le nombre de workers du consumer; l'ordre est conservé par idProducto
+func (mb *MemoryEventBus) DefinirConcurrencia(workers int) {
+	mb.workers = workers
+}
+
+// PublicarTransaccion injecte un événement TransaccionBlockchain dans le bus
+func (mb *MemoryEventBus) PublicarTransaccion(ctx context.Context, event *models.TransaccionBlockchainEvent) error {
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling événement: %w", err)
+	}
+
+	return mb.PublicarMensaje(ctx, eventBytes)
+}
+
+// PublicarMensaje injecte un message brut dans le bus (permet de simuler des messages invalides)
+func (mb *MemoryEventBus) PublicarMensaje(ctx context.Context, value []byte) error {
+	select {
+	case <-mb.closed:
+		return fmt.Errorf("bus en mémoire fermé")
+	default:
+	}
+
+	select {
+	case mb.entrants <- value:
+		return nil
+	case <-mb.closed:
+		return fmt.Errorf("bus en mémoire fermé")
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ConsumeEvents consomme les événements injectés jusqu'à l'annulation du contexte. Les messages
+// sont répartis par idProducto sur les workers; les messages déjà répartis sont terminés avant le retour.
+// Comme sur Kafka, un message interrompu par l'arrêt n'est pas envoyé en lettres mortes.
+func (mb *MemoryEventBus) ConsumeEvents(ctx context.Context, handler func(event *models.TransaccionBlockchainEvent) error) error {
+	log.Println("🎧 Début de consommation des événements depuis le bus en mémoire")
+
+	pool := nouveauPoolOrdonne(mb.workers)
+	defer pool.fermer()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("🛑 Arrêt de la consommation d'événements")
+			return ctx.Err()
+		case <-mb.closed:
+			return nil
+		case value := <-mb.entrants:
+			mb.mu.Lock()
+			offset := mb.consommes
+			mb.consommes++
+			mb.mu.Unlock()
+
+			err := pool.soumettre(ctx, cleOrdre(value, nil), func() {
+				if intentos, motivo, err := traiterMessageTransaccion(ctx, value, handler, mb.politica); err != nil {
+					if ctx.Err() != nil {
+						log.Printf("⏸️ Message offset=%d interrompu par l'arrêt, non envoyé en DLQ", offset)
+						return
+					}
+					mb.mu.Lock()
+					mb.dlq = append(mb.dlq, nouveauMensajeDLQ(topicMemoire, 0, offset, nil, value, nil, intentos, motivo, err))
+					mb.mu.Unlock()
+				}
+			})
+			if err != nil {
+				log.Println("🛑 Arrêt de la consommation d'événements")
+				return err
+			}
+		}
+	}
+}
+
+// RejouerDLQ réinjecte dans le handler les max plus anciennes lettres mortes; celles qui échouent
+// encore sont remises en fin de file, celles interrompues par l'arrêt reprennent leur place en tête
+func (mb *MemoryEventBus) RejouerDLQ(ctx context.Context, max int, handler func(event *models.TransaccionBlockchainEvent) error) (ResumenRejeuDLQ, error) {
+	var resumen ResumenRejeuDLQ
+	if max <= 0 {
+		max = MaxRejeuDLQParDefaut
+	}
+
+	mb.mu.Lock()
+	lot := mb.dlq[:min(max, len(mb.dlq))]
+	mb.dlq = append([]MensajeDLQ(nil), mb.dlq[len(lot):]...)
+	mb.mu.Unlock()
+
+	for i, mensaje := range lot {
+		if ctx.Err() != nil {
+			// Les messages non rejoués reprennent leur place en tête de file
+			mb.mu.Lock()
+			mb.dlq = append(append([]MensajeDLQ(nil), lot[i:]...), mb.dlq...)
+			mb.mu.Unlock()
+			return resumen, ctx.Err()
+		}
+
+		resumen.Leidos++
+		if intentos, motivo, err := traiterMessageTransaccion(ctx, mensaje.Valor, handler, mb.politica); err != nil {
+			if ctx.Err() != nil {
+				mb.mu.Lock()
+				mb.dlq = append(append([]MensajeDLQ(nil), lot[i:]...), mb.dlq...)
+				mb.mu.Unlock()
+				return resumen, ctx.Err()
+			}
+			mb.mu.Lock()
+			mb.dlq = append(mb.dlq, mensaje.nouvelEchec(intentos, motivo, err))
+			mb.mu.Unlock()
+			resumen.Renvoyes++
+			continue
+		}
+		resumen.Traites++
+	}
+
+	return resumen, nil
+}
+
+// LettresMortes retourne une copie des messages en lettres mortes
+func (mb *MemoryEventBus) LettresMortes() []MensajeDLQ {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	dlq := make([]MensajeDLQ, len(mb.dlq))
+	copy(dlq, mb.dlq)
+	return dlq
+}
+
+// PublishHistorialReconstruido enregistre un événement de reconstruction d'historial
+func (mb *MemoryEventBus) PublishHistorialReconstruido(ctx context.Context, event *models.HistorialReconstruidoEvent) error {
+	return mb.publishEvent(EventTypeHistorialReconstruido, event)
+}
+
+// PublishInconsistencia enregistre un événement d'inconsistance
+func (mb *MemoryEventBus) PublishInconsistencia(ctx context.Context, event *models.InconsistenciaEvent) error {
+	return mb.publishEvent(EventTypeHistorialInconsistencia, event)
+}
+
+// publishEvent enregistre un événement publié
+func (mb *MemoryEventBus) publishEvent(eventType string, event interface{}) error {
+	eventBytes, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("erreur marshalling événement: %w", err)
+	}
+
+	mb.mu.Lock()
+	mb.publicados = append(mb.publicados, MensajePublicado{
+		EventType: eventType,
+		Payload:   eventBytes,
+		Timestamp: time.Now(),
+	})
+	mb.mu.Unlock()
+
+	log.Printf("📤 Événement publié: type=%s (bus en mémoire)", eventType)
+	return nil
+}
+
+// Publicados retourne une copie des messages publiés par le service
+func (mb *MemoryEventBus) Publicados() []MensajePublicado {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+
+	publicados := make([]MensajePublicado, len(mb.publicados))
+	copy(publicados, mb.publicados)
+	return publicados
+}
+
+// VerificarConexion est toujours réussie pour le bus en mémoire
+func (mb *MemoryEventBus) VerificarConexion(ctx context.Context) error {
+	return nil
+}
+
+// Close arrête la consommation et refuse les nouveaux messages
+func (mb *MemoryEventBus) Close() error {
+	mb.closeOnce.Do(func() {
+		close(mb.closed)
+	})
+	return nil
+}
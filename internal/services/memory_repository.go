@@ -0,0 +1,619 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// MemoryRepository implémente HistorialRepository en mémoire (tests, exécution hors ligne)
+type MemoryRepository struct {
+	mu                sync.RWMutex
+	historiales       map[string]models.HistorialTransparencia
+	eventos           map[string]map[string]models.EventoVerificado
+	tasks             map[string]models.TaskStatus
+	eventosBlockchain map[string]models.BlockchainEvent
+	puntos            map[string]models.PuntoSincronizacion
+	intentos          map[ClaveEvento][]models.IntentoVerificacion
+}
+
+// NewMemoryRepository crée une nouvelle instance de MemoryRepository
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		historiales:       make(map[string]models.HistorialTransparencia),
+		eventos:           make(map[string]map[string]models.EventoVerificado),
+		tasks:             make(map[string]models.TaskStatus),
+		eventosBlockchain: make(map[string]models.BlockchainEvent),
+		puntos:            make(map[string]models.PuntoSincronizacion),
+		intentos:          make(map[ClaveEvento][]models.IntentoVerificacion),
+	}
+}
+
+// GuardarHistorial sauvegarde l'historial de transparence
+func (mr *MemoryRepository) GuardarHistorial(ctx context.Context, historial *models.HistorialTransparencia) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	// Même sémantique que la table DynamoDB: clé primaire (idProducto, lote)
+	mr.historiales[claveHistorialMemoria(historial.IDProducto, historial.Lote)] = copierHistorial(*historial)
+	return nil
+}
+
+// ObtenerHistorial récupère un historial par ID produit et lote
+func (mr *MemoryRepository) ObtenerHistorial(ctx context.Context, idProducto, lote string) (*models.HistorialTransparencia, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	historial, ok := mr.historiales[claveHistorialMemoria(idProducto, lote)]
+	if !ok {
+		return nil, nil // Non trouvé
+	}
+
+	resultat := copierHistorial(historial)
+	return &resultat, nil
+}
+
+// ListarHistorialesPorProducto liste les historiales de tous les lots d'un produit, triés par lote
+func (mr *MemoryRepository) ListarHistorialesPorProducto(ctx context.Context, idProducto string) ([]models.HistorialTransparencia, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	historiales := []models.HistorialTransparencia{}
+	for _, historial := range mr.historiales {
+		if historial.IDProducto == idProducto {
+			historiales = append(historiales, copierHistorial(historial))
+		}
+	}
+
+	sort.Slice(historiales, func(i, j int) bool {
+		return historiales[i].Lote < historiales[j].Lote
+	})
+
+	return historiales, nil
+}
+
+// ListarHistorialesPorProductoPagina lit une page des historiales d'un produit
+func (mr *MemoryRepository) ListarHistorialesPorProductoPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	historiales, _ := mr.ListarHistorialesPorProducto(ctx, idProducto)
+	return paginerMemoire(historiales, func(h models.HistorialTransparencia) string {
+		return h.Lote
+	}, "historiales#"+idProducto, consulta)
+}
+
+// ListarHistorialesInconsistentes liste les historiales avec état inconsistant
+func (mr *MemoryRepository) ListarHistorialesInconsistentes(ctx context.Context) ([]models.HistorialTransparencia, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var historiales []models.HistorialTransparencia
+	for _, historial := range mr.historiales {
+		if historial.EstadoActual == models.EstadoInconsistente {
+			historiales = append(historiales, copierHistorial(historial))
+		}
+	}
+
+	sort.Slice(historiales, func(i, j int) bool {
+		if historiales[i].IDProducto != historiales[j].IDProducto {
+			return historiales[i].IDProducto < historiales[j].IDProducto
+		}
+		return historiales[i].Lote < historiales[j].Lote
+	})
+
+	return historiales, nil
+}
+
+// ListarHistorialesInconsistentesPagina lit une page des historiales avec état inconsistant
+func (mr *MemoryRepository) ListarHistorialesInconsistentesPagina(ctx context.Context, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	historiales, _ := mr.ListarHistorialesInconsistentes(ctx)
+	return paginerMemoire(historiales, func(h models.HistorialTransparencia) string {
+		return h.IDProducto + "\x00" + h.Lote
+	}, "historiales-inconsistentes", consulta)
+}
+
+// GuardarEvento sauvegarde un événement vérifié (idempotent, comme la version DynamoDB)
+func (mr *MemoryRepository) GuardarEvento(ctx context.Context, evento *models.EventoVerificado) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	eventosProducto, ok := mr.eventos[evento.IDProducto]
+	if !ok {
+		eventosProducto = make(map[string]models.EventoVerificado)
+		mr.eventos[evento.IDProducto] = eventosProducto
+	}
+
+	if _, existe := eventosProducto[evento.IDEvento]; existe {
+		log.Printf("⚠️ Événement déjà existant (idempotence): %s", evento.IDEvento)
+		return nil
+	}
+
+	if evento.Version == 0 {
+		evento.Version = 1
+	}
+	eventosProducto[evento.IDEvento] = copierEvento(*evento)
+	return nil
+}
+
+// ObtenerEventos récupère tous les événements pour un produit, triés par idEvento
+func (mr *MemoryRepository) ObtenerEventos(ctx context.Context, idProducto string) ([]models.EventoVerificado, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	eventosProducto := mr.eventos[idProducto]
+	eventos := make([]models.EventoVerificado, 0, len(eventosProducto))
+	for _, evento := range eventosProducto {
+		eventos = append(eventos, copierEvento(evento))
+	}
+
+	sort.Slice(eventos, func(i, j int) bool {
+		return eventos[i].IDEvento < eventos[j].IDEvento
+	})
+
+	return eventos, nil
+}
+
+// ObtenerEventosPagina lit une page des événements d'un produit
+func (mr *MemoryRepository) ObtenerEventosPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error) {
+	eventos, _ := mr.ObtenerEventos(ctx, idProducto)
+	return paginerMemoire(eventos, func(e models.EventoVerificado) string {
+		return e.IDEvento
+	}, "eventos#"+idProducto, consulta)
+}
+
+// ObtenerEventosLote lit plusieurs événements (les clés absentes sont ignorées)
+func (mr *MemoryRepository) ObtenerEventosLote(ctx context.Context, claves []ClaveEvento) ([]models.EventoVerificado, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var eventos []models.EventoVerificado
+	for _, clave := range dedoublonnerClaves(claves) {
+		if evento, ok := mr.eventos[clave.IDProducto][clave.IDEvento]; ok {
+			eventos = append(eventos, copierEvento(evento))
+		}
+	}
+	return eventos, nil
+}
+
+// GuardarEventosLote crée les événements absents et retourne les clés des événements déjà présents
+func (mr *MemoryRepository) GuardarEventosLote(ctx context.Context, eventos []models.EventoVerificado) ([]ClaveEvento, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	var existentes []ClaveEvento
+	for _, evento := range dedoublonnerEventos(eventos) {
+		eventosProducto, ok := mr.eventos[evento.IDProducto]
+		if !ok {
+			eventosProducto = make(map[string]models.EventoVerificado)
+			mr.eventos[evento.IDProducto] = eventosProducto
+		}
+		if _, existe := eventosProducto[evento.IDEvento]; existe {
+			existentes = append(existentes, ClaveEvento{IDProducto: evento.IDProducto, IDEvento: evento.IDEvento})
+			continue
+		}
+		if evento.Version == 0 {
+			evento.Version = 1
+		}
+		eventosProducto[evento.IDEvento] = copierEvento(evento)
+	}
+	return existentes, nil
+}
+
+// ObtenerEvento récupère un événement spécifique
+func (mr *MemoryRepository) ObtenerEvento(ctx context.Context, idProducto, idEvento string) (*models.EventoVerificado, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	evento, ok := mr.eventos[idProducto][idEvento]
+	if !ok {
+		return nil, nil // Non trouvé
+	}
+
+	resultat := copierEvento(evento)
+	return &resultat, nil
+}
+
+// ActualizarResultadoVerificacion met à jour le résultat de vérification d'un événement existant si sa
+// version n'a pas changé depuis la lecture, puis incrémente evento.Version
+func (mr *MemoryRepository) ActualizarResultadoVerificacion(ctx context.Context, evento *models.EventoVerificado) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if _, ok := mr.eventos[evento.IDProducto][evento.IDEvento]; !ok {
+		return fmt.Errorf("événement non trouvé: %s", evento.IDEvento)
+	}
+	if !mr.actualiserVerification(evento) {
+		return fmt.Errorf("événement %s: %w", evento.IDEvento, ErrConflictoVersion)
+	}
+	return nil
+}
+
+// ActualizarResultadosVerificacionLote met à jour plusieurs résultats et retourne les clés des
+// événements modifiés entre-temps ou absents
+func (mr *MemoryRepository) ActualizarResultadosVerificacionLote(ctx context.Context, eventos []models.EventoVerificado) ([]ClaveEvento, error) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	var conflictos []ClaveEvento
+	for i := range eventos {
+		if !mr.actualiserVerification(&eventos[i]) {
+			conflictos = append(conflictos, ClaveEvento{IDProducto: eventos[i].IDProducto, IDEvento: eventos[i].IDEvento})
+		}
+	}
+	return conflictos, nil
+}
+
+// actualiserVerification applique la mise à jour si la version enregistrée est celle attendue
+// (mr.mu doit être verrouillé en écriture)
+func (mr *MemoryRepository) actualiserVerification(evento *models.EventoVerificado) bool {
+	existant, ok := mr.eventos[evento.IDProducto][evento.IDEvento]
+	if !ok || existant.Version != evento.Version {
+		return false
+	}
+
+	existant.ResultadoVerificacion = evento.ResultadoVerificacion
+	existant.Observaciones = evento.Observaciones
+	existant.BloqueNumero = evento.BloqueNumero
+	existant.BloqueHash = evento.BloqueHash
+	existant.Version++
+	mr.eventos[evento.IDProducto][evento.IDEvento] = existant
+	evento.Version = existant.Version
+	return true
+}
+
+// GuardarPruebaMerkle enregistre la transaction de la racine, la preuve d'inclusion et le résultat
+func (mr *MemoryRepository) GuardarPruebaMerkle(ctx context.Context, evento *models.EventoVerificado) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	existant, ok := mr.eventos[evento.IDProducto][evento.IDEvento]
+	if !ok {
+		return fmt.Errorf("événement non trouvé: %s", evento.IDEvento)
+	}
+
+	existant.ReferenciaBlockchain = evento.ReferenciaBlockchain
+	existant.PruebaMerkle = copierPruebaMerkle(evento.PruebaMerkle)
+	existant.ResultadoVerificacion = evento.ResultadoVerificacion
+	existant.Observaciones = evento.Observaciones
+	existant.BloqueNumero = evento.BloqueNumero
+	existant.BloqueHash = evento.BloqueHash
+	existant.Version++
+	mr.eventos[evento.IDProducto][evento.IDEvento] = existant
+	evento.Version = existant.Version
+	return nil
+}
+
+// ListarEventosPorResultado liste les événements ayant un résultat de vérification donné
+func (mr *MemoryRepository) ListarEventosPorResultado(ctx context.Context, resultado string) ([]models.EventoVerificado, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var eventos []models.EventoVerificado
+	for _, eventosProducto := range mr.eventos {
+		for _, evento := range eventosProducto {
+			if evento.ResultadoVerificacion == resultado {
+				eventos = append(eventos, copierEvento(evento))
+			}
+		}
+	}
+
+	sort.Slice(eventos, func(i, j int) bool {
+		if eventos[i].IDProducto != eventos[j].IDProducto {
+			return eventos[i].IDProducto < eventos[j].IDProducto
+		}
+		return eventos[i].IDEvento < eventos[j].IDEvento
+	})
+
+	return eventos, nil
+}
+
+// ListarEventosPorResultadoPagina lit une page des événements ayant un résultat de vérification donné
+func (mr *MemoryRepository) ListarEventosPorResultadoPagina(ctx context.Context, resultado string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error) {
+	eventos, _ := mr.ListarEventosPorResultado(ctx, resultado)
+	return paginerMemoire(eventos, func(e models.EventoVerificado) string {
+		return e.IDProducto + "\x00" + e.IDEvento
+	}, "eventos-resultado#"+resultado, consulta)
+}
+
+// GuardarTaskStatus sauvegarde le statut d'une tâche; les tâches expirées sont purgées au passage
+func (mr *MemoryRepository) GuardarTaskStatus(ctx context.Context, taskStatus *models.TaskStatus) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	maintenant := time.Now()
+	for taskID, tarea := range mr.tasks {
+		if tarea.Expirada(maintenant) {
+			delete(mr.tasks, taskID)
+		}
+	}
+
+	tarea := *taskStatus
+	tarea.Progreso = copierProgreso(taskStatus.Progreso)
+	mr.tasks[taskStatus.TaskID] = tarea
+	return nil
+}
+
+// ObtenerTaskStatus récupère le statut d'une tâche (nil si absente ou expirée)
+func (mr *MemoryRepository) ObtenerTaskStatus(ctx context.Context, taskID string) (*models.TaskStatus, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	taskStatus, ok := mr.tasks[taskID]
+	if !ok || taskStatus.Expirada(time.Now()) {
+		return nil, nil // Non trouvé
+	}
+
+	taskStatus.Progreso = copierProgreso(taskStatus.Progreso)
+	return &taskStatus, nil
+}
+
+// ListarTareas lit une page des tâches non expirées, de la plus récente à la plus ancienne
+func (mr *MemoryRepository) ListarTareas(ctx context.Context, filtro FiltroTareas, consulta ConsultaPaginada) ([]models.TaskStatus, string, error) {
+	mr.mu.RLock()
+	maintenant := time.Now()
+	var tareas []models.TaskStatus
+	for _, tarea := range mr.tasks {
+		if tarea.Expirada(maintenant) {
+			continue
+		}
+		if (filtro.Status != "" && tarea.Status != filtro.Status) || (filtro.IDProducto != "" && tarea.IDProducto != filtro.IDProducto) {
+			continue
+		}
+		tarea.Progreso = copierProgreso(tarea.Progreso)
+		tareas = append(tareas, tarea)
+	}
+	mr.mu.RUnlock()
+
+	sort.Slice(tareas, func(i, j int) bool {
+		return cleTacheRecente(tareas[i]) < cleTacheRecente(tareas[j])
+	})
+	return paginerMemoire(tareas, cleTacheRecente, "tareas#"+filtro.Status+"#"+filtro.IDProducto, consulta)
+}
+
+// cleTacheRecente ordonne les tâches de la plus récente à la plus ancienne
+func cleTacheRecente(tarea models.TaskStatus) string {
+	return fmt.Sprintf("%019d#%s", math.MaxInt64-tarea.CreatedAt.UnixNano(), tarea.TaskID)
+}
+
+// copierProgreso copie l'avancement d'une synchronisation (nil si absent)
+func copierProgreso(progreso *models.ProgresoSincronizacion) *models.ProgresoSincronizacion {
+	if progreso == nil {
+		return nil
+	}
+	copie := *progreso
+	return &copie
+}
+
+// ObtenerPuntoSincronizacion lit le point de reprise d'un produit (nil si absent)
+func (mr *MemoryRepository) ObtenerPuntoSincronizacion(ctx context.Context, idProducto string) (*models.PuntoSincronizacion, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	punto, ok := mr.puntos[idProducto]
+	if !ok {
+		return nil, nil // Non trouvé
+	}
+	return &punto, nil
+}
+
+// GuardarPuntoSincronizacion enregistre le point de reprise d'un produit sans le faire reculer
+func (mr *MemoryRepository) GuardarPuntoSincronizacion(ctx context.Context, punto *models.PuntoSincronizacion) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	if existant, ok := mr.puntos[punto.IDProducto]; ok && existant.UltimoUpdatedAt != "" {
+		recul := punto.UltimoUpdatedAt < existant.UltimoUpdatedAt ||
+			(punto.UltimoUpdatedAt == existant.UltimoUpdatedAt && punto.UltimoIDTransaction < existant.UltimoIDTransaction)
+		if recul {
+			return nil
+		}
+	}
+	mr.puntos[punto.IDProducto] = *punto
+	return nil
+}
+
+// GuardarIntentosVerificacion ajoute des tentatives au journal de vérification
+func (mr *MemoryRepository) GuardarIntentosVerificacion(ctx context.Context, intentos []models.IntentoVerificacion) error {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	for _, intento := range intentos {
+		clave := ClaveEvento{IDProducto: intento.IDProducto, IDEvento: intento.IDEvento}
+		mr.intentos[clave] = append(mr.intentos[clave], intento)
+	}
+	return nil
+}
+
+// ListarIntentosVerificacion retourne les tentatives d'un événement triées par idIntento
+func (mr *MemoryRepository) ListarIntentosVerificacion(ctx context.Context, idProducto, idEvento string) ([]models.IntentoVerificacion, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	intentos := append([]models.IntentoVerificacion(nil), mr.intentos[ClaveEvento{IDProducto: idProducto, IDEvento: idEvento}]...)
+	sort.SliceStable(intentos, func(i, j int) bool {
+		return intentos[i].IDIntento < intentos[j].IDIntento
+	})
+	return intentos, nil
+}
+
+// ObtenerEventosBlockchainPorProducto récupère les événements blockchain pour un produit
+func (mr *MemoryRepository) ObtenerEventosBlockchainPorProducto(ctx context.Context, idProducto string) ([]models.BlockchainEvent, error) {
+	return mr.ObtenerEventosBlockchainDesde(ctx, idProducto, nil)
+}
+
+// ObtenerEventosBlockchainDesde récupère les événements blockchain d'un produit postérieurs au point de reprise
+func (mr *MemoryRepository) ObtenerEventosBlockchainDesde(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) ([]models.BlockchainEvent, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	var eventos []models.BlockchainEvent
+	for _, evento := range mr.eventosBlockchain {
+		if evento.IDProducto == idProducto && desde.Posterior(evento) {
+			eventos = append(eventos, evento)
+		}
+	}
+
+	trierEventosBlockchain(eventos)
+	return eventos, nil
+}
+
+// ObtenerEventosBlockchainPorProductoPagina lit une page des événements blockchain d'un produit
+func (mr *MemoryRepository) ObtenerEventosBlockchainPorProductoPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.BlockchainEvent, string, error) {
+	eventos, _ := mr.ObtenerEventosBlockchainPorProducto(ctx, idProducto)
+	return paginerMemoire(eventos, cleEventoBlockchain, "blockchain#"+idProducto, consulta)
+}
+
+// ObtenerTousEventosBlockchain récupère tous les événements blockchain
+func (mr *MemoryRepository) ObtenerTousEventosBlockchain(ctx context.Context) ([]models.BlockchainEvent, error) {
+	mr.mu.RLock()
+	defer mr.mu.RUnlock()
+
+	eventos := make([]models.BlockchainEvent, 0, len(mr.eventosBlockchain))
+	for _, evento := range mr.eventosBlockchain {
+		eventos = append(eventos, evento)
+	}
+
+	trierEventosBlockchain(eventos)
+	return eventos, nil
+}
+
+// ObtenerTousEventosBlockchainPagina lit une page de la table blockchain simulée
+func (mr *MemoryRepository) ObtenerTousEventosBlockchainPagina(ctx context.Context, consulta ConsultaPaginada) ([]models.BlockchainEvent, string, error) {
+	eventos, _ := mr.ObtenerTousEventosBlockchain(ctx)
+	return paginerMemoire(eventos, cleEventoBlockchain, "blockchain", consulta)
+}
+
+// ParcourirEventosBlockchain répartit la table simulée en segments et la livre par pages
+func (mr *MemoryRepository) ParcourirEventosBlockchain(ctx context.Context, segmento, totalSegmentos int, traiter func([]models.BlockchainEvent) error) error {
+	if totalSegmentos <= 0 || segmento < 0 || segmento >= totalSegmentos {
+		return fmt.Errorf("segment %d/%d invalide", segmento, totalSegmentos)
+	}
+
+	todos, _ := mr.ObtenerTousEventosBlockchain(ctx)
+	var page []models.BlockchainEvent
+	for i := segmento; i < len(todos); i += totalSegmentos {
+		page = append(page, todos[i])
+		if len(page) == LimitePaginaPorDefecto {
+			if err := traiter(page); err != nil {
+				return err
+			}
+			page = nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(page) > 0 {
+		return traiter(page)
+	}
+	return nil
+}
+
+// AgregarEventoBlockchain ajoute (ou remplace) un événement dans la table blockchain simulée
+func (mr *MemoryRepository) AgregarEventoBlockchain(evento models.BlockchainEvent) {
+	mr.mu.Lock()
+	defer mr.mu.Unlock()
+
+	mr.eventosBlockchain[evento.IDTransaction] = evento
+}
+
+// CargarEventosBlockchain charge des événements blockchain depuis un fichier JSON (tableau)
+func (mr *MemoryRepository) CargarEventosBlockchain(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("erreur lecture fichier événements blockchain: %w", err)
+	}
+
+	var eventos []models.BlockchainEvent
+	if err := json.Unmarshal(data, &eventos); err != nil {
+		return fmt.Errorf("erreur parsing fichier événements blockchain: %w", err)
+	}
+
+	for _, evento := range eventos {
+		mr.AgregarEventoBlockchain(evento)
+	}
+
+	log.Printf("✅ %d événements blockchain chargés depuis %s", len(eventos), path)
+	return nil
+}
+
+// trierEventosBlockchain trie les événements par date puis par idTransaction
+func trierEventosBlockchain(eventos []models.BlockchainEvent) {
+	sort.Slice(eventos, func(i, j int) bool {
+		if eventos[i].FechaEvento != eventos[j].FechaEvento {
+			return eventos[i].FechaEvento < eventos[j].FechaEvento
+		}
+		return eventos[i].IDTransaction < eventos[j].IDTransaction
+	})
+}
+
+// cleEventoBlockchain est la clé de pagination cohérente avec trierEventosBlockchain
+func cleEventoBlockchain(evento models.BlockchainEvent) string {
+	return evento.FechaEvento + "\x00" + evento.IDTransaction
+}
+
+// claveHistorialMemoria construit la clé composite (idProducto, lote) d'un historial
+func claveHistorialMemoria(idProducto, lote string) string {
+	return idProducto + "#" + claveLoteHistorial(lote)
+}
+
+// copierHistorial retourne une copie indépendante d'un historial
+func copierHistorial(historial models.HistorialTransparencia) models.HistorialTransparencia {
+	if historial.Metadata != nil {
+		metadata := make(map[string]string, len(historial.Metadata))
+		for k, v := range historial.Metadata {
+			metadata[k] = v
+		}
+		historial.Metadata = metadata
+	}
+	return historial
+}
+
+// copierEvento retourne une copie indépendante d'un événement vérifié
+func copierEvento(evento models.EventoVerificado) models.EventoVerificado {
+	if evento.DatosEvento != nil {
+		evento.DatosEvento = copierValeur(evento.DatosEvento).(map[string]interface{})
+	}
+	if evento.Enriquecimiento != nil {
+		evento.Enriquecimiento = copierValeur(evento.Enriquecimiento).(map[string]interface{})
+	}
+	evento.PruebaMerkle = copierPruebaMerkle(evento.PruebaMerkle)
+	return evento
+}
+
+// copierPruebaMerkle copie une preuve d'inclusion (nil si absente)
+func copierPruebaMerkle(prueba *models.PruebaMerkle) *models.PruebaMerkle {
+	if prueba == nil {
+		return nil
+	}
+	copie := *prueba
+	copie.Hermanos = append([]models.NodoMerkle(nil), prueba.Hermanos...)
+	return &copie
+}
+
+// copierValeur copie récursivement les structures JSON génériques
+func copierValeur(valeur interface{}) interface{} {
+	switch v := valeur.(type) {
+	case map[string]interface{}:
+		copie := make(map[string]interface{}, len(v))
+		for k, elem := range v {
+			copie[k] = copierValeur(elem)
+		}
+		return copie
+	case []interface{}:
+		copie := make([]interface{}, len(v))
+		for i, elem := range v {
+			copie[i] = copierValeur(elem)
+		}
+		return copie
+	default:
+		return v
+	}
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// Préfixes de domaine (RFC 6962) : une feuille ne peut pas être confondue avec un nœud interne
+const (
+	prefixeFeuilleMerkle = 0x00
+	prefixeNoeudMerkle   = 0x01
+)
+
+// ConstruirArbolMerkle construit l'arbre Merkle des hashes d'événements (hex SHA-256) et
+// retourne la racine et la preuve d'inclusion de chaque feuille, dans l'ordre des hashes.
+// Un nœud sans frère est promu tel quel au niveau supérieur (pas de duplication).
+func ConstruirArbolMerkle(hojas []string) (string, []models.PruebaMerkle, error) {
+	if len(hojas) == 0 {
+		return "", nil, fmt.Errorf("lot Merkle vide")
+	}
+
+	nivel := make([][]byte, len(hojas))
+	pruebas := make([]models.PruebaMerkle, len(hojas))
+	// posiciones[i] est l'indice, dans le niveau courant, du nœud qui contient la feuille i
+	posiciones := make([]int, len(hojas))
+
+	for i, hoja := range hojas {
+		octets, err := hex.DecodeString(models.NormalizarHash(hoja))
+		if err != nil || len(octets) != sha256.Size {
+			return "", nil, fmt.Errorf("hash de feuille invalide à l'indice %d: %s", i, hoja)
+		}
+		nivel[i] = hashFeuilleMerkle(octets)
+		posiciones[i] = i
+		pruebas[i] = models.PruebaMerkle{
+			Hoja:       models.NormalizarHash(hoja),
+			Indice:     i,
+			TamanoLote: len(hojas),
+			Hermanos:   []models.NodoMerkle{},
+		}
+	}
+
+	for len(nivel) > 1 {
+		// Chaque feuille enregistre son frère au niveau courant
+		for i := range pruebas {
+			pos := posiciones[i]
+			if pos%2 == 0 && pos+1 < len(nivel) {
+				pruebas[i].Hermanos = append(pruebas[i].Hermanos, models.NodoMerkle{Hash: hex.EncodeToString(nivel[pos+1]), Derecha: true})
+			} else if pos%2 == 1 {
+				pruebas[i].Hermanos = append(pruebas[i].Hermanos, models.NodoMerkle{Hash: hex.EncodeToString(nivel[pos-1]), Derecha: false})
+			}
+			posiciones[i] = pos / 2
+		}
+
+		siguiente := make([][]byte, 0, (len(nivel)+1)/2)
+		for i := 0; i < len(nivel); i += 2 {
+			if i+1 < len(nivel) {
+				siguiente = append(siguiente, hashNoeudMerkle(nivel[i], nivel[i+1]))
+			} else {
+				siguiente = append(siguiente, nivel[i])
+			}
+		}
+		nivel = siguiente
+	}
+
+	raiz := hex.EncodeToString(nivel[0])
+	for i := range pruebas {
+		pruebas[i].Raiz = raiz
+	}
+
+	return raiz, pruebas, nil
+}
+
+// VerificarPruebaMerkle vérifie que le hash d'un événement est inclus sous la racine de la preuve
+func VerificarPruebaMerkle(hashEvento string, prueba *models.PruebaMerkle) (bool, error) {
+	if prueba == nil {
+		return false, fmt.Errorf("preuve Merkle absente")
+	}
+
+	octets, err := hex.DecodeString(models.NormalizarHash(hashEvento))
+	if err != nil || len(octets) != sha256.Size {
+		return false, fmt.Errorf("hash d'événement invalide: %s", hashEvento)
+	}
+
+	courant := hashFeuilleMerkle(octets)
+	for _, hermano := range prueba.Hermanos {
+		frere, err := hex.DecodeString(models.NormalizarHash(hermano.Hash))
+		if err != nil || len(frere) != sha256.Size {
+			return false, fmt.Errorf("nœud de preuve invalide: %s", hermano.Hash)
+		}
+		if hermano.Derecha {
+			courant = hashNoeudMerkle(courant, frere)
+		} else {
+			courant = hashNoeudMerkle(frere, courant)
+		}
+	}
+
+	return hex.EncodeToString(courant) == models.NormalizarHash(prueba.Raiz), nil
+}
+
+// hashFeuilleMerkle calcule SHA-256(0x00 || feuille)
+func hashFeuilleMerkle(hoja []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefixeFeuilleMerkle})
+	h.Write(hoja)
+	return h.Sum(nil)
+}
+
+// hashNoeudMerkle calcule SHA-256(0x01 || gauche || droite)
+func hashNoeudMerkle(izquierda, derecha []byte) []byte {
+	h := sha256.New()
+	h.Write([]byte{prefixeNoeudMerkle})
+	h.Write(izquierda)
+	h.Write(derecha)
+	return h.Sum(nil)
+}
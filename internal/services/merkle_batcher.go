@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// Anclador ancre un hash de 32 octets sur le registre et retourne le hash de la transaction
+type Anclador interface {
+	AnclarHash(ctx context.Context, hash string) (string, error)
+}
+
+// Vérification à la compilation que les implémentations respectent l'interface
+var (
+	_ Anclador = (*BlockchainService)(nil)
+	_ Anclador = (*LocalLedger)(nil)
+)
+
+// MerkleBatcher regroupe les événements à ancrer en lots: seule la racine Merkle du lot est
+// ancrée, chaque événement conserve sa preuve d'inclusion et la transaction de la racine.
+type MerkleBatcher struct {
+	mu             sync.Mutex
+	repository     HistorialRepository
+	anclador       Anclador
+	ledgerVerifier LedgerVerifier
+	tamanoLote     int
+	intervalo      time.Duration
+	pendientes     []models.EventoVerificado
+	loteCompleto   chan struct{}
+}
+
+// NewMerkleBatcher crée un MerkleBatcher. Un lot est ancré dès qu'il atteint tamanoLote
+// événements, et au plus tard à chaque intervalo. Si ledgerVerifier est fourni, chaque
+// événement est vérifié (preuve + transaction de la racine) juste après l'ancrage.
+func NewMerkleBatcher(repository HistorialRepository, anclador Anclador, ledgerVerifier LedgerVerifier, tamanoLote int, intervalo time.Duration) *MerkleBatcher {
+	return &MerkleBatcher{
+		repository:     repository,
+		anclador:       anclador,
+		ledgerVerifier: ledgerVerifier,
+		tamanoLote:     tamanoLote,
+		intervalo:      intervalo,
+		loteCompleto:   make(chan struct{}, 1),
+	}
+}
+
+// Agregar ajoute un événement déjà enregistré en PENDING_ANCHOR au lot courant. Un lot complet
+// est ancré par la boucle Demarrer, sans bloquer l'appelant pendant l'envoi de la transaction.
+func (mb *MerkleBatcher) Agregar(evento *models.EventoVerificado) {
+	mb.mu.Lock()
+	mb.pendientes = append(mb.pendientes, *evento)
+	complet := len(mb.pendientes) >= mb.tamanoLote
+	mb.mu.Unlock()
+
+	if complet {
+		select {
+		case mb.loteCompleto <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Recuperar recharge les événements restés en PENDING_ANCHOR (arrêt avant l'ancrage de leur lot)
+func (mb *MerkleBatcher) Recuperar(ctx context.Context) (int, error) {
+	eventos, err := mb.repository.ListarEventosPorResultado(ctx, models.VerificacionPendienteAnclaje)
+	if err != nil {
+		return 0, fmt.Errorf("erreur récupération événements en attente d'ancrage: %w", err)
+	}
+
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	dejaPresents := make(map[string]bool, len(mb.pendientes))
+	for _, evento := range mb.pendientes {
+		dejaPresents[evento.IDProducto+"#"+evento.IDEvento] = true
+	}
+
+	recuperes := 0
+	for _, evento := range eventos {
+		if dejaPresents[evento.IDProducto+"#"+evento.IDEvento] {
+			continue
+		}
+		mb.pendientes = append(mb.pendientes, evento)
+		recuperes++
+	}
+	return recuperes, nil
+}
+
+// Demarrer ancre périodiquement le lot courant jusqu'à l'annulation du contexte
+func (mb *MerkleBatcher) Demarrer(ctx context.Context) {
+	if n, err := mb.Recuperar(ctx); err != nil {
+		log.Printf("⚠️ %v", err)
+	} else if n > 0 {
+		log.Printf("🌳 %d événements en attente d'ancrage récupérés", n)
+	}
+
+	ticker := time.NewTicker(mb.intervalo)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			// Ancrer le dernier lot partiel avant l'arrêt; en cas d'échec il reste en
+			// PENDING_ANCHOR et sera récupéré au prochain démarrage
+			arretCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			if _, err := mb.Anclar(arretCtx); err != nil {
+				log.Printf("⚠️ Erreur ancrage du dernier lot: %v", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+		case <-mb.loteCompleto:
+		}
+
+		if _, err := mb.Anclar(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️ Erreur ancrage lot Merkle: %v", err)
+		}
+	}
+}
+
+// Anclar ancre la racine Merkle du lot courant et enregistre la preuve de chaque événement.
+// Retourne le nombre d'événements ancrés; en cas d'échec d'ancrage le lot est conservé.
+func (mb *MerkleBatcher) Anclar(ctx context.Context) (int, error) {
+	mb.mu.Lock()
+	pendientes := mb.pendientes
+	mb.pendientes = nil
+	mb.mu.Unlock()
+
+	// Un événement dont le hash ne peut pas être recalculé ne bloque pas le lot
+	lote := make([]models.EventoVerificado, 0, len(pendientes))
+	hojas := make([]string, 0, len(pendientes))
+	for i := range pendientes {
+		_, hashLocal, err := calcularHashLocalEvento(&pendientes[i])
+		if err != nil {
+			log.Printf("⚠️ Événement %s exclu du lot Merkle: %v", pendientes[i].IDEvento, err)
+			continue
+		}
+		lote = append(lote, pendientes[i])
+		hojas = append(hojas, hashLocal)
+	}
+
+	if len(lote) == 0 {
+		return 0, nil
+	}
+
+	raiz, pruebas, err := ConstruirArbolMerkle(hojas)
+	if err != nil {
+		mb.reintegrer(lote)
+		return 0, err
+	}
+
+	txHash, err := mb.anclador.AnclarHash(ctx, raiz)
+	if err != nil {
+		mb.reintegrer(lote)
+		return 0, fmt.Errorf("erreur ancrage racine Merkle: %w", err)
+	}
+	log.Printf("🌳 Racine Merkle %s (%d événements) ancrée: %s", raiz, len(lote), txHash)
+
+	for i := range lote {
+		evento := &lote[i]
+		evento.ReferenciaBlockchain = txHash
+		evento.PruebaMerkle = &pruebas[i]
+		evento.ResultadoVerificacion = models.VerificacionOK
+		evento.Observaciones = fmt.Sprintf("Ancré par lot Merkle (%d/%d)", i+1, len(lote))
+
+		if mb.ledgerVerifier != nil {
+			if err := mb.ledgerVerifier.VerificarIntegridad(ctx, evento); err != nil {
+				log.Printf("⚠️ Échec vérification événement %s après ancrage: %v", evento.IDEvento, err)
+			}
+		}
+
+		// La racine est déjà ancrée: un échec d'écriture laisse l'événement en PENDING_ANCHOR,
+		// il sera ré-ancré dans un lot ultérieur au prochain redémarrage
+		if err := mb.repository.GuardarPruebaMerkle(ctx, evento); err != nil {
+			log.Printf("⚠️ Erreur sauvegarde preuve Merkle %s: %v", evento.IDEvento, err)
+		}
+	}
+
+	return len(lote), nil
+}
+
+// reintegrer remet un lot non ancré en tête des événements en attente
+func (mb *MerkleBatcher) reintegrer(lote []models.EventoVerificado) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	mb.pendientes = append(lote, mb.pendientes...)
+}
@@ -0,0 +1,159 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+)
+
+// ErrCursorInvalido signale un jeton de pagination illisible ou émis pour une autre requête
+var ErrCursorInvalido = errors.New("cursor de pagination invalide")
+
+const (
+	// LimitePaginaPorDefecto est la taille de page appliquée quand aucune limite n'est demandée
+	LimitePaginaPorDefecto = 100
+	// LimitePaginaMaxima borne la taille d'une page
+	LimitePaginaMaxima = 1000
+)
+
+// ConsultaPaginada décrit la page demandée d'une liste
+type ConsultaPaginada struct {
+	Cursor string // Jeton opaque renvoyé par la page précédente (vide: première page)
+	Limite int    // Nombre maximal d'éléments (0: LimitePaginaPorDefecto)
+}
+
+// limite retourne la taille de page effective
+func (c ConsultaPaginada) limite() int {
+	if c.Limite <= 0 {
+		return LimitePaginaPorDefecto
+	}
+	if c.Limite > LimitePaginaMaxima {
+		return LimitePaginaMaxima
+	}
+	return c.Limite
+}
+
+// curseur est le contenu d'un jeton de pagination. La portée identifie la requête (table,
+// index, critère) pour qu'un jeton ne puisse pas être rejoué sur une autre liste.
+type curseur struct {
+	Portee string               `json:"p"`
+	Cle    map[string]valeurCle `json:"k"`
+}
+
+// valeurCle est un attribut de clé DynamoDB (les clés ne sont que des chaînes ou des nombres)
+type valeurCle struct {
+	S *string `json:"S,omitempty"`
+	N *string `json:"N,omitempty"`
+}
+
+// encoderCurseur transforme une LastEvaluatedKey en jeton opaque
+func encoderCurseur(portee string, cle map[string]types.AttributeValue) (string, error) {
+	if len(cle) == 0 {
+		return "", nil
+	}
+
+	contenu := curseur{Portee: portee, Cle: make(map[string]valeurCle, len(cle))}
+	for nom, valeur := range cle {
+		switch v := valeur.(type) {
+		case *types.AttributeValueMemberS:
+			contenu.Cle[nom] = valeurCle{S: &v.Value}
+		case *types.AttributeValueMemberN:
+			contenu.Cle[nom] = valeurCle{N: &v.Value}
+		default:
+			return "", fmt.Errorf("attribut de clé %s de type non supporté: %T", nom, valeur)
+		}
+	}
+
+	data, err := json.Marshal(contenu)
+	if err != nil {
+		return "", fmt.Errorf("erreur encodage cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decoderCurseur retrouve l'ExclusiveStartKey d'un jeton (nil pour un jeton vide)
+func decoderCurseur(jeton, portee string) (map[string]types.AttributeValue, error) {
+	if jeton == "" {
+		return nil, nil
+	}
+
+	contenu, err := lireCurseur(jeton)
+	if err != nil {
+		return nil, err
+	}
+	if contenu.Portee != portee {
+		return nil, fmt.Errorf("%w: émis pour une autre requête", ErrCursorInvalido)
+	}
+
+	cle := make(map[string]types.AttributeValue, len(contenu.Cle))
+	for nom, valeur := range contenu.Cle {
+		switch {
+		case valeur.S != nil && valeur.N == nil:
+			cle[nom] = &types.AttributeValueMemberS{Value: *valeur.S}
+		case valeur.N != nil && valeur.S == nil:
+			cle[nom] = &types.AttributeValueMemberN{Value: *valeur.N}
+		default:
+			return nil, fmt.Errorf("%w: attribut %s mal formé", ErrCursorInvalido, nom)
+		}
+	}
+	return cle, nil
+}
+
+// porteeCurseur retourne la portée d'un jeton sans le valider contre une requête
+func porteeCurseur(jeton string) (string, error) {
+	contenu, err := lireCurseur(jeton)
+	if err != nil {
+		return "", err
+	}
+	return contenu.Portee, nil
+}
+
+func lireCurseur(jeton string) (*curseur, error) {
+	data, err := base64.RawURLEncoding.DecodeString(jeton)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCursorInvalido, err)
+	}
+
+	var contenu curseur
+	if err := json.Unmarshal(data, &contenu); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrCursorInvalido, err)
+	}
+	if len(contenu.Cle) == 0 {
+		return nil, fmt.Errorf("%w: clé absente", ErrCursorInvalido)
+	}
+	return &contenu, nil
+}
+
+// paginerMemoire découpe une liste déjà triée selon cle (ordre croissant, valeurs uniques):
+// le jeton porte la clé du dernier élément renvoyé, la page suivante reprend juste après.
+func paginerMemoire[T any](elements []T, cle func(T) string, portee string, consulta ConsultaPaginada) ([]T, string, error) {
+	debut := 0
+	if consulta.Cursor != "" {
+		apres, err := decoderCurseur(consulta.Cursor, portee)
+		if err != nil {
+			return nil, "", err
+		}
+		derniere, ok := apres["cle"].(*types.AttributeValueMemberS)
+		if !ok {
+			return nil, "", fmt.Errorf("%w: clé absente", ErrCursorInvalido)
+		}
+		debut = sort.Search(len(elements), func(i int) bool {
+			return cle(elements[i]) > derniere.Value
+		})
+	}
+
+	fin := debut + consulta.limite()
+	if fin >= len(elements) {
+		return elements[debut:], "", nil
+	}
+
+	page := elements[debut:fin]
+	suivant, err := encoderCurseur(portee, map[string]types.AttributeValue{
+		"cle": &types.AttributeValueMemberS{Value: cle(page[len(page)-1])},
+	})
+	return page, suivant, err
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"hash/fnv"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ConcurrenciaConsumoPorDefecto est le nombre de workers du consumer d'événements
+const ConcurrenciaConsumoPorDefecto = 8
+
+// capaciteFileWorker borne les messages en attente par worker: au-delà, la lecture du bus attend
+const capaciteFileWorker = 64
+
+// poolOrdonne exécute des tâches sur plusieurs workers. Les tâches d'une même clé vont toujours au
+// même worker et s'exécutent dans l'ordre de soumission; des clés différentes avancent en parallèle.
+type poolOrdonne struct {
+	files []chan func()
+	wg    sync.WaitGroup
+}
+
+// nouveauPoolOrdonne démarre workers goroutines (au moins une)
+func nouveauPoolOrdonne(workers int) *poolOrdonne {
+	pool := &poolOrdonne{files: make([]chan func(), max(workers, 1))}
+	for i := range pool.files {
+		file := make(chan func(), capaciteFileWorker)
+		pool.files[i] = file
+
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+			for tache := range file {
+				tache()
+			}
+		}()
+	}
+	return pool
+}
+
+// soumettre place la tâche dans la file du worker de la clé, en attendant si elle est pleine
+func (p *poolOrdonne) soumettre(ctx context.Context, cle string, tache func()) error {
+	hash := fnv.New32a()
+	hash.Write([]byte(cle))
+	file := p.files[hash.Sum32()%uint32(len(p.files))]
+
+	select {
+	case file <- tache:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// fermer attend la fin des tâches soumises; le pool n'accepte plus de tâche ensuite
+func (p *poolOrdonne) fermer() {
+	for _, file := range p.files {
+		close(file)
+	}
+	p.wg.Wait()
+}
+
+// cleOrdre retourne la clé d'ordonnancement d'un message TransaccionBlockchain: son idProducto,
+// ou à défaut la clé du message (message illisible, traité sans garantie d'ordre)
+func cleOrdre(value, cle []byte) string {
+	var entete struct {
+		IDProducto string `json:"idProducto"`
+	}
+	if err := json.Unmarshal(value, &entete); err == nil && entete.IDProducto != "" {
+		return entete.IDProducto
+	}
+	return string(cle)
+}
+
+// suiviOffsets calcule les offsets validables quand les messages d'une partition se terminent dans
+// le désordre: seul le dernier message d'une suite contiguë de messages terminés est validé, un
+// message non terminé retient la validation de tous ceux qui le suivent dans sa partition
+type suiviOffsets struct {
+	mu         sync.Mutex
+	partitions map[int][]*messageEnCours // Messages lus non encore validables, par ordre d'offset
+}
+
+// messageEnCours est un message lu dont le traitement n'est pas encore validable
+type messageEnCours struct {
+	msg     kafka.Message
+	termine bool
+}
+
+func nouveauSuiviOffsets() *suiviOffsets {
+	return &suiviOffsets{partitions: make(map[int][]*messageEnCours)}
+}
+
+// ajouter enregistre un message lu. Un offset qui ne suit pas le dernier lu de la partition signale
+// une relecture après rééquilibrage: le suivi de la partition repart de ce message.
+func (s *suiviOffsets) ajouter(msg kafka.Message) *messageEnCours {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enCours := &messageEnCours{msg: msg}
+	file := s.partitions[msg.Partition]
+	if n := len(file); n > 0 && file[n-1].msg.Offset >= msg.Offset {
+		file = nil
+	}
+	s.partitions[msg.Partition] = append(file, enCours)
+	return enCours
+}
+
+// terminer marque le message traité et retourne le dernier message validable de sa partition
+func (s *suiviOffsets) terminer(enCours *messageEnCours) (kafka.Message, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	enCours.termine = true
+	file := s.partitions[enCours.msg.Partition]
+
+	var aValider *messageEnCours
+	for len(file) > 0 && file[0].termine {
+		aValider, file = file[0], file[1:]
+	}
+	s.partitions[enCours.msg.Partition] = file
+
+	if aValider == nil {
+		return kafka.Message{}, false
+	}
+	return aValider.msg, true
+}
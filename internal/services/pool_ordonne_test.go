@@ -0,0 +1,100 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCleOrdre(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		cle   []byte
+		want  string
+	}{
+		{name: "idProducto du payload", value: `{"idProducto": "prod-001", "idEvento": "evt-001"}`, cle: []byte("cle-kafka"), want: "prod-001"},
+		{name: "payload illisible: clé du message", value: `{"idProducto": `, cle: []byte("cle-kafka"), want: "cle-kafka"},
+		{name: "payload sans idProducto: clé du message", value: `{"idEvento": "evt-001"}`, cle: []byte("cle-kafka"), want: "cle-kafka"},
+		{name: "idProducto d'un autre type: clé du message", value: `{"idProducto": 42}`, cle: []byte("cle-kafka"), want: "cle-kafka"},
+		{name: "ni payload ni clé", value: `pas du json`, want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, cleOrdre([]byte(tt.value), tt.cle))
+		})
+	}
+}
+
+func TestPoolOrdonne_OrdreParCle(t *testing.T) {
+	// Arrange
+	pool := nouveauPoolOrdonne(4)
+	var mu sync.Mutex
+	ordre := map[string][]int{}
+
+	// Act
+	for i := 0; i < 50; i++ {
+		for _, cle := range []string{"prod-a", "prod-b", "prod-c"} {
+			cle, i := cle, i
+			require.NoError(t, pool.soumettre(context.Background(), cle, func() {
+				mu.Lock()
+				defer mu.Unlock()
+				ordre[cle] = append(ordre[cle], i)
+			}))
+		}
+	}
+	pool.fermer()
+
+	// Assert: chaque clé voit ses tâches dans l'ordre de soumission
+	for cle, indices := range ordre {
+		require.Len(t, indices, 50, cle)
+		for i, indice := range indices {
+			assert.Equal(t, i, indice, cle)
+		}
+	}
+}
+
+func TestPoolOrdonne_SoumettreApresArret(t *testing.T) {
+	// Arrange: un seul worker bloqué, sa file pleine
+	pool := nouveauPoolOrdonne(1)
+	debloquer := make(chan struct{})
+	var mu sync.Mutex
+	var executees []string
+
+	executer := func(nom string) func() {
+		return func() {
+			mu.Lock()
+			defer mu.Unlock()
+			executees = append(executees, nom)
+		}
+	}
+
+	require.NoError(t, pool.soumettre(context.Background(), "prod", func() {
+		<-debloquer
+		executer("bloquante")()
+	}))
+	for i := 0; i < capaciteFileWorker; i++ {
+		require.NoError(t, pool.soumettre(context.Background(), "prod", executer(fmt.Sprintf("en-file-%d", i))))
+	}
+
+	// Act: arrêt pendant que la file est pleine
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := pool.soumettre(ctx, "prod", executer("refusee"))
+	close(debloquer)
+	pool.fermer()
+
+	// Assert: la tâche refusée signale l'arrêt, celles déjà en file sont toutes exécutées
+	assert.ErrorIs(t, err, context.Canceled)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, executees, capaciteFileWorker+1)
+	assert.Equal(t, "bloquante", executees[0])
+	assert.Equal(t, fmt.Sprintf("en-file-%d", capaciteFileWorker-1), executees[capaciteFileWorker])
+	assert.NotContains(t, executees, "refusee")
+}
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand"
+	"net"
+	"time"
+
+	"github.com/aws/smithy-go"
+)
+
+// ErrMensajeInvalido marque un message qu'aucune nouvelle tentative ne peut traiter (poison):
+// JSON illisible, message non conforme au schéma de sa version, payload non canonicalisable
+var ErrMensajeInvalido = errors.New("message invalide")
+
+// Motifs d'envoi d'un message en lettres mortes
+const (
+	MotivoMensajeInvalido    = "MENSAJE_INVALIDO"    // Poison: envoyé sans nouvelle tentative
+	MotivoVersionDesconocida = "VERSION_DESCONOCIDA" // schemaVersion sans schéma embarqué
+	MotivoReintentosAgotados = "REINTENTOS_AGOTADOS" // Erreur transitoire persistante
+	MotivoErrorProcesamiento = "ERROR_PROCESAMIENTO" // Erreur non classée: envoyée sans reprise, rejouable
+)
+
+// PoliticaReintentos borne les reprises du traitement d'un message en erreur transitoire
+// (throttling DynamoDB, timeout) avant son envoi en lettres mortes
+type PoliticaReintentos struct {
+	MaxIntentos   int           // Tentatives au total, première incluse (1: aucune reprise)
+	EsperaInicial time.Duration // Attente maximale avant la première reprise, doublée ensuite
+	EsperaMaxima  time.Duration // Plafond de l'attente entre deux tentatives
+}
+
+// PoliticaReintentosPorDefecto retourne la politique appliquée par les bus d'événements
+func PoliticaReintentosPorDefecto() PoliticaReintentos {
+	return PoliticaReintentos{
+		MaxIntentos:   5,
+		EsperaInicial: 200 * time.Millisecond,
+		EsperaMaxima:  10 * time.Second,
+	}
+}
+
+// espera tire l'attente avant la tentative suivante (jitter complet): uniformément entre 0 et
+// le backoff exponentiel plafonné, pour que les consommateurs throttlés ne reprennent pas ensemble
+func (p PoliticaReintentos) espera(tentative int) time.Duration {
+	plafond := p.EsperaMaxima
+	if decalage := tentative - 1; decalage < 32 {
+		if backoff := p.EsperaInicial << decalage; backoff > 0 && backoff < plafond {
+			plafond = backoff
+		}
+	}
+	if plafond <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(plafond) + 1))
+}
+
+// executer applique la politique à traiter. Retourne le nombre de tentatives faites, la dernière
+// erreur et le motif d'envoi en lettres mortes si le message n'a pas pu être traité.
+func (p PoliticaReintentos) executer(ctx context.Context, traiter func() error) (int, string, error) {
+	maxIntentos := max(p.MaxIntentos, 1)
+
+	for tentative := 1; ; tentative++ {
+		err := traiter()
+		switch {
+		case err == nil:
+			return tentative, "", nil
+		case errors.Is(err, ErrMensajeInvalido):
+			return tentative, MotivoMensajeInvalido, err
+		case !esErreurTransitoire(err):
+			return tentative, MotivoErrorProcesamiento, err
+		case tentative >= maxIntentos:
+			return tentative, MotivoReintentosAgotados, err
+		}
+
+		attente := p.espera(tentative)
+		log.Printf("🔁 Erreur transitoire (tentative %d/%d), reprise dans %v: %v", tentative, maxIntentos, attente, err)
+		if attendre(ctx, attente) != nil {
+			return tentative, MotivoReintentosAgotados, err
+		}
+	}
+}
+
+// attendre patiente la durée donnée ou jusqu'à l'annulation du contexte
+func attendre(ctx context.Context, duree time.Duration) error {
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-time.After(duree):
+		return nil
+	}
+}
+
+// codesErreurTransitoire liste les erreurs AWS levées après épuisement des reprises du SDK
+var codesErreurTransitoire = map[string]bool{
+	"ProvisionedThroughputExceededException": true,
+	"ThrottlingException":                    true,
+	"RequestLimitExceeded":                   true,
+	"TransactionConflictException":           true,
+	"InternalServerError":                    true,
+	"ServiceUnavailable":                     true,
+}
+
+// esErreurTransitoire reconnaît les erreurs qu'une nouvelle tentative peut résoudre: throttling,
+// indisponibilité passagère (DynamoDB, registre d'ancrage) et timeouts (contexte de l'appel ou réseau)
+func esErreurTransitoire(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrRegistroIndisponible) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && codesErreurTransitoire[apiErr.ErrorCode()]
+}
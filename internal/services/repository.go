@@ -0,0 +1,94 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// HistorialRepository abstrait le stockage des historiales, événements et tâches.
+// Chaque liste existe en version complète et en version paginée (...Pagina) qui renvoie
+// le cursor opaque de la page suivante (vide sur la dernière page).
+type HistorialRepository interface {
+	// Historiales
+	GuardarHistorial(ctx context.Context, historial *models.HistorialTransparencia) error
+	// ObtenerHistorial lit l'historial d'un lot. Lote vide: ligne models.LoteGlobal, enregistrée par
+	// la dernière reconstruction sans filtre de lot (pas un agrégat des historiales par lot)
+	ObtenerHistorial(ctx context.Context, idProducto, lote string) (*models.HistorialTransparencia, error)
+	ListarHistorialesPorProducto(ctx context.Context, idProducto string) ([]models.HistorialTransparencia, error)
+	ListarHistorialesPorProductoPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error)
+	ListarHistorialesInconsistentes(ctx context.Context) ([]models.HistorialTransparencia, error)
+	ListarHistorialesInconsistentesPagina(ctx context.Context, consulta ConsultaPaginada) ([]models.HistorialTransparencia, string, error)
+
+	// Événements vérifiés
+	GuardarEvento(ctx context.Context, evento *models.EventoVerificado) error
+	ObtenerEventos(ctx context.Context, idProducto string) ([]models.EventoVerificado, error)
+	ObtenerEventosPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error)
+	ObtenerEvento(ctx context.Context, idProducto, idEvento string) (*models.EventoVerificado, error)
+	// ObtenerEventosLote lit plusieurs événements en une fois (les clés absentes sont ignorées)
+	ObtenerEventosLote(ctx context.Context, claves []ClaveEvento) ([]models.EventoVerificado, error)
+	// GuardarEventosLote crée plusieurs événements en une fois sans jamais écraser un événement
+	// existant, et retourne les clés des événements déjà présents (non écrits)
+	GuardarEventosLote(ctx context.Context, eventos []models.EventoVerificado) ([]ClaveEvento, error)
+	// ActualizarResultadoVerificacion met à jour le résultat de vérification d'un événement existant si
+	// sa version est toujours evento.Version (ErrConflictoVersion sinon), puis incrémente evento.Version
+	ActualizarResultadoVerificacion(ctx context.Context, evento *models.EventoVerificado) error
+	// ActualizarResultadosVerificacionLote met à jour plusieurs résultats avec le même contrôle de version
+	// et retourne les clés des événements modifiés entre-temps (non mis à jour)
+	ActualizarResultadosVerificacionLote(ctx context.Context, eventos []models.EventoVerificado) ([]ClaveEvento, error)
+	ListarEventosPorResultado(ctx context.Context, resultado string) ([]models.EventoVerificado, error)
+	ListarEventosPorResultadoPagina(ctx context.Context, resultado string, consulta ConsultaPaginada) ([]models.EventoVerificado, string, error)
+	// GuardarPruebaMerkle enregistre la transaction de la racine, la preuve d'inclusion et le résultat
+	GuardarPruebaMerkle(ctx context.Context, evento *models.EventoVerificado) error
+
+	// Tâches asynchrones
+	GuardarTaskStatus(ctx context.Context, taskStatus *models.TaskStatus) error
+	// ObtenerTaskStatus lit une tâche (nil si absente ou expirée)
+	ObtenerTaskStatus(ctx context.Context, taskID string) (*models.TaskStatus, error)
+	// ListarTareas lit une page des tâches non expirées, de la plus récente à la plus ancienne
+	ListarTareas(ctx context.Context, filtro FiltroTareas, consulta ConsultaPaginada) ([]models.TaskStatus, string, error)
+
+	// Événements blockchain (lecture seule)
+	ObtenerEventosBlockchainPorProducto(ctx context.Context, idProducto string) ([]models.BlockchainEvent, error)
+	ObtenerEventosBlockchainPorProductoPagina(ctx context.Context, idProducto string, consulta ConsultaPaginada) ([]models.BlockchainEvent, string, error)
+	// ObtenerEventosBlockchainDesde lit les événements d'un produit postérieurs au point de reprise
+	// (nil: tous les événements)
+	ObtenerEventosBlockchainDesde(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) ([]models.BlockchainEvent, error)
+	ObtenerTousEventosBlockchain(ctx context.Context) ([]models.BlockchainEvent, error)
+	ObtenerTousEventosBlockchainPagina(ctx context.Context, consulta ConsultaPaginada) ([]models.BlockchainEvent, string, error)
+	// ParcourirEventosBlockchain lit le segment donné de la table page par page (scan parallèle:
+	// un appel par segment), sans charger la table en mémoire. Une erreur de traiter arrête le parcours.
+	ParcourirEventosBlockchain(ctx context.Context, segmento, totalSegmentos int, traiter func([]models.BlockchainEvent) error) error
+
+	// Points de reprise de la synchronisation par produit
+	ObtenerPuntoSincronizacion(ctx context.Context, idProducto string) (*models.PuntoSincronizacion, error)
+	GuardarPuntoSincronizacion(ctx context.Context, punto *models.PuntoSincronizacion) error
+
+	// Journal des tentatives de vérification (ajout seul)
+	GuardarIntentosVerificacion(ctx context.Context, intentos []models.IntentoVerificacion) error
+	// ListarIntentosVerificacion retourne toutes les tentatives d'un événement, de la plus ancienne à la plus récente
+	ListarIntentosVerificacion(ctx context.Context, idProducto, idEvento string) ([]models.IntentoVerificacion, error)
+}
+
+// ErrConflictoVersion signale un événement modifié depuis sa lecture: il faut le relire avant de
+// réessayer la mise à jour
+var ErrConflictoVersion = errors.New("événement modifié entre-temps (conflit de version)")
+
+// FiltroTareas restreint la liste des tâches (champ vide: pas de filtre)
+type FiltroTareas struct {
+	Status     string
+	IDProducto string
+}
+
+// ClaveEvento identifie un événement vérifié
+type ClaveEvento struct {
+	IDProducto string
+	IDEvento   string
+}
+
+// Vérification à la compilation que les implémentations respectent l'interface
+var (
+	_ HistorialRepository = (*DynamoDBService)(nil)
+	_ HistorialRepository = (*MemoryRepository)(nil)
+)
@@ -0,0 +1,257 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// SignatureVerifier vérifie la FirmaDigital des événements contre le registre d'acteurs.
+// La signature porte sur le hash recalculé du payload producteur (32 octets).
+type SignatureVerifier struct {
+	registry         ActorRegistry
+	firmaObligatoria bool
+}
+
+// NewSignatureVerifier crée un vérificateur de signatures. Si firmaObligatoria est vrai,
+// un événement sans signature est rejeté.
+func NewSignatureVerifier(registry ActorRegistry, firmaObligatoria bool) *SignatureVerifier {
+	return &SignatureVerifier{
+		registry:         registry,
+		firmaObligatoria: firmaObligatoria,
+	}
+}
+
+// VerificarFirma vérifie la signature de l'événement et renseigne FIRMA_INVALIDA en cas d'échec
+func (sv *SignatureVerifier) VerificarFirma(ctx context.Context, evento *models.EventoVerificado) error {
+	if strings.TrimSpace(evento.FirmaDigital) == "" {
+		if sv.firmaObligatoria {
+			return marquerFirmaInvalida(evento, "Signature manquante")
+		}
+		return nil
+	}
+
+	if evento.ActorEmisor == "" {
+		return marquerFirmaInvalida(evento, "Signature sans acteur émetteur")
+	}
+
+	firma, err := decoderFirmaDigital(evento.FirmaDigital)
+	if err != nil {
+		return marquerFirmaInvalida(evento, fmt.Sprintf("Signature illisible: %v", err))
+	}
+
+	// La signature doit couvrir les données réelles, pas seulement le hash déclaré
+	_, hashLocal, err := calcularHashLocalEvento(evento)
+	if err != nil {
+		return marquerFirmaInvalida(evento, fmt.Sprintf("Erreur calcul hash local: %v", err))
+	}
+	digest, err := hex.DecodeString(hashLocal)
+	if err != nil {
+		return marquerFirmaInvalida(evento, fmt.Sprintf("Hash local invalide: %v", err))
+	}
+
+	claves, err := sv.registry.ObtenerClavesActor(ctx, evento.ActorEmisor)
+	if err != nil {
+		return fmt.Errorf("erreur récupération clés acteur %s: %w", evento.ActorEmisor, err)
+	}
+	if len(claves) == 0 {
+		return marquerFirmaInvalida(evento, fmt.Sprintf("Acteur non enregistré: %s", evento.ActorEmisor))
+	}
+
+	// Seules les clés actives à la date de l'événement sont acceptées (rotation, révocation);
+	// la révocation s'applique aussi à la date de réception, que le producteur ne contrôle pas
+	var erreurs []string
+	verifiees := 0
+	for _, clave := range claves {
+		if !clave.ActivaEn(evento.Fecha, evento.CreatedAt) {
+			continue
+		}
+		verifiees++
+		err := verifierAvecClave(clave, firma, digest, evento)
+		if err == nil {
+			return nil
+		}
+		erreurs = append(erreurs, fmt.Sprintf("%s: %v", clave.Algoritmo, err))
+	}
+
+	if verifiees == 0 {
+		return marquerFirmaInvalida(evento, fmt.Sprintf("Aucune clé active pour %s au %s", evento.ActorEmisor, evento.Fecha.Format(time.RFC3339)))
+	}
+
+	return marquerFirmaInvalida(evento, fmt.Sprintf("Signature invalide pour %s (%s)", evento.ActorEmisor, strings.Join(erreurs, "; ")))
+}
+
+// marquerFirmaInvalida renseigne le résultat FIRMA_INVALIDA et retourne l'erreur correspondante
+func marquerFirmaInvalida(evento *models.EventoVerificado, observacion string) error {
+	evento.ResultadoVerificacion = models.VerificacionFirmaInvalida
+	evento.Observaciones = observacion
+	return fmt.Errorf("signature invalide: %s", observacion)
+}
+
+// decoderFirmaDigital accepte une signature brute (hex ou base64) ou la forme JSON FirmaDigital
+func decoderFirmaDigital(valor string) (*models.FirmaDigital, error) {
+	valor = strings.TrimSpace(valor)
+	if strings.HasPrefix(valor, "{") {
+		var firma models.FirmaDigital
+		if err := json.Unmarshal([]byte(valor), &firma); err != nil {
+			return nil, fmt.Errorf("JSON FirmaDigital invalide: %w", err)
+		}
+		return &firma, nil
+	}
+	return &models.FirmaDigital{Firma: valor}, nil
+}
+
+// decoderOctets décode une valeur hexadécimale (préfixe 0x optionnel) ou base64
+func decoderOctets(valor string) ([]byte, error) {
+	valor = strings.TrimSpace(valor)
+	if octets, err := hex.DecodeString(strings.TrimPrefix(valor, "0x")); err == nil {
+		return octets, nil
+	}
+	if octets, err := base64.StdEncoding.DecodeString(valor); err == nil {
+		return octets, nil
+	}
+	return nil, fmt.Errorf("encodage non reconnu (hex ou base64 attendu)")
+}
+
+// verifierAvecClave vérifie la signature avec une clé enregistrée de l'acteur
+func verifierAvecClave(clave models.ClaveActor, firma *models.FirmaDigital, digest []byte, evento *models.EventoVerificado) error {
+	firmaOctets, err := decoderOctets(firma.Firma)
+	if err != nil {
+		return err
+	}
+
+	switch clave.Algoritmo {
+	case models.AlgoritmoFirmaECDSASecp256k1:
+		return verifierECDSASecp256k1(clave.Direccion, firmaOctets, digest)
+	case models.AlgoritmoFirmaEd25519:
+		clavePublica, err := decoderOctets(clave.ClavePublica)
+		if err != nil {
+			return err
+		}
+		if len(clavePublica) != ed25519.PublicKeySize {
+			return fmt.Errorf("clé Ed25519 de taille invalide: %d", len(clavePublica))
+		}
+		if !ed25519.Verify(ed25519.PublicKey(clavePublica), digest, firmaOctets) {
+			return fmt.Errorf("signature Ed25519 incorrecte")
+		}
+		return nil
+	case models.AlgoritmoFirmaX509:
+		certificado, err := certificatSignataire(clave.Certificado, firma.Certificado)
+		if err != nil {
+			return err
+		}
+		if evento.Fecha.Before(certificado.NotBefore) || evento.Fecha.After(certificado.NotAfter) {
+			return fmt.Errorf("certificat non valide à la date de l'événement")
+		}
+		return verifierClePublique(certificado.PublicKey, firmaOctets, digest)
+	default:
+		return fmt.Errorf("algorithme de signature non supporté: %s", clave.Algoritmo)
+	}
+}
+
+// verifierECDSASecp256k1 retrouve l'adresse Ethereum du signataire et la compare à l'adresse enregistrée.
+// Le digest est accepté signé brut ou avec le préfixe EIP-191 (personal_sign).
+func verifierECDSASecp256k1(direccion string, firma, digest []byte) error {
+	if !common.IsHexAddress(direccion) {
+		return fmt.Errorf("adresse enregistrée invalide: %s", direccion)
+	}
+	if len(firma) != 65 {
+		return fmt.Errorf("signature secp256k1 de taille invalide: %d", len(firma))
+	}
+
+	// Normaliser V (27/28 -> 0/1)
+	firmaNormalisee := make([]byte, 65)
+	copy(firmaNormalisee, firma)
+	if firmaNormalisee[64] >= 27 {
+		firmaNormalisee[64] -= 27
+	}
+
+	esperada := common.HexToAddress(direccion)
+	prefixe := fmt.Sprintf("\x19Ethereum Signed Message:\n%d", len(digest))
+	candidats := [][]byte{
+		digest,
+		ethcrypto.Keccak256([]byte(prefixe), digest),
+	}
+
+	for _, candidat := range candidats {
+		clavePublica, err := ethcrypto.SigToPub(candidat, firmaNormalisee)
+		if err != nil {
+			continue
+		}
+		if ethcrypto.PubkeyToAddress(*clavePublica) == esperada {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("adresse récupérée différente de %s", esperada.Hex())
+}
+
+// certificatSignataire retourne le certificat à utiliser: celui enregistré, ou celui joint
+// à la signature s'il est identique ou émis par le certificat enregistré.
+func certificatSignataire(certificadoRegistrado, certificadoFirma string) (*x509.Certificate, error) {
+	registrado, err := parserCertificat(certificadoRegistrado)
+	if err != nil {
+		return nil, fmt.Errorf("certificat enregistré: %w", err)
+	}
+	if strings.TrimSpace(certificadoFirma) == "" {
+		return registrado, nil
+	}
+
+	joint, err := parserCertificat(certificadoFirma)
+	if err != nil {
+		return nil, fmt.Errorf("certificat joint: %w", err)
+	}
+	if bytes.Equal(joint.Raw, registrado.Raw) {
+		return registrado, nil
+	}
+	if err := joint.CheckSignatureFrom(registrado); err != nil {
+		return nil, fmt.Errorf("certificat joint non émis par le certificat enregistré: %w", err)
+	}
+	return joint, nil
+}
+
+// parserCertificat décode un certificat X.509 au format PEM
+func parserCertificat(certificadoPEM string) (*x509.Certificate, error) {
+	bloc, _ := pem.Decode([]byte(certificadoPEM))
+	if bloc == nil {
+		return nil, fmt.Errorf("PEM invalide")
+	}
+	return x509.ParseCertificate(bloc.Bytes)
+}
+
+// verifierClePublique vérifie une signature sur le digest avec la clé publique d'un certificat
+func verifierClePublique(clavePublica interface{}, firma, digest []byte) error {
+	switch cle := clavePublica.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(cle, digest, firma) {
+			return fmt.Errorf("signature ECDSA incorrecte")
+		}
+	case *rsa.PublicKey:
+		if err := rsa.VerifyPKCS1v15(cle, crypto.SHA256, digest, firma); err != nil {
+			return fmt.Errorf("signature RSA incorrecte: %w", err)
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(cle, digest, firma) {
+			return fmt.Errorf("signature Ed25519 incorrecte")
+		}
+	default:
+		return fmt.Errorf("type de clé de certificat non supporté: %T", clavePublica)
+	}
+	return nil
+}
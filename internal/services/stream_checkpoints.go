@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"sync"
+)
+
+// RegistroPuntosStream conserve, pour chaque shard d'un stream DynamoDB, le numéro de séquence du
+// dernier enregistrement traité: le consumer reprend juste après au redémarrage.
+type RegistroPuntosStream interface {
+	// ObtenerSecuenciaShard retourne la dernière séquence traitée du shard ("" si aucune)
+	ObtenerSecuenciaShard(ctx context.Context, idShard string) (string, error)
+	GuardarSecuenciaShard(ctx context.Context, idShard, secuencia string) error
+}
+
+// Vérification à la compilation que les implémentations respectent l'interface
+var (
+	_ RegistroPuntosStream = (*MemoryRegistroPuntosStream)(nil)
+	_ RegistroPuntosStream = (*DynamoDBRegistroPuntosStream)(nil)
+)
+
+// MemoryRegistroPuntosStream garde les séquences en mémoire (perdues au redémarrage)
+type MemoryRegistroPuntosStream struct {
+	mu         sync.RWMutex
+	secuencias map[string]string
+}
+
+// NewMemoryRegistroPuntosStream crée un registre de séquences vide
+func NewMemoryRegistroPuntosStream() *MemoryRegistroPuntosStream {
+	return &MemoryRegistroPuntosStream{
+		secuencias: make(map[string]string),
+	}
+}
+
+// ObtenerSecuenciaShard retourne la dernière séquence traitée du shard
+func (mrp *MemoryRegistroPuntosStream) ObtenerSecuenciaShard(ctx context.Context, idShard string) (string, error) {
+	mrp.mu.RLock()
+	defer mrp.mu.RUnlock()
+
+	return mrp.secuencias[idShard], nil
+}
+
+// GuardarSecuenciaShard enregistre la dernière séquence traitée du shard
+func (mrp *MemoryRegistroPuntosStream) GuardarSecuenciaShard(ctx context.Context, idShard, secuencia string) error {
+	mrp.mu.Lock()
+	defer mrp.mu.Unlock()
+
+	mrp.secuencias[idShard] = secuencia
+	return nil
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuiviOffsets(t *testing.T) {
+	// Une étape lit un message (partition, offset) ou termine le n-ième message lu; aValider est
+	// l'offset retourné par terminer (-1: rien à valider)
+	type etape struct {
+		lire      bool
+		particion int
+		offset    int64
+		terminer  int
+		aValider  int64
+	}
+	lire := func(particion int, offset int64) etape {
+		return etape{lire: true, particion: particion, offset: offset}
+	}
+	terminer := func(n int, aValider int64) etape {
+		return etape{terminer: n, aValider: aValider}
+	}
+
+	tests := []struct {
+		name   string
+		etapes []etape
+	}{
+		{
+			name: "fin dans le désordre: seul le préfixe contigu est validé",
+			etapes: []etape{
+				lire(0, 10), lire(0, 11), lire(0, 12),
+				terminer(2, -1), // 12 attend 10 et 11
+				terminer(1, -1), // 11 attend 10
+				terminer(0, 12), // 10 débloque 11 et 12
+			},
+		},
+		{
+			name: "partitions indépendantes",
+			etapes: []etape{
+				lire(0, 10), lire(1, 50), lire(0, 11),
+				terminer(2, -1), // 11 attend 10 sur la partition 0
+				terminer(1, 50), // la partition 1 n'attend pas la 0
+				terminer(0, 11),
+			},
+		},
+		{
+			name: "offset en arrière après rééquilibrage: la file de la partition repart",
+			etapes: []etape{
+				lire(0, 10), lire(0, 11),
+				lire(0, 10), // Relecture: 10 et 11 sont oubliés
+				terminer(2, 10),
+			},
+		},
+		{
+			name: "fin tardive d'un message oublié: rien n'est validé",
+			etapes: []etape{
+				lire(0, 10), lire(0, 11),
+				lire(0, 10),     // Relecture après rééquilibrage
+				terminer(1, -1), // L'ancien 11 ne valide pas au-delà du nouveau 10 en cours
+				terminer(0, -1),
+				terminer(2, 10),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			suivi := nouveauSuiviOffsets()
+			var lus []*messageEnCours
+
+			for i, e := range tt.etapes {
+				if e.lire {
+					lus = append(lus, suivi.ajouter(kafka.Message{Partition: e.particion, Offset: e.offset}))
+					continue
+				}
+
+				// Act
+				msg, ok := suivi.terminer(lus[e.terminer])
+
+				// Assert
+				if e.aValider < 0 {
+					assert.False(t, ok, "étape %d: rien à valider", i)
+					continue
+				}
+				if assert.True(t, ok, "étape %d: offset %d attendu", i, e.aValider) {
+					assert.Equal(t, e.aValider, msg.Offset, "étape %d", i)
+					assert.Equal(t, lus[e.terminer].msg.Partition, msg.Partition, "étape %d", i)
+				}
+			}
+		})
+	}
+}
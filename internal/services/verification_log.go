@@ -0,0 +1,45 @@
+package services
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+)
+
+// verificadorFirma identifie la vérification de signature dans le journal des vérifications
+const verificadorFirma = "firma"
+
+// formatIntento date les identifiants de tentative à largeur fixe: l'ordre lexicographique de
+// idIntento (clé de tri) suit l'ordre chronologique
+const formatIntento = "2006-01-02T15:04:05.000000000Z"
+
+// nouvelIntentoVerificacion décrit la vérification qui vient d'être faite sur l'événement: résultat
+// obtenu, bloc vu et hash recalculé sur le payload producteur
+func nouvelIntentoVerificacion(evento *models.EventoVerificado, fecha time.Time, verificador, origen string, err error) models.IntentoVerificacion {
+	fecha = fecha.UTC()
+	intento := models.IntentoVerificacion{
+		IDProducto:    evento.IDProducto,
+		IDEvento:      evento.IDEvento,
+		IDIntento:     fecha.Format(formatIntento) + "#" + uuid.NewString()[:8],
+		FechaIntento:  fecha,
+		Verificador:   verificador,
+		Origen:        origen,
+		BloqueNumero:  evento.BloqueNumero,
+		BloqueHash:    evento.BloqueHash,
+		Resultado:     evento.ResultadoVerificacion,
+		Observaciones: evento.Observaciones,
+	}
+	if err != nil {
+		intento.Error = err.Error()
+	}
+
+	// Un payload illisible est déjà signalé par le résultat de la vérification
+	if algoritmo, hash, errHash := calcularHashLocalEvento(evento); errHash == nil {
+		intento.AlgoritmoHash = algoritmo
+		intento.HashCalculado = hash
+	}
+
+	return intento
+}
@@ -93,10 +93,10 @@ echo -e "\n${BLUE}=== TESTS DES ENDPOINTS D'HISTORIQUE ===${NC}"
 call_api "GET" "$API_URL/PROD123" "" "Obtenir historique du produit PROD123"
 
 # Test 5: Obtenir les événements d'un produit avec pagination
-call_api "GET" "$API_URL/PROD123/events?page=1&limit=5" "" "Obtenir événements du produit PROD123 (page 1, limit 5)"
+call_api "GET" "$API_URL/PROD123/events?limit=5" "" "Obtenir événements du produit PROD123 (limit 5)"
 
 # Test 6: Obtenir les événements d'un produit avec filtre de type
-call_api "GET" "$API_URL/PROD123/events?tipoEvento=INGRESO&page=1&limit=10" "" "Obtenir événements INGRESO du produit PROD123"
+call_api "GET" "$API_URL/PROD123/events?tipoEvento=INGRESO&limit=10" "" "Obtenir événements INGRESO du produit PROD123"
 
 # Test 7: Vérifier un événement spécifique
 call_api "GET" "$API_URL/PROD123/verify/EVT456" "" "Vérifier événement EVT456 du produit PROD123"
@@ -111,10 +111,10 @@ call_api "POST" "$API_URL/reconstruir" "$reconstruct_data" "Reconstruire histori
 call_api "GET" "$API_URL/tasks/TASK789" "" "Obtenir statut de la tâche TASK789"
 
 # Test 10: Lister les inconsistances
-call_api "GET" "$API_URL/inconsistencies?page=1&limit=10" "" "Lister les inconsistances (page 1, limit 10)"
+call_api "GET" "$API_URL/inconsistencies?limit=10" "" "Lister les inconsistances (limit 10)"
 
 # Test 11: Lister les inconsistances avec filtre de sévérité
-call_api "GET" "$API_URL/inconsistencies?severidad=ALTA&page=1&limit=5" "" "Lister inconsistances de sévérité ALTA"
+call_api "GET" "$API_URL/inconsistencies?severidad=ALTA&limit=5" "" "Lister inconsistances de sévérité ALTA"
 
 echo -e "\n${BLUE}=== TESTS DES ENDPOINTS DE MÉTRIQUES ===${NC}"
 
@@ -127,7 +127,7 @@ echo -e "\n${BLUE}=== TESTS D'ERREURS ET VALIDATION ===${NC}"
 call_api "GET" "$API_URL/inexistant" "" "Test endpoint inexistant (404 attendu)"
 
 # Test 14: Paramètres invalides
-call_api "GET" "$API_URL/PROD123/events?page=abc&limit=-1" "" "Test paramètres invalides (400 attendu)"
+call_api "GET" "$API_URL/PROD123/events?cursor=abc&limit=-1" "" "Test paramètres invalides (400 attendu)"
 
 # Test 15: POST sans données requises
 call_api "POST" "$API_URL/reconstruir" '{}' "POST sans données requises (400 attendu)"
@@ -31,19 +31,19 @@ curl -s "$BASE_URL/health" | head -5
 echo -e "\n"
 
 echo "2. Obtenir événements du produit PROD123:"
-curl -s "$BASE_URL/api/historial/PROD123/events?page=1&limit=5" | head -10
+curl -s "$BASE_URL/api/historial/PROD123/events?limit=5" | head -10
 echo -e "\n"
 
 echo "3. Obtenir événements de type INGRESO:"
-curl -s "$BASE_URL/api/historial/PROD123/events?tipo=INGRESO&page=1&limit=5" | head -10
+curl -s "$BASE_URL/api/historial/PROD123/events?tipo=INGRESO&limit=5" | head -10
 echo -e "\n"
 
 echo "4. Lister les inconsistances:"
-curl -s "$BASE_URL/api/historial/inconsistencies?page=1&limit=5" | head -10
+curl -s "$BASE_URL/api/historial/inconsistencies?limit=5" | head -10
 echo -e "\n"
 
 echo "5. Lister inconsistances de sévérité ALTA:"
-curl -s "$BASE_URL/api/historial/inconsistencies?severidad=ALTA&page=1&limit=5" | head -10
+curl -s "$BASE_URL/api/historial/inconsistencies?severidad=ALTA&limit=5" | head -10
 echo -e "\n"
 
 echo "🛑 Arrêt du service..."
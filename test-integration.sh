@@ -7,6 +7,43 @@ echo "======================================================================="
 TRANSACCION_API="http://localhost:8080/api/transacciones"
 HISTORIAL_API="http://localhost:8081/api/historial"
 
+# Mode standalone: le service tourne avec RUN_MODE=standalone (sans Kafka, DynamoDB ni blockchain)
+# et les événements sont injectés directement dans son bus en mémoire.
+STANDALONE=${STANDALONE:-false}
+STANDALONE_API="http://localhost:8081/api/standalone"
+
+if [ "$STANDALONE" = "true" ]; then
+    echo "🧪 Mode standalone: injection des événements via $STANDALONE_API/events"
+    INGRESO_RESPONSE=$(curl -s -X POST "$STANDALONE_API/events" \
+      -H "Content-Type: application/json" \
+      -d '{
+        "schemaVersion": "1.0",
+        "idEvento": "EVT-STANDALONE-INGRESO",
+        "tipoEvento": "INGRESO",
+        "idProducto": "PROD123",
+        "lote": "L001",
+        "fechaEvento": "2025-01-15T10:00:00Z",
+        "datosEvento": {"cantidad": 100, "nombreProducto": "Paracetamol 500mg", "fabricante": "Laboratorio ABC"},
+        "actorEmisor": "PROVEEDOR_001"
+      }')
+    echo "Réponse INGRESO: $INGRESO_RESPONSE"
+
+    EGRESO_RESPONSE=$(curl -s -X POST "$STANDALONE_API/events" \
+      -H "Content-Type: application/json" \
+      -d '{
+        "schemaVersion": "1.0",
+        "idEvento": "EVT-STANDALONE-EGRESO",
+        "tipoEvento": "EGRESO",
+        "idProducto": "PROD123",
+        "lote": "L001",
+        "fechaEvento": "2025-01-16T10:00:00Z",
+        "datosEvento": {"cantidad": 50, "destino": "HOSPITAL_001"},
+        "actorEmisor": "DISTRIBUIDOR_001"
+      }')
+    echo "Réponse EGRESO: $EGRESO_RESPONSE"
+    sleep 1
+else
+
 echo "🧪 Étape 1: Création d'événements via TransaccionBlockchain"
 echo "==========================================================="
 
@@ -57,6 +94,7 @@ echo "Réponse EGRESO: $EGRESO_RESPONSE"
 
 # Attendre que les événements soient traités
 sleep 3
+fi
 
 echo ""
 echo "🔍 Étape 2: Test des endpoints HistorialBlockchain avec données réelles"
@@ -0,0 +1,144 @@
+package services_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// eventoSigne construit un événement daté et signé par la clé secp256k1 donnée
+func eventoSigne(t *testing.T, cle *ecdsa.PrivateKey, fecha time.Time) *models.EventoVerificado {
+	t.Helper()
+	datos := map[string]interface{}{"cantidad": 100}
+	firma, err := ethcrypto.Sign(digestDatos(t, datos), cle)
+	require.NoError(t, err)
+
+	return &models.EventoVerificado{
+		IDProducto:   "prod-test-001",
+		IDEvento:     "evt-001",
+		Fecha:        fecha,
+		DatosEvento:  datos,
+		ActorEmisor:  "fabricante-001",
+		FirmaDigital: hex.EncodeToString(firma),
+	}
+}
+
+func TestActorService_RotacionYRevocacion(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	registry := services.NewMemoryActorRegistry()
+	actorService := services.NewActorService(registry)
+	verifier := services.NewSignatureVerifier(registry, true)
+
+	cleAncienne, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	cleNouvelle, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	debut := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	rotation := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	compromission := time.Date(2025, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	actor, err := actorService.RegistrarActor(ctx, &models.RegistrarActorRequest{
+		IDActor:   "fabricante-001",
+		Nombre:    "Laboratorios MediSupply",
+		TipoActor: "fabricante",
+		Claves: []models.ClaveActor{{
+			Algoritmo:   models.AlgoritmoFirmaECDSASecp256k1,
+			Direccion:   ethcrypto.PubkeyToAddress(cleAncienne.PublicKey).Hex(),
+			ValidoDesde: debut,
+		}},
+	})
+	require.NoError(t, err)
+	require.Len(t, actor.Claves, 1)
+
+	// Act: rotation puis révocation de la nouvelle clé
+	nueva, err := actorService.RotarClave(ctx, "fabricante-001", &models.RotarClaveRequest{
+		Algoritmo:   models.AlgoritmoFirmaECDSASecp256k1,
+		Direccion:   ethcrypto.PubkeyToAddress(cleNouvelle.PublicKey).Hex(),
+		ValidoDesde: &rotation,
+	})
+	require.NoError(t, err)
+
+	_, err = actorService.RevocarClave(ctx, "fabricante-001", nueva.IDClave, &models.RevocarClaveRequest{
+		RevocadaEn: &compromission,
+		Motivo:     "clé compromise",
+	})
+	require.NoError(t, err)
+
+	// Assert: chaque signature est jugée avec la clé active à la date de l'événement, la révocation
+	// s'appliquant aussi à sa date de réception
+	tests := []struct {
+		name     string
+		cle      *ecdsa.PrivateKey
+		fecha    time.Time
+		recibido time.Time
+		valide   bool
+	}{
+		{name: "ancienne clé avant rotation", cle: cleAncienne, fecha: rotation.Add(-time.Hour), valide: true},
+		{name: "ancienne clé après rotation", cle: cleAncienne, fecha: rotation.Add(time.Hour)},
+		{name: "nouvelle clé avant son début", cle: cleNouvelle, fecha: rotation.Add(-time.Hour)},
+		{name: "nouvelle clé avant révocation", cle: cleNouvelle, fecha: compromission.Add(-time.Hour), recibido: compromission.Add(-time.Minute), valide: true},
+		{name: "nouvelle clé après révocation", cle: cleNouvelle, fecha: compromission.Add(time.Hour)},
+		{name: "nouvelle clé antidatée, reçue après révocation", cle: cleNouvelle, fecha: compromission.Add(-time.Hour), recibido: compromission.Add(time.Hour)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			evento := eventoSigne(t, tt.cle, tt.fecha)
+			evento.CreatedAt = tt.recibido
+
+			err := verifier.VerificarFirma(ctx, evento)
+
+			if tt.valide {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+				assert.Equal(t, models.VerificacionFirmaInvalida, evento.ResultadoVerificacion)
+			}
+		})
+	}
+}
+
+func TestActorService_Errores(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	actorService := services.NewActorService(services.NewMemoryActorRegistry())
+
+	// Act & Assert
+	_, err := actorService.RegistrarActor(ctx, &models.RegistrarActorRequest{
+		IDActor: "distribuidor-001",
+		Claves:  []models.ClaveActor{{Algoritmo: models.AlgoritmoFirmaEd25519, ClavePublica: "abcd"}},
+	})
+	assert.True(t, errors.Is(err, services.ErrSolicitudInvalida))
+
+	_, err = actorService.ObtenerActor(ctx, "distribuidor-001")
+	assert.True(t, errors.Is(err, services.ErrActorNoEncontrado), "aucune écriture après une clé invalide")
+
+	_, err = actorService.RegistrarActor(ctx, &models.RegistrarActorRequest{IDActor: "pharmacie-001"})
+	require.NoError(t, err)
+	_, err = actorService.RegistrarActor(ctx, &models.RegistrarActorRequest{
+		IDActor: "pharmacie-001",
+		Claves:  []models.ClaveActor{{Algoritmo: models.AlgoritmoFirmaECDSASecp256k1, Direccion: "0x00000000000000000000000000000000000000aa"}},
+	})
+	assert.True(t, errors.Is(err, services.ErrActorExistente), "réenregistrement refusé")
+	actor, err := actorService.ObtenerActor(ctx, "pharmacie-001")
+	require.NoError(t, err)
+	assert.Empty(t, actor.Claves, "aucune clé ouverte ajoutée hors rotation")
+
+	_, err = actorService.RotarClave(ctx, "inconnu", &models.RotarClaveRequest{Algoritmo: models.AlgoritmoFirmaEd25519})
+	assert.True(t, errors.Is(err, services.ErrActorNoEncontrado))
+
+	_, err = actorService.RevocarClave(ctx, "inconnu", "cle-001", &models.RevocarClaveRequest{})
+	assert.True(t, errors.Is(err, services.ErrClaveNoEncontrada))
+}
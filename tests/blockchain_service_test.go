@@ -0,0 +1,86 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// noeudRPC simule un nœud JSON-RPC: repond(methode) retourne le statut HTTP et le résultat de l'appel
+func noeudRPC(t *testing.T, repond func(methode string) (int, interface{})) *services.BlockchainService {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var requete struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requete))
+
+		statut, resultat := repond(requete.Method)
+		if statut != http.StatusOK {
+			http.Error(w, http.StatusText(statut), statut)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"jsonrpc": "2.0", "id": requete.ID, "result": resultat})
+	}))
+	t.Cleanup(server.Close)
+
+	bs, err := services.NewBlockchainService(server.URL, 2*time.Second, 1, services.ReglasAnclaje{})
+	require.NoError(t, err)
+	t.Cleanup(bs.Close)
+	return bs
+}
+
+func TestBlockchainService_VerificarIntegridad_ErreursRPC(t *testing.T) {
+	tests := []struct {
+		name         string
+		repond       func(methode string) (int, interface{})
+		indisponible bool
+		resultado    string
+	}{
+		{
+			name:         "nœud en panne: aucun verdict",
+			repond:       func(string) (int, interface{}) { return http.StatusServiceUnavailable, nil },
+			indisponible: true,
+			resultado:    models.VerificacionPendienteConfirmacion,
+		},
+		{
+			name:      "transaction inconnue du nœud",
+			repond:    func(string) (int, interface{}) { return http.StatusOK, nil },
+			resultado: models.VerificacionNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			bs := noeudRPC(t, tt.repond)
+			evento := &models.EventoVerificado{
+				IDProducto:            "PROD-RPC",
+				IDEvento:              "EVT-RPC",
+				ReferenciaBlockchain:  "0xabc",
+				DatosEvento:           map[string]interface{}{"cantidad": 1},
+				ResultadoVerificacion: models.VerificacionPendienteConfirmacion,
+			}
+
+			// Act
+			err := bs.VerificarIntegridad(context.Background(), evento)
+
+			// Assert
+			require.Error(t, err)
+			assert.Equal(t, tt.indisponible, errors.Is(err, services.ErrRegistroIndisponible))
+			assert.Equal(t, tt.resultado, evento.ResultadoVerificacion)
+		})
+	}
+}
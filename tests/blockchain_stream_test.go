@@ -0,0 +1,196 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodbstreams"
+	streamstypes "github.com/aws/aws-sdk-go-v2/service/dynamodbstreams/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// streamFactice simule un stream DynamoDB: des shards ordonnés, chacun ouvert ou fermé
+type streamFactice struct {
+	shards       []streamstypes.Shard
+	records      map[string][]streamstypes.Record
+	fermes       map[string]bool
+	typesOuverts []streamstypes.ShardIteratorType
+}
+
+func (sf *streamFactice) DescribeStream(ctx context.Context, params *dynamodbstreams.DescribeStreamInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.DescribeStreamOutput, error) {
+	return &dynamodbstreams.DescribeStreamOutput{
+		StreamDescription: &streamstypes.StreamDescription{Shards: sf.shards},
+	}, nil
+}
+
+func (sf *streamFactice) GetShardIterator(ctx context.Context, params *dynamodbstreams.GetShardIteratorInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetShardIteratorOutput, error) {
+	idShard := aws.ToString(params.ShardId)
+	sf.typesOuverts = append(sf.typesOuverts, params.ShardIteratorType)
+
+	position := 0
+	if params.ShardIteratorType == streamstypes.ShardIteratorTypeAfterSequenceNumber {
+		for i, record := range sf.records[idShard] {
+			if aws.ToString(record.Dynamodb.SequenceNumber) == aws.ToString(params.SequenceNumber) {
+				position = i + 1
+			}
+		}
+	}
+	return &dynamodbstreams.GetShardIteratorOutput{ShardIterator: aws.String(fmt.Sprintf("%s|%d", idShard, position))}, nil
+}
+
+func (sf *streamFactice) GetRecords(ctx context.Context, params *dynamodbstreams.GetRecordsInput, optFns ...func(*dynamodbstreams.Options)) (*dynamodbstreams.GetRecordsOutput, error) {
+	parties := strings.Split(aws.ToString(params.ShardIterator), "|")
+	idShard := parties[0]
+	position, _ := strconv.Atoi(parties[1])
+
+	records := sf.records[idShard][position:]
+	sortie := &dynamodbstreams.GetRecordsOutput{Records: records}
+	if !sf.fermes[idShard] {
+		sortie.NextShardIterator = aws.String(fmt.Sprintf("%s|%d", idShard, len(sf.records[idShard])))
+	}
+	return sortie, nil
+}
+
+func recordStream(operation streamstypes.OperationType, secuencia, idTransaction string) streamstypes.Record {
+	return streamstypes.Record{
+		EventID:   aws.String("evt-" + secuencia),
+		EventName: operation,
+		Dynamodb: &streamstypes.StreamRecord{
+			SequenceNumber: aws.String(secuencia),
+			NewImage: map[string]streamstypes.AttributeValue{
+				"idTransaction": &streamstypes.AttributeValueMemberS{Value: idTransaction},
+				"idProducto":    &streamstypes.AttributeValueMemberS{Value: "prod-stream-001"},
+				"tipoEvento":    &streamstypes.AttributeValueMemberS{Value: "fabricacion"},
+				"fechaEvento":   &streamstypes.AttributeValueMemberS{Value: "2025-11-04T02:10:07Z"},
+				"datosEvento":   &streamstypes.AttributeValueMemberS{Value: `{"cantidad": 10}`},
+			},
+		},
+	}
+}
+
+func TestConsumidorStreamBlockchain_Sondear(t *testing.T) {
+	// Arrange: un shard parent fermé et son enfant ouvert
+	ctx := context.Background()
+	stream := &streamFactice{
+		shards: []streamstypes.Shard{
+			{ShardId: aws.String("shard-enfant"), ParentShardId: aws.String("shard-parent")},
+			{ShardId: aws.String("shard-parent")},
+		},
+		records: map[string][]streamstypes.Record{
+			"shard-parent": {
+				recordStream(streamstypes.OperationTypeInsert, "100", "tx-001"),
+				recordStream(streamstypes.OperationTypeModify, "101", "tx-001"),
+			},
+			"shard-enfant": {
+				recordStream(streamstypes.OperationTypeInsert, "200", "tx-002"),
+				recordStream(streamstypes.OperationTypeRemove, "201", "tx-003"),
+			},
+		},
+		fermes: map[string]bool{"shard-parent": true},
+	}
+	repo := services.NewMemoryRepository()
+	puntos := services.NewMemoryRegistroPuntosStream()
+	service := services.NewHistorialService(repo, nil, nil, false)
+	consumidor := services.NewConsumidorStreamBlockchain(stream, "arn:stream", service, puntos, 0)
+
+	// Act: l'enfant attend la fin du parent, lu à la passe suivante
+	premiere, err := consumidor.Sondear(ctx)
+	require.NoError(t, err)
+	seconde, err := consumidor.Sondear(ctx)
+	require.NoError(t, err)
+
+	// Assert
+	assert.Equal(t, 2, premiere)
+	assert.Equal(t, 2, seconde)
+
+	eventos, err := repo.ObtenerEventos(ctx, "prod-stream-001")
+	require.NoError(t, err)
+	require.Len(t, eventos, 2) // La suppression n'efface pas l'historique
+	assert.Equal(t, "tx-001", eventos[0].IDEvento)
+	assert.Equal(t, "tx-002", eventos[1].IDEvento)
+
+	secuencia, _ := puntos.ObtenerSecuenciaShard(ctx, "shard-enfant")
+	assert.Equal(t, "201", secuencia)
+
+	// Act: un nouveau consumer reprend après la séquence enregistrée
+	stream.records["shard-enfant"] = append(stream.records["shard-enfant"], recordStream(streamstypes.OperationTypeInsert, "202", "tx-004"))
+	stream.typesOuverts = nil
+	redemarre := services.NewConsumidorStreamBlockchain(stream, "arn:stream", service, puntos, 0)
+	traites := 0
+	for i := 0; i < 2; i++ {
+		n, err := redemarre.Sondear(ctx)
+		require.NoError(t, err)
+		traites += n
+	}
+
+	// Assert
+	assert.Equal(t, 1, traites)
+	assert.Contains(t, stream.typesOuverts, streamstypes.ShardIteratorTypeAfterSequenceNumber)
+	eventos, err = repo.ObtenerEventos(ctx, "prod-stream-001")
+	require.NoError(t, err)
+	assert.Len(t, eventos, 3)
+}
+
+func TestConsumidorStreamBlockchain_ModificationsDesEvenementsExistants(t *testing.T) {
+	// Arrange: une ligne insérée en attente, lue à la première passe
+	ctx := context.Background()
+	avecImage := func(record streamstypes.Record, attribut, valeur string) streamstypes.Record {
+		record.Dynamodb.NewImage[attribut] = &streamstypes.AttributeValueMemberS{Value: valeur}
+		return record
+	}
+	stream := &streamFactice{
+		shards: []streamstypes.Shard{{ShardId: aws.String("shard-001")}},
+		records: map[string][]streamstypes.Record{
+			"shard-001": {avecImage(recordStream(streamstypes.OperationTypeInsert, "100", "tx-010"), "estado", "pendiente")},
+		},
+	}
+	repo := services.NewMemoryRepository()
+	bus := services.NewMemoryEventBus(1)
+	service := services.NewHistorialService(repo, nil, bus, false)
+	consumidor := services.NewConsumidorStreamBlockchain(stream, "arn:stream", service, services.NewMemoryRegistroPuntosStream(), 0)
+
+	_, err := consumidor.Sondear(ctx)
+	require.NoError(t, err)
+	evento, err := repo.ObtenerEvento(ctx, "prod-stream-001", "tx-010")
+	require.NoError(t, err)
+	require.NotNil(t, evento)
+	assert.Equal(t, "PENDING", evento.ResultadoVerificacion)
+
+	// Act: la ligne est confirmée
+	stream.records["shard-001"] = append(stream.records["shard-001"],
+		avecImage(recordStream(streamstypes.OperationTypeModify, "101", "tx-010"), "estado", "confirmado"))
+	_, err = consumidor.Sondear(ctx)
+	require.NoError(t, err)
+
+	// Assert: le nouvel état est enregistré par la mise à jour versionnée
+	evento, err = repo.ObtenerEvento(ctx, "prod-stream-001", "tx-010")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, evento.ResultadoVerificacion)
+	assert.Equal(t, int64(2), evento.Version)
+	assert.Empty(t, bus.Publicados())
+
+	// Act: le payload ancré de la ligne est réécrit
+	stream.records["shard-001"] = append(stream.records["shard-001"],
+		avecImage(recordStream(streamstypes.OperationTypeModify, "102", "tx-010"), "datosEvento", `{"cantidad": 99}`))
+	_, err = consumidor.Sondear(ctx)
+	require.NoError(t, err)
+
+	// Assert: inconsistance publiée, l'événement enregistré reste la référence
+	publicados := bus.Publicados()
+	require.Len(t, publicados, 1)
+	assert.Equal(t, services.EventTypeHistorialInconsistencia, publicados[0].EventType)
+	assert.Contains(t, string(publicados[0].Payload), "datosEvento")
+
+	evento, err = repo.ObtenerEvento(ctx, "prod-stream-001", "tx-010")
+	require.NoError(t, err)
+	assert.Equal(t, float64(10), evento.DatosEvento["cantidad"])
+	assert.Equal(t, int64(2), evento.Version)
+}
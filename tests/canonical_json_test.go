@@ -0,0 +1,68 @@
+package services_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestCanonicalizarJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		valor   interface{}
+		attendu string
+	}{
+		{
+			name:    "clés triées et sans espaces",
+			valor:   map[string]interface{}{"b": 1, "a": []interface{}{true, nil, "x"}},
+			attendu: `{"a":[true,null,"x"],"b":1}`,
+		},
+		{
+			name:    "nombres au format ECMAScript (RFC 8785 §3.2.2.3)",
+			valor:   []interface{}{333333333.33333329, 1e30, 4.50, 2e-3, 0.000000000000000000000000001, -0.0, 1e21, 1e-7, 100},
+			attendu: `[333333333.3333333,1e+30,4.5,0.002,1e-27,0,1e+21,1e-7,100]`,
+		},
+		{
+			name:    "échappement minimal des chaînes",
+			valor:   map[string]interface{}{"s": "€$\u000f\nA'B\"\\\\\"/<>&\u2028"},
+			attendu: "{\"s\":\"€$\\u000f\\nA'B\\\"\\\\\\\\\\\"/<>&\u2028\"}",
+		},
+		{
+			name:    "tri par unités UTF-16",
+			valor:   map[string]interface{}{"\u20ac": "Euro", "\r": "CR", "\ufb33": "Hebrew", "1": "One", "\U0001f600": "Emoji", "\u0080": "Control", "\u00f6": "Latin"},
+			attendu: "{\"\\r\":\"CR\",\"1\":\"One\",\"\u0080\":\"Control\",\"\u00f6\":\"Latin\",\"\u20ac\":\"Euro\",\"\U0001f600\":\"Emoji\",\"\ufb33\":\"Hebrew\"}",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resultat, err := services.CanonicalizarJSON(tt.valor)
+			require.NoError(t, err)
+			assert.Equal(t, tt.attendu, string(resultat))
+		})
+	}
+}
+
+func TestCalcularHashDatos_IndependantDeLaRepresentation(t *testing.T) {
+	// Le même payload décodé différemment (int vs float64, ordre d'insertion) doit donner le même hash
+	datosProducteur := map[string]interface{}{"lote": "LOT-12345", "cantidad": 1000, "planta": "Planta A"}
+	datosStockes := map[string]interface{}{"planta": "Planta A", "cantidad": float64(1000), "lote": "LOT-12345"}
+
+	hashProducteur, err := services.CalcularHashDatos(datosProducteur, models.AlgoritmoSHA256JCS)
+	require.NoError(t, err)
+	hashStocke, err := services.CalcularHashDatos(datosStockes, models.AlgoritmoSHA256JCS)
+	require.NoError(t, err)
+
+	attendu := sha256.Sum256([]byte(`{"cantidad":1000,"lote":"LOT-12345","planta":"Planta A"}`))
+	assert.Equal(t, hex.EncodeToString(attendu[:]), hashProducteur)
+	assert.Equal(t, hashProducteur, hashStocke)
+
+	_, err = services.CalcularHashDatos(datosProducteur, "MD5")
+	assert.Error(t, err)
+}
@@ -0,0 +1,110 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestConfirmationMonitor_RevisarPendientes(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	datos := map[string]interface{}{"cantidad": 100}
+	repo := services.NewMemoryRepository()
+	ledger := services.NewLocalLedger()
+	ledger.DefinirConfirmaciones(3)
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xaaa", HashAnclado: hashDatos(t, datos), BloqueNumero: 10})
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xbbb", HashAnclado: hashDatos(t, datos), BloqueNumero: 11})
+	service := services.NewHistorialService(repo, ledger, services.NewMemoryEventBus(1), true)
+	monitor := services.NewConfirmationMonitor(repo, ledger, 3, time.Minute)
+
+	for idEvento, txHash := range map[string]string{"evt-confirme": "0xaaa", "evt-reorg": "0xbbb"} {
+		require.NoError(t, service.TraiterEvenementTransaccion(ctx, &models.TransaccionBlockchainEvent{
+			IDEvento:            idEvento,
+			IDProducto:          "prod-test-001",
+			FechaEvento:         time.Now(),
+			DatosEvento:         datos,
+			HashEvento:          hashDatos(t, datos),
+			DireccionBlockchain: txHash,
+		}))
+	}
+
+	pendientes, err := repo.ListarEventosPorResultado(ctx, models.VerificacionPendienteConfirmacion)
+	require.NoError(t, err)
+	require.Len(t, pendientes, 2)
+	assert.Equal(t, uint64(10), pendientes[0].BloqueNumero)
+	assert.NotEmpty(t, pendientes[0].BloqueHash)
+
+	// Act: profondeur non atteinte, rien ne change
+	actualizados, err := monitor.RevisarPendientes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, actualizados)
+
+	// Act: le bloc 11 est réorganisé puis la chaîne avance jusqu'à 12
+	ledger.ReorganizarBloque(11, "0xfork")
+	ledger.DefinirBloqueActual(12)
+	actualizados, err = monitor.RevisarPendientes(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 2, actualizados)
+
+	confirme, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-confirme")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, confirme.ResultadoVerificacion)
+
+	reorg, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-reorg")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionNotFound, reorg.ResultadoVerificacion)
+	assert.Contains(t, reorg.Observaciones, "Réorganisation")
+}
+
+func TestConfirmationMonitor_TransactionEnAttente(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	datos := map[string]interface{}{"cantidad": 100}
+	repo := services.NewMemoryRepository()
+	ledger := services.NewLocalLedger()
+	ledger.DefinirConfirmaciones(1)
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xccc", HashAnclado: hashDatos(t, datos), EnAttente: true})
+	service := services.NewHistorialService(repo, ledger, services.NewMemoryEventBus(1), true)
+	monitor := services.NewConfirmationMonitor(repo, ledger, 1, time.Minute)
+
+	require.NoError(t, service.TraiterEvenementTransaccion(ctx, &models.TransaccionBlockchainEvent{
+		IDEvento:            "evt-en-attente",
+		IDProducto:          "prod-test-001",
+		FechaEvento:         time.Now(),
+		DatosEvento:         datos,
+		HashEvento:          hashDatos(t, datos),
+		DireccionBlockchain: "0xccc",
+	}))
+
+	evento, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-en-attente")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionPendienteConfirmacion, evento.ResultadoVerificacion)
+	assert.Empty(t, evento.BloqueHash)
+
+	// Act: toujours sans reçu, rien ne change
+	actualizados, err := monitor.RevisarPendientes(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 0, actualizados)
+
+	// Act: la transaction est incluse dans le bloc 5
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xccc", HashAnclado: hashDatos(t, datos), BloqueNumero: 5})
+	actualizados, err = monitor.RevisarPendientes(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 1, actualizados)
+
+	evento, err = repo.ObtenerEvento(ctx, "prod-test-001", "evt-en-attente")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, evento.ResultadoVerificacion)
+	assert.Equal(t, uint64(5), evento.BloqueNumero)
+}
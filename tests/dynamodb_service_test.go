@@ -0,0 +1,165 @@
+package services_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// tableEvenementsFactice simule l'API DynamoDB pour un seul événement déjà enregistré: PutItem
+// échoue sur la condition, UpdateItem n'accepte que la version enregistrée. Les erreurs passent par
+// le client du SDK, qui les enveloppe comme en production.
+type tableEvenementsFactice struct {
+	mu         sync.Mutex
+	item       map[string]types.AttributeValue
+	version    int64
+	misesAJour []string // Version attendue de chaque UpdateItem reçu
+}
+
+func (tf *tableEvenementsFactice) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	tf.mu.Lock()
+	defer tf.mu.Unlock()
+
+	var requete struct {
+		ExpressionAttributeValues map[string]map[string]interface{}
+	}
+	if err := json.NewDecoder(r.Body).Decode(&requete); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-amz-json-1.0")
+	echecCondition := func(avecItem bool) {
+		reponse := map[string]interface{}{
+			"__type":  "com.amazonaws.dynamodb.v20120810#ConditionalCheckFailedException",
+			"message": "The conditional request failed",
+		}
+		if avecItem {
+			reponse["Item"] = attributsJSON(tf.item)
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(reponse)
+	}
+
+	switch operation := strings.TrimPrefix(r.Header.Get("X-Amz-Target"), "DynamoDB_20120810."); operation {
+	case "PutItem":
+		echecCondition(false)
+	case "GetItem":
+		json.NewEncoder(w).Encode(map[string]interface{}{"Item": attributsJSON(tf.item)})
+	case "UpdateItem":
+		attendue := requete.ExpressionAttributeValues[":attendue"]["N"]
+		tf.misesAJour = append(tf.misesAJour, attendue.(string))
+		if attendue != strconv.FormatInt(tf.version, 10) {
+			echecCondition(true)
+			return
+		}
+		tf.version++
+		tf.item["version"] = &types.AttributeValueMemberN{Value: strconv.FormatInt(tf.version, 10)}
+		tf.item["resultadoVerificacion"] = &types.AttributeValueMemberS{Value: requete.ExpressionAttributeValues[":resultado"]["S"].(string)}
+		json.NewEncoder(w).Encode(map[string]interface{}{})
+	default:
+		http.Error(w, "opération non simulée: "+operation, http.StatusNotImplemented)
+	}
+}
+
+// attributsJSON encode des attributs au format JSON de l'API DynamoDB
+func attributsJSON(item map[string]types.AttributeValue) map[string]interface{} {
+	sortie := make(map[string]interface{}, len(item))
+	for nom, valeur := range item {
+		sortie[nom] = attributJSON(valeur)
+	}
+	return sortie
+}
+
+func attributJSON(valeur types.AttributeValue) interface{} {
+	switch v := valeur.(type) {
+	case *types.AttributeValueMemberS:
+		return map[string]interface{}{"S": v.Value}
+	case *types.AttributeValueMemberN:
+		return map[string]interface{}{"N": v.Value}
+	case *types.AttributeValueMemberBOOL:
+		return map[string]interface{}{"BOOL": v.Value}
+	case *types.AttributeValueMemberNULL:
+		return map[string]interface{}{"NULL": v.Value}
+	case *types.AttributeValueMemberM:
+		return map[string]interface{}{"M": attributsJSON(v.Value)}
+	case *types.AttributeValueMemberL:
+		liste := make([]interface{}, 0, len(v.Value))
+		for _, element := range v.Value {
+			liste = append(liste, attributJSON(element))
+		}
+		return map[string]interface{}{"L": liste}
+	default:
+		panic("type d'attribut non simulé")
+	}
+}
+
+func TestDynamoDBService_EvenementDejaEnregistre_VerificationEnregistree(t *testing.T) {
+	// Arrange: l'événement est déjà enregistré (version 3), son ancrage est confirmé
+	ctx := context.Background()
+	datos := map[string]interface{}{"cantidad": 100}
+	hash := hashDatos(t, datos)
+
+	item, err := attributevalue.MarshalMap(models.EventoVerificado{
+		IDProducto:            "prod-ddb-001",
+		IDEvento:              "evt-001",
+		Fecha:                 time.Now().UTC(),
+		DatosEvento:           datos,
+		HashEvento:            hash,
+		HashCriptografico:     models.HashCriptografico{Algoritmo: models.AlgoritmoSHA256JCS, ValorHash: hash},
+		ReferenciaBlockchain:  "0xddb",
+		ResultadoVerificacion: models.VerificacionPendienteConfirmacion,
+		Version:               3,
+	})
+	require.NoError(t, err)
+	table := &tableEvenementsFactice{item: item, version: 3}
+	server := httptest.NewServer(table)
+	defer server.Close()
+
+	client := dynamodb.NewFromConfig(aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("test", "test", ""),
+	}, func(o *dynamodb.Options) {
+		o.BaseEndpoint = aws.String(server.URL)
+	})
+	repo := services.NewDynamoDBService(client, "historial", "evento_verificado", "blockchain")
+
+	ledger := services.NewLocalLedger()
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xddb", HashAnclado: hash, BloqueNumero: 7})
+	service := services.NewHistorialService(repo, ledger, nil, true)
+
+	// Act: le message est relivré (au moins une fois)
+	err = service.TraiterEvenementTransaccion(ctx, &models.TransaccionBlockchainEvent{
+		IDEvento:            "evt-001",
+		IDProducto:          "prod-ddb-001",
+		FechaEvento:         time.Now(),
+		DatosEvento:         datos,
+		HashEvento:          hash,
+		DireccionBlockchain: "0xddb",
+	})
+
+	// Assert: le doublon est idempotent, le résultat est enregistré après relecture de la version
+	require.NoError(t, err)
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	assert.Equal(t, []string{"0", "3"}, table.misesAJour)
+	assert.Equal(t, int64(4), table.version)
+	assert.Equal(t, &types.AttributeValueMemberS{Value: models.VerificacionOK}, table.item["resultadoVerificacion"])
+}
@@ -0,0 +1,117 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestDecodificarEventoTransaccion_VersionCourante(t *testing.T) {
+	// Arrange
+	message := []byte(`{
+		"schemaVersion": "1.0",
+		"idEvento": "evt-001",
+		"tipoEvento": "Ingreso",
+		"idProducto": "prod-test-001",
+		"fechaEvento": "2025-01-15T10:00:00Z",
+		"datosEvento": {"cantidad": 100, "temperatura": 4.5},
+		"champFutur": true
+	}`)
+
+	// Act
+	event, err := services.DecodificarEventoTransaccion(message)
+
+	// Assert: les propriétés inconnues sont tolérées
+	require.NoError(t, err)
+	assert.Equal(t, "evt-001", event.IDEvento)
+	assert.Equal(t, float64(100), event.DatosEvento["cantidad"])
+	assert.Equal(t, 4.5, event.DatosEvento["temperatura"])
+}
+
+func TestDecodificarEventoTransaccion_Upcaster09(t *testing.T) {
+	// Arrange: en 0.9, datosEvento est une chaîne JSON
+	message := []byte(`{
+		"schemaVersion": "0.9",
+		"idEvento": "evt-001",
+		"tipoEvento": "Ingreso",
+		"idProducto": "prod-test-001",
+		"fechaEvento": "2025-01-15T10:00:00Z",
+		"datosEvento": "{\"cantidad\": 100, \"nombreProducto\": \"Paracetamol 500mg\"}"
+	}`)
+
+	// Act
+	event, err := services.DecodificarEventoTransaccion(message)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, services.VersionEsquemaTransaccion, event.SchemaVersion)
+	assert.Equal(t, map[string]interface{}{"cantidad": float64(100), "nombreProducto": "Paracetamol 500mg"}, event.DatosEvento)
+
+	// Un datosEvento 0.9 qui n'encode pas un objet est rejeté
+	_, err = services.DecodificarEventoTransaccion([]byte(`{"schemaVersion": "0.9", "idEvento": "evt-002", "tipoEvento": "Ingreso",
+		"idProducto": "prod-test-001", "fechaEvento": "2025-01-15T10:00:00Z", "datosEvento": "[1, 2]"}`))
+	assert.ErrorIs(t, err, services.ErrMensajeInvalido)
+}
+
+func TestDecodificarEventoTransaccion_Rejets(t *testing.T) {
+	tests := []struct {
+		nombre    string
+		message   string
+		erreur    error
+		contenant string
+	}{
+		{"version inconnue", `{"schemaVersion": "2.0", "idEvento": "evt-001"}`, services.ErrVersionEsquemaDesconocida, `"2.0"`},
+		{"version absente", `{"idEvento": "evt-001"}`, services.ErrVersionEsquemaDesconocida, `""`},
+		{"champ requis absent", `{"schemaVersion": "1.0", "idEvento": "evt-001", "tipoEvento": "Ingreso", "idProducto": "prod-test-001",
+			"datosEvento": {}}`, services.ErrMensajeInvalido, "$.fechaEvento: requis"},
+		{"type incorrect", `{"schemaVersion": "1.0", "idEvento": "evt-001", "tipoEvento": "Ingreso", "idProducto": "prod-test-001",
+			"fechaEvento": "2025-01-15T10:00:00Z", "datosEvento": "{}"}`, services.ErrMensajeInvalido, "$.datosEvento: type object attendu"},
+		{"date invalide", `{"schemaVersion": "1.0", "idEvento": "evt-001", "tipoEvento": "Ingreso", "idProducto": "prod-test-001",
+			"fechaEvento": "15/01/2025", "datosEvento": {}}`, services.ErrMensajeInvalido, "$.fechaEvento: date-time"},
+		{"JSON illisible", `{invalide`, services.ErrMensajeInvalido, "JSON illisible"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.nombre, func(t *testing.T) {
+			// Act
+			_, err := services.DecodificarEventoTransaccion([]byte(tt.message))
+
+			// Assert
+			require.ErrorIs(t, err, tt.erreur)
+			assert.Contains(t, err.Error(), tt.contenant)
+		})
+	}
+}
+
+func TestMemoryEventBus_VersionInconnueEnDLQ(t *testing.T) {
+	// Arrange
+	bus := services.NewMemoryEventBus(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.ConsumeEvents(ctx, func(*models.TransaccionBlockchainEvent) error { return nil })
+	}()
+
+	// Act
+	evento := eventoTransaccion("prod-test-001", "evt-001")
+	evento.SchemaVersion = "2.0"
+	require.NoError(t, bus.PublicarTransaccion(ctx, evento))
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		return len(bus.LettresMortes()) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	assert.Equal(t, services.MotivoVersionDesconocida, bus.LettresMortes()[0].Motivo)
+}
@@ -0,0 +1,127 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestHistorialService_SynchroniserTousLesEventosBlockchain(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := services.NewMemoryRepository()
+	for i := 0; i < 250; i++ {
+		repo.AgregarEventoBlockchain(models.BlockchainEvent{
+			IDTransaction: fmt.Sprintf("tx-%04d", i),
+			IDProducto:    fmt.Sprintf("prod-%d", i%5),
+			TipoEvento:    "fabricacion",
+			FechaEvento:   "2025-11-04T02:10:07Z",
+			DatosEvento:   fmt.Sprintf(`{"cantidad": %d}`, i),
+		})
+	}
+
+	service := services.NewHistorialService(repo, nil, nil, false)
+	service.DefinirParalelismoSincronizacion(services.ParalelismoSincronizacion{Segmentos: 3, Workers: 4})
+
+	var rapports []models.ProgresoSincronizacion
+	rapport := func(progreso models.ProgresoSincronizacion) {
+		rapports = append(rapports, progreso)
+	}
+
+	// Act
+	progreso, err := service.SynchroniserTousLesEventosBlockchain(ctx, rapport)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, int64(250), progreso.Leidos)
+	assert.Equal(t, int64(250), progreso.Sincronizados)
+	assert.Equal(t, int64(0), progreso.Errores)
+	assert.NotNil(t, progreso.TerminadoEn)
+	require.NotEmpty(t, rapports)
+	assert.Equal(t, *progreso, rapports[len(rapports)-1])
+
+	for p := 0; p < 5; p++ {
+		eventos, err := repo.ObtenerEventos(ctx, fmt.Sprintf("prod-%d", p))
+		require.NoError(t, err)
+		assert.Len(t, eventos, 50)
+	}
+
+	// Une seconde passe ne crée rien
+	progreso, err = service.SynchroniserTousLesEventosBlockchain(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), progreso.Sincronizados)
+	assert.Equal(t, int64(250), progreso.Existentes)
+}
+
+func TestHistorialService_SynchroniserTousLesEventosBlockchain_AvancePointsDeReprise(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := services.NewMemoryRepository()
+	for i := 0; i < 40; i++ {
+		repo.AgregarEventoBlockchain(models.BlockchainEvent{
+			IDTransaction: fmt.Sprintf("tx-%04d", i),
+			IDProducto:    fmt.Sprintf("prod-%d", i%2),
+			TipoEvento:    "fabricacion",
+			FechaEvento:   "2025-11-04T02:10:07Z",
+			DatosEvento:   fmt.Sprintf(`{"cantidad": %d}`, i),
+			UpdatedAt:     fmt.Sprintf("2025-11-04T02:%02d:00Z", i),
+		})
+	}
+
+	service := services.NewHistorialService(repo, nil, nil, false)
+	service.DefinirParalelismoSincronizacion(services.ParalelismoSincronizacion{Segmentos: 3, Workers: 4})
+
+	// Act
+	_, err := service.SynchroniserTousLesEventosBlockchain(ctx, nil)
+
+	// Assert: chaque produit reprend après sa dernière ligne
+	require.NoError(t, err)
+	for p, ultima := range map[string]string{"prod-0": "tx-0038", "prod-1": "tx-0039"} {
+		punto, err := repo.ObtenerPuntoSincronizacion(ctx, p)
+		require.NoError(t, err)
+		require.NotNil(t, punto, p)
+		assert.Equal(t, ultima, punto.UltimoIDTransaction)
+		assert.False(t, punto.SincronizadoEn.IsZero())
+
+		eventos, err := repo.ObtenerEventosBlockchainDesde(ctx, p, punto)
+		require.NoError(t, err)
+		assert.Empty(t, eventos, "aucune ligne relue après la synchronisation globale")
+	}
+}
+
+func TestHistorialService_SynchroniserTousLesEventosBlockchainAsync_ArretDuService(t *testing.T) {
+	// Arrange: le service est déjà arrêté
+	ctxService, arreter := context.WithCancel(context.Background())
+	repo := services.NewMemoryRepository()
+	repo.AgregarEventoBlockchain(models.BlockchainEvent{
+		IDTransaction: "tx-0001",
+		IDProducto:    "prod-0",
+		FechaEvento:   "2025-11-04T02:10:07Z",
+		DatosEvento:   `{"cantidad": 1}`,
+		UpdatedAt:     "2025-11-04T02:10:07Z",
+	})
+	service := services.NewHistorialService(repo, nil, nil, false)
+	service.DefinirContexteTaches(ctxService)
+	arreter()
+
+	// Act
+	taskID, err := service.SynchroniserTousLesEventosBlockchainAsync(context.Background())
+	require.NoError(t, err)
+
+	// Assert: la tâche échoue sur l'annulation et aucun point n'est enregistré
+	require.Eventually(t, func() bool {
+		statut, err := service.ObtenerTaskStatus(context.Background(), taskID)
+		return err == nil && statut != nil && statut.Status == models.TaskStatusFailed
+	}, 2*time.Second, 10*time.Millisecond)
+
+	punto, err := repo.ObtenerPuntoSincronizacion(context.Background(), "prod-0")
+	require.NoError(t, err)
+	assert.Nil(t, punto)
+}
@@ -30,6 +30,16 @@ func (m *MockDynamoDBService) ObtenerHistorial(ctx context.Context, idProducto,
 	return args.Get(0).(*models.HistorialTransparencia), args.Error(1)
 }
 
+func (m *MockDynamoDBService) ListarHistorialesPorProducto(ctx context.Context, idProducto string) ([]models.HistorialTransparencia, error) {
+	args := m.Called(ctx, idProducto)
+	return args.Get(0).([]models.HistorialTransparencia), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ListarHistorialesPorProductoPagina(ctx context.Context, idProducto string, consulta services.ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	args := m.Called(ctx, idProducto, consulta)
+	return args.Get(0).([]models.HistorialTransparencia), args.String(1), args.Error(2)
+}
+
 func (m *MockDynamoDBService) GuardarEvento(ctx context.Context, evento *models.EventoVerificado) error {
 	args := m.Called(ctx, evento)
 	return args.Error(0)
@@ -40,6 +50,11 @@ func (m *MockDynamoDBService) ObtenerEventos(ctx context.Context, idProducto str
 	return args.Get(0).([]models.EventoVerificado), args.Error(1)
 }
 
+func (m *MockDynamoDBService) ObtenerEventosPagina(ctx context.Context, idProducto string, consulta services.ConsultaPaginada) ([]models.EventoVerificado, string, error) {
+	args := m.Called(ctx, idProducto, consulta)
+	return args.Get(0).([]models.EventoVerificado), args.String(1), args.Error(2)
+}
+
 func (m *MockDynamoDBService) ObtenerEvento(ctx context.Context, idProducto, idEvento string) (*models.EventoVerificado, error) {
 	args := m.Called(ctx, idProducto, idEvento)
 	if args.Get(0) == nil {
@@ -48,6 +63,47 @@ func (m *MockDynamoDBService) ObtenerEvento(ctx context.Context, idProducto, idE
 	return args.Get(0).(*models.EventoVerificado), args.Error(1)
 }
 
+func (m *MockDynamoDBService) ObtenerEventosLote(ctx context.Context, claves []services.ClaveEvento) ([]models.EventoVerificado, error) {
+	args := m.Called(ctx, claves)
+	return args.Get(0).([]models.EventoVerificado), args.Error(1)
+}
+
+func (m *MockDynamoDBService) GuardarEventosLote(ctx context.Context, eventos []models.EventoVerificado) ([]services.ClaveEvento, error) {
+	args := m.Called(ctx, eventos)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]services.ClaveEvento), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ActualizarResultadoVerificacion(ctx context.Context, evento *models.EventoVerificado) error {
+	args := m.Called(ctx, evento)
+	return args.Error(0)
+}
+
+func (m *MockDynamoDBService) ActualizarResultadosVerificacionLote(ctx context.Context, eventos []models.EventoVerificado) ([]services.ClaveEvento, error) {
+	args := m.Called(ctx, eventos)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]services.ClaveEvento), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ListarEventosPorResultado(ctx context.Context, resultado string) ([]models.EventoVerificado, error) {
+	args := m.Called(ctx, resultado)
+	return args.Get(0).([]models.EventoVerificado), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ListarEventosPorResultadoPagina(ctx context.Context, resultado string, consulta services.ConsultaPaginada) ([]models.EventoVerificado, string, error) {
+	args := m.Called(ctx, resultado, consulta)
+	return args.Get(0).([]models.EventoVerificado), args.String(1), args.Error(2)
+}
+
+func (m *MockDynamoDBService) GuardarPruebaMerkle(ctx context.Context, evento *models.EventoVerificado) error {
+	args := m.Called(ctx, evento)
+	return args.Error(0)
+}
+
 func (m *MockDynamoDBService) GuardarTaskStatus(ctx context.Context, taskStatus *models.TaskStatus) error {
 	args := m.Called(ctx, taskStatus)
 	return args.Error(0)
@@ -61,11 +117,74 @@ func (m *MockDynamoDBService) ObtenerTaskStatus(ctx context.Context, taskID stri
 	return args.Get(0).(*models.TaskStatus), args.Error(1)
 }
 
+func (m *MockDynamoDBService) ListarTareas(ctx context.Context, filtro services.FiltroTareas, consulta services.ConsultaPaginada) ([]models.TaskStatus, string, error) {
+	args := m.Called(ctx, filtro, consulta)
+	return args.Get(0).([]models.TaskStatus), args.String(1), args.Error(2)
+}
+
 func (m *MockDynamoDBService) ListarHistorialesInconsistentes(ctx context.Context) ([]models.HistorialTransparencia, error) {
 	args := m.Called(ctx)
 	return args.Get(0).([]models.HistorialTransparencia), args.Error(1)
 }
 
+func (m *MockDynamoDBService) ListarHistorialesInconsistentesPagina(ctx context.Context, consulta services.ConsultaPaginada) ([]models.HistorialTransparencia, string, error) {
+	args := m.Called(ctx, consulta)
+	return args.Get(0).([]models.HistorialTransparencia), args.String(1), args.Error(2)
+}
+
+func (m *MockDynamoDBService) ObtenerEventosBlockchainPorProducto(ctx context.Context, idProducto string) ([]models.BlockchainEvent, error) {
+	args := m.Called(ctx, idProducto)
+	return args.Get(0).([]models.BlockchainEvent), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ObtenerEventosBlockchainPorProductoPagina(ctx context.Context, idProducto string, consulta services.ConsultaPaginada) ([]models.BlockchainEvent, string, error) {
+	args := m.Called(ctx, idProducto, consulta)
+	return args.Get(0).([]models.BlockchainEvent), args.String(1), args.Error(2)
+}
+
+func (m *MockDynamoDBService) ObtenerEventosBlockchainDesde(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) ([]models.BlockchainEvent, error) {
+	args := m.Called(ctx, idProducto, desde)
+	return args.Get(0).([]models.BlockchainEvent), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ObtenerTousEventosBlockchain(ctx context.Context) ([]models.BlockchainEvent, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]models.BlockchainEvent), args.Error(1)
+}
+
+func (m *MockDynamoDBService) ObtenerTousEventosBlockchainPagina(ctx context.Context, consulta services.ConsultaPaginada) ([]models.BlockchainEvent, string, error) {
+	args := m.Called(ctx, consulta)
+	return args.Get(0).([]models.BlockchainEvent), args.String(1), args.Error(2)
+}
+
+func (m *MockDynamoDBService) ParcourirEventosBlockchain(ctx context.Context, segmento, totalSegmentos int, traiter func([]models.BlockchainEvent) error) error {
+	args := m.Called(ctx, segmento, totalSegmentos, traiter)
+	return args.Error(0)
+}
+
+func (m *MockDynamoDBService) ObtenerPuntoSincronizacion(ctx context.Context, idProducto string) (*models.PuntoSincronizacion, error) {
+	args := m.Called(ctx, idProducto)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PuntoSincronizacion), args.Error(1)
+}
+
+func (m *MockDynamoDBService) GuardarPuntoSincronizacion(ctx context.Context, punto *models.PuntoSincronizacion) error {
+	args := m.Called(ctx, punto)
+	return args.Error(0)
+}
+
+// Le journal des vérifications n'est pas l'objet de ces tests: il est accepté sans attente
+func (m *MockDynamoDBService) GuardarIntentosVerificacion(ctx context.Context, intentos []models.IntentoVerificacion) error {
+	return nil
+}
+
+func (m *MockDynamoDBService) ListarIntentosVerificacion(ctx context.Context, idProducto, idEvento string) ([]models.IntentoVerificacion, error) {
+	args := m.Called(ctx, idProducto, idEvento)
+	return args.Get(0).([]models.IntentoVerificacion), args.Error(1)
+}
+
 // MockBlockchainService est un mock pour BlockchainService
 type MockBlockchainService struct {
 	mock.Mock
@@ -76,6 +195,25 @@ func (m *MockBlockchainService) VerificarIntegridad(ctx context.Context, evento
 	return args.Error(0)
 }
 
+func (m *MockBlockchainService) ObtenerBloqueActual(ctx context.Context) (uint64, error) {
+	args := m.Called(ctx)
+	return args.Get(0).(uint64), args.Error(1)
+}
+
+func (m *MockBlockchainService) ObtenerHashBloque(ctx context.Context, numero uint64) (string, error) {
+	args := m.Called(ctx, numero)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockBlockchainService) VerificarConexion(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockBlockchainService) Close() {}
+
+func (m *MockBlockchainService) Nombre() string { return "mock" }
+
 // MockKafkaService est un mock pour KafkaService
 type MockKafkaService struct {
 	mock.Mock
@@ -91,6 +229,25 @@ func (m *MockKafkaService) PublishInconsistencia(ctx context.Context, event *mod
 	return args.Error(0)
 }
 
+func (m *MockKafkaService) ConsumeEvents(ctx context.Context, handler func(event *models.TransaccionBlockchainEvent) error) error {
+	args := m.Called(ctx, handler)
+	return args.Error(0)
+}
+
+func (m *MockKafkaService) RejouerDLQ(ctx context.Context, max int, handler func(event *models.TransaccionBlockchainEvent) error) (services.ResumenRejeuDLQ, error) {
+	args := m.Called(ctx, max, handler)
+	return args.Get(0).(services.ResumenRejeuDLQ), args.Error(1)
+}
+
+func (m *MockKafkaService) VerificarConexion(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *MockKafkaService) Close() error {
+	return nil
+}
+
 // Test de base pour HistorialService
 func TestHistorialService_TraiterEvenementTransaccion(t *testing.T) {
 	// Arrange
@@ -154,6 +311,9 @@ func TestHistorialService_ObtenerHistorial(t *testing.T) {
 	}
 
 	// Mock expectations
+	mockDynamoDB.On("ObtenerPuntoSincronizacion", mock.Anything, "prod-test-001").Return(nil, nil)
+	mockDynamoDB.On("ObtenerEventosBlockchainDesde", mock.Anything, "prod-test-001", mock.Anything).Return([]models.BlockchainEvent{}, nil)
+	mockDynamoDB.On("GuardarPuntoSincronizacion", mock.Anything, mock.Anything).Return(nil)
 	mockDynamoDB.On("ObtenerHistorial", mock.Anything, "prod-test-001", "lot-2025-01").Return(expectedHistorial, nil)
 
 	// Act
@@ -180,6 +340,9 @@ func TestHistorialService_ReconstruirHistorial_NoEvents(t *testing.T) {
 
 	// Mock expectations
 	mockDynamoDB.On("ObtenerHistorial", mock.Anything, "prod-test-001", "lot-2025-01").Return(nil, nil)
+	mockDynamoDB.On("ObtenerPuntoSincronizacion", mock.Anything, "prod-test-001").Return(nil, nil)
+	mockDynamoDB.On("ObtenerEventosBlockchainDesde", mock.Anything, "prod-test-001", mock.Anything).Return([]models.BlockchainEvent{}, nil)
+	mockDynamoDB.On("GuardarPuntoSincronizacion", mock.Anything, mock.Anything).Return(nil)
 	mockDynamoDB.On("ObtenerEventos", mock.Anything, "prod-test-001").Return([]models.EventoVerificado{}, nil)
 
 	// Act
@@ -191,3 +354,38 @@ func TestHistorialService_ReconstruirHistorial_NoEvents(t *testing.T) {
 	assert.Contains(t, err.Error(), "aucun événement trouvé")
 	mockDynamoDB.AssertExpectations(t)
 }
+
+func TestHistorialService_SynchroniserDepuisBlockchain_ParLot(t *testing.T) {
+	// Arrange
+	mockDynamoDB := new(MockDynamoDBService)
+	service := services.NewHistorialService(mockDynamoDB, nil, nil, false)
+
+	eventosBC := make([]models.BlockchainEvent, 3)
+	for i, id := range []string{"tx-001", "tx-002", "tx-003"} {
+		eventosBC[i] = models.BlockchainEvent{
+			IDTransaction: id,
+			IDProducto:    "prod-test-001",
+			FechaEvento:   "2025-11-04T02:10:07Z",
+			DatosEvento:   `{"cantidad": 100}`,
+		}
+	}
+
+	mockDynamoDB.On("ObtenerPuntoSincronizacion", mock.Anything, "prod-test-001").Return(nil, nil)
+	mockDynamoDB.On("ObtenerEventosBlockchainDesde", mock.Anything, "prod-test-001", mock.Anything).Return(eventosBC, nil)
+	mockDynamoDB.On("GuardarPuntoSincronizacion", mock.Anything, mock.Anything).Return(nil)
+	mockDynamoDB.On("ObtenerEventosLote", mock.Anything, mock.MatchedBy(func(claves []services.ClaveEvento) bool {
+		return len(claves) == 3
+	})).Return([]models.EventoVerificado{{IDProducto: "prod-test-001", IDEvento: "tx-002"}}, nil).Once()
+	mockDynamoDB.On("GuardarEventosLote", mock.Anything, mock.MatchedBy(func(eventos []models.EventoVerificado) bool {
+		return len(eventos) == 2 && eventos[0].IDEvento == "tx-001" && eventos[1].IDEvento == "tx-003"
+	})).Return(nil, nil).Once()
+
+	// Act
+	err := service.SynchroniserDepuisBlockchain(context.Background(), "prod-test-001")
+
+	// Assert: une lecture et une écriture groupées, aucun accès unitaire
+	assert.NoError(t, err)
+	mockDynamoDB.AssertExpectations(t)
+	mockDynamoDB.AssertNotCalled(t, "ObtenerEvento", mock.Anything, mock.Anything, mock.Anything)
+	mockDynamoDB.AssertNotCalled(t, "GuardarEvento", mock.Anything, mock.Anything)
+}
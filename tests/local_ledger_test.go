@@ -0,0 +1,237 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// hashDatos calcule le hash attendu des données d'un événement
+func hashDatos(t *testing.T, datos map[string]interface{}) string {
+	t.Helper()
+	hash, err := services.CalcularHashDatos(datos, models.AlgoritmoSHA256JCS)
+	require.NoError(t, err)
+	return hash
+}
+
+func TestLocalLedger_VerificarEvento(t *testing.T) {
+	datos := map[string]interface{}{"cantidad": 100, "planta": "Planta A"}
+	datosAlteres := map[string]interface{}{"cantidad": 900, "planta": "Planta A"}
+	hashAncre := hashDatos(t, datos)
+	registre := "0x1111111111111111111111111111111111111111"
+	autreContrat := "0x2222222222222222222222222222222222222222"
+	topicAncrage := "0x" + strings.Repeat("ab", 32)
+
+	tests := []struct {
+		name      string
+		txHash    string
+		datos     map[string]interface{}
+		seed      *services.TransaccionLocal
+		contratos []string
+		topico    string
+		resultado string
+	}{
+		{
+			name:      "transaction confirmée et hash ancré identique",
+			txHash:    "0xAAA",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0xaaa", HashAnclado: "0x" + hashAncre, BloqueNumero: 10},
+			resultado: models.VerificacionOK,
+		},
+		{
+			name:      "données modifiées après ancrage",
+			txHash:    "0xbbb",
+			datos:     datosAlteres,
+			seed:      &services.TransaccionLocal{TxHash: "0xbbb", HashAnclado: hashAncre, BloqueNumero: 11},
+			resultado: models.VerificacionHashMismatch,
+		},
+		{
+			name:      "transaction sans hash ancré",
+			txHash:    "0xfff",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0xfff", BloqueNumero: 13},
+			resultado: models.VerificacionHashMismatch,
+		},
+		{
+			name:      "transaction absente",
+			txHash:    "0xccc",
+			datos:     datos,
+			resultado: models.VerificacionNotFound,
+		},
+		{
+			name:      "transaction revertée",
+			txHash:    "0xddd",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0xddd", HashAnclado: hashAncre, BloqueNumero: 12, Revertida: true},
+			resultado: models.VerificacionTxRevertida,
+		},
+		{
+			name:      "appel du contrat registre autorisé",
+			txHash:    "0x111",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0x111", HashAnclado: hashAncre, BloqueNumero: 14, Contrato: registre},
+			contratos: []string{registre},
+			resultado: models.VerificacionOK,
+		},
+		{
+			name:      "hash ancré par un autre contrat",
+			txHash:    "0x222",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0x222", HashAnclado: hashAncre, BloqueNumero: 15, Contrato: autreContrat},
+			contratos: []string{registre},
+			resultado: models.VerificacionContratoIncorrecto,
+		},
+		{
+			name:      "log émis par le registre avec le topic attendu",
+			txHash:    "0x333",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0x333", HashAnclado: hashAncre, BloqueNumero: 16, Contrato: registre, Topico: topicAncrage},
+			contratos: []string{registre},
+			topico:    topicAncrage,
+			resultado: models.VerificacionOK,
+		},
+		{
+			name:      "log du registre avec un autre topic",
+			txHash:    "0x444",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0x444", HashAnclado: hashAncre, BloqueNumero: 17, Contrato: registre, Topico: "0x" + strings.Repeat("cd", 32)},
+			contratos: []string{registre},
+			topico:    topicAncrage,
+			resultado: models.VerificacionHashMismatch,
+		},
+		{
+			name:      "appel du registre sans log d'ancrage",
+			txHash:    "0x555",
+			datos:     datos,
+			seed:      &services.TransaccionLocal{TxHash: "0x555", HashAnclado: hashAncre, BloqueNumero: 18, Contrato: registre},
+			contratos: []string{registre},
+			topico:    topicAncrage,
+			resultado: models.VerificacionHashMismatch,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			ctx := context.Background()
+			repo := services.NewMemoryRepository()
+			ledger := services.NewLocalLedger()
+			if tt.seed != nil {
+				ledger.RegistrarTransaccion(*tt.seed)
+			}
+			ledger.DefinirContratosPermitidos(tt.contratos, tt.topico)
+
+			require.NoError(t, repo.GuardarEvento(ctx, &models.EventoVerificado{
+				IDProducto:           "prod-test-001",
+				IDEvento:             "evt-001",
+				Fecha:                time.Now(),
+				DatosEvento:          tt.datos,
+				HashEvento:           hashAncre,
+				ReferenciaBlockchain: tt.txHash,
+			}))
+
+			service := services.NewHistorialService(repo, ledger, nil, true)
+
+			// Act
+			evento, err := service.VerificarEvento(ctx, "prod-test-001", "evt-001")
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, tt.resultado, evento.ResultadoVerificacion)
+		})
+	}
+}
+
+func TestLocalLedger_TraiterEvenementTransaccion_EnrichissementNonHache(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := services.NewMemoryRepository()
+	ledger := services.NewLocalLedger()
+	datos := map[string]interface{}{"cantidad": 100}
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xeee", HashAnclado: hashDatos(t, datos), BloqueNumero: 20})
+	service := services.NewHistorialService(repo, ledger, services.NewMemoryEventBus(1), true)
+
+	event := &models.TransaccionBlockchainEvent{
+		SchemaVersion:       "1.0",
+		IDEvento:            "evt-lote-001",
+		IDProducto:          "prod-test-001",
+		Lote:                "lot-2025-01",
+		FechaEvento:         time.Now(),
+		DatosEvento:         datos,
+		HashEvento:          hashDatos(t, datos),
+		DireccionBlockchain: "0xeee",
+	}
+
+	// Act
+	err := service.TraiterEvenementTransaccion(ctx, event)
+
+	// Assert
+	require.NoError(t, err)
+	assert.NotContains(t, event.DatosEvento, "lote")
+
+	evento, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-lote-001")
+	require.NoError(t, err)
+	assert.Equal(t, "lot-2025-01", evento.Enriquecimiento[models.EnriquecimientoLote])
+	assert.Equal(t, models.AlgoritmoSHA256JCS, evento.HashCriptografico.Algoritmo)
+
+	verificado, err := service.VerificarEvento(ctx, "prod-test-001", "evt-lote-001")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, verificado.ResultadoVerificacion)
+}
+
+func TestHistorialService_ListarVerificaciones(t *testing.T) {
+	// Arrange: l'événement est vérifié avant puis après l'ancrage de sa transaction
+	ctx := context.Background()
+	datos := map[string]interface{}{"cantidad": 100, "planta": "Planta A"}
+	hashAncre := hashDatos(t, datos)
+	repo := services.NewMemoryRepository()
+	ledger := services.NewLocalLedger()
+	require.NoError(t, repo.GuardarEvento(ctx, &models.EventoVerificado{
+		IDProducto:           "prod-test-001",
+		IDEvento:             "evt-001",
+		Fecha:                time.Now(),
+		DatosEvento:          datos,
+		HashEvento:           hashAncre,
+		ReferenciaBlockchain: "0xaaa",
+	}))
+	service := services.NewHistorialService(repo, ledger, nil, true)
+
+	// Act
+	_, err := service.VerificarEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	ledger.RegistrarTransaccion(services.TransaccionLocal{TxHash: "0xaaa", HashAnclado: hashAncre, BloqueNumero: 10})
+	_, err = service.VerificarEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+
+	intentos, err := service.ListarVerificaciones(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+
+	// Assert: les deux tentatives sont conservées, le dernier résultat est enregistré sur l'événement
+	require.Len(t, intentos, 2)
+	assert.Equal(t, models.VerificacionNotFound, intentos[0].Resultado)
+	assert.NotEmpty(t, intentos[0].Error)
+	assert.Equal(t, models.VerificacionOK, intentos[1].Resultado)
+	assert.Empty(t, intentos[1].Error)
+	assert.Equal(t, uint64(10), intentos[1].BloqueNumero)
+	for _, intento := range intentos {
+		assert.Equal(t, "local", intento.Verificador)
+		assert.Equal(t, models.OrigenVerificacion, intento.Origen)
+		assert.Equal(t, hashAncre, intento.HashCalculado)
+	}
+	assert.Less(t, intentos[0].IDIntento, intentos[1].IDIntento)
+
+	stocke, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, stocke.ResultadoVerificacion)
+
+	inconnu, err := service.ListarVerificaciones(ctx, "prod-test-001", "evt-inconnu")
+	require.NoError(t, err)
+	assert.Nil(t, inconnu)
+}
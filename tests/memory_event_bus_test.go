@@ -0,0 +1,292 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/smithy-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestMemoryEventBus_ConsumeEvents(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	bus := services.NewMemoryEventBus(10)
+	service := services.NewHistorialService(repo, nil, bus, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.ConsumeEvents(ctx, func(event *models.TransaccionBlockchainEvent) error {
+			return service.TraiterEvenementTransaccion(ctx, event)
+		})
+	}()
+
+	// Act
+	require.NoError(t, bus.PublicarMensaje(ctx, []byte("{invalide")))
+	require.NoError(t, bus.PublicarTransaccion(ctx, &models.TransaccionBlockchainEvent{
+		SchemaVersion: "1.0",
+		IDEvento:      "evt-bus-001",
+		TipoEvento:    "Ingreso",
+		IDProducto:    "prod-test-001",
+		Lote:          "lot-2025-01",
+		FechaEvento:   time.Now(),
+		DatosEvento:   map[string]interface{}{"cantidad": 100},
+	}))
+
+	// Assert
+	assert.Eventually(t, func() bool {
+		evento, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-bus-001")
+		return err == nil && evento != nil
+	}, 2*time.Second, 10*time.Millisecond)
+
+	cancel()
+	<-done
+}
+
+func TestMemoryEventBus_ReconstruirHistorial_PublieReconstruido(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	repo.AgregarEventoBlockchain(models.BlockchainEvent{
+		IDTransaction: "tx-001",
+		IDProducto:    "prod-test-001",
+		TipoEvento:    "fabricacion",
+		FechaEvento:   "2025-11-04T02:10:07Z",
+		Estado:        "confirmado",
+		DatosEvento:   `{"nombreProducto": "Paracetamol 500mg"}`,
+	})
+	bus := services.NewMemoryEventBus(10)
+	service := services.NewHistorialService(repo, nil, bus, false)
+
+	// Act
+	historial, err := service.ReconstruirHistorial(context.Background(), "prod-test-001", "", true)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, models.EstadoConforme, historial.EstadoActual)
+	assert.Equal(t, "Paracetamol 500mg", historial.NombreProducto)
+
+	publicados := bus.Publicados()
+	require.Len(t, publicados, 1)
+	assert.Equal(t, services.EventTypeHistorialReconstruido, publicados[0].EventType)
+}
+
+func TestMemoryEventBus_DLQ_RejeuApresCorrection(t *testing.T) {
+	// Arrange: un handler en panne puis corrigé
+	bus := services.NewMemoryEventBus(10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	enPanne := true
+	var traites []string
+	handler := func(event *models.TransaccionBlockchainEvent) error {
+		if enPanne {
+			return fmt.Errorf("stockage indisponible")
+		}
+		traites = append(traites, event.IDEvento)
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.ConsumeEvents(ctx, handler)
+	}()
+
+	require.NoError(t, bus.PublicarMensaje(ctx, []byte("{invalide")))
+	require.NoError(t, bus.PublicarTransaccion(ctx, eventoTransaccion("prod-test-001", "evt-dlq-001")))
+
+	assert.Eventually(t, func() bool {
+		return len(bus.LettresMortes()) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	dlq := bus.LettresMortes()
+	assert.Equal(t, []byte("{invalide"), dlq[0].Valor)
+	assert.Contains(t, dlq[0].Error, "parsing")
+	assert.Equal(t, int64(1), dlq[1].Offset)
+	assert.Equal(t, 1, dlq[1].Intentos)
+	assert.Contains(t, dlq[1].Error, "stockage indisponible")
+
+	// Act
+	enPanne = false
+	resumen, err := bus.RejouerDLQ(context.Background(), 0, handler)
+
+	// Assert: le message illisible reste en lettres mortes avec une tentative de plus
+	require.NoError(t, err)
+	assert.Equal(t, services.ResumenRejeuDLQ{Leidos: 2, Traites: 1, Renvoyes: 1}, resumen)
+	assert.Equal(t, []string{"evt-dlq-001"}, traites)
+
+	dlq = bus.LettresMortes()
+	require.Len(t, dlq, 1)
+	assert.Equal(t, 2, dlq[0].Intentos)
+	assert.Equal(t, int64(0), dlq[0].Offset)
+}
+
+func TestMemoryEventBus_ReprisesAvantDLQ(t *testing.T) {
+	// Arrange: evt-001 throttlé deux fois, evt-002 throttlé en permanence
+	bus := services.NewMemoryEventBus(10)
+	bus.DefinirPoliticaReintentos(services.PoliticaReintentos{
+		MaxIntentos:   3,
+		EsperaInicial: time.Millisecond,
+		EsperaMaxima:  5 * time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	appels := map[string]int{}
+	handler := func(event *models.TransaccionBlockchainEvent) error {
+		mu.Lock()
+		defer mu.Unlock()
+		appels[event.IDEvento]++
+		if event.IDEvento == "evt-002" || appels[event.IDEvento] <= 2 {
+			return fmt.Errorf("erreur sauvegarde événement: %w", &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException"})
+		}
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.ConsumeEvents(ctx, handler)
+	}()
+
+	// Act
+	for _, idEvento := range []string{"evt-001", "evt-002", ""} {
+		require.NoError(t, bus.PublicarTransaccion(ctx, eventoTransaccion("prod-test-001", idEvento)))
+	}
+
+	// Assert: le message sans idEvento part sans être traité, evt-002 après épuisement des reprises
+	assert.Eventually(t, func() bool {
+		return len(bus.LettresMortes()) == 2
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	dlq := bus.LettresMortes()
+	assert.Equal(t, services.MotivoReintentosAgotados, dlq[0].Motivo)
+	assert.Equal(t, 3, dlq[0].Intentos)
+	assert.Equal(t, services.MotivoMensajeInvalido, dlq[1].Motivo)
+	assert.Equal(t, 1, dlq[1].Intentos)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]int{"evt-001": 3, "evt-002": 3}, appels)
+}
+
+func TestMemoryEventBus_ArretPendantReprise_PasDeDLQ(t *testing.T) {
+	// Arrange: evt-001 throttlé, attente de reprise longue
+	bus := services.NewMemoryEventBus(10)
+	bus.DefinirPoliticaReintentos(services.PoliticaReintentos{
+		MaxIntentos:   3,
+		EsperaInicial: time.Minute,
+		EsperaMaxima:  time.Minute,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	appele := make(chan struct{}, 1)
+	handler := func(event *models.TransaccionBlockchainEvent) error {
+		appele <- struct{}{}
+		return fmt.Errorf("erreur sauvegarde événement: %w", &smithy.GenericAPIError{Code: "ProvisionedThroughputExceededException"})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.ConsumeEvents(ctx, handler)
+	}()
+	require.NoError(t, bus.PublicarTransaccion(ctx, eventoTransaccion("prod-test-001", "evt-001")))
+	<-appele
+
+	// Act: arrêt pendant l'attente de reprise
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatal("ConsumeEvents n'a pas rendu la main après l'arrêt")
+	}
+
+	// Assert: le message interrompu n'est pas une lettre morte
+	assert.Empty(t, bus.LettresMortes())
+}
+
+func TestMemoryEventBus_Concurrencia_OrdreParProducto(t *testing.T) {
+	// Arrange: deux produits, des traitements lents
+	bus := services.NewMemoryEventBus(20)
+	bus.DefinirConcurrencia(4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var mu sync.Mutex
+	ordre := map[string][]string{}
+	enCours, maxEnCours := 0, 0
+	handler := func(event *models.TransaccionBlockchainEvent) error {
+		mu.Lock()
+		enCours++
+		maxEnCours = max(maxEnCours, enCours)
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		enCours--
+		ordre[event.IDProducto] = append(ordre[event.IDProducto], event.IDEvento)
+		mu.Unlock()
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_ = bus.ConsumeEvents(ctx, handler)
+	}()
+
+	// Act: messages des deux produits entrelacés
+	attendus := map[string][]string{}
+	for i := 0; i < 5; i++ {
+		for _, idProducto := range []string{"prod-a", "prod-b"} {
+			idEvento := fmt.Sprintf("%s-evt-%d", idProducto, i)
+			attendus[idProducto] = append(attendus[idProducto], idEvento)
+			require.NoError(t, bus.PublicarTransaccion(ctx, eventoTransaccion(idProducto, idEvento)))
+		}
+	}
+
+	// Assert: ordre conservé par produit, produits traités en parallèle
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(ordre["prod-a"])+len(ordre["prod-b"]) == 10
+	}, 2*time.Second, 10*time.Millisecond)
+	cancel()
+	<-done
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, attendus, ordre)
+	assert.GreaterOrEqual(t, maxEnCours, 2)
+}
+
+// eventoTransaccion construit un TransaccionBlockchainEvent conforme au schéma courant
+func eventoTransaccion(idProducto, idEvento string) *models.TransaccionBlockchainEvent {
+	return &models.TransaccionBlockchainEvent{
+		SchemaVersion: services.VersionEsquemaTransaccion,
+		IDEvento:      idEvento,
+		TipoEvento:    "Ingreso",
+		IDProducto:    idProducto,
+		FechaEvento:   time.Now(),
+		DatosEvento:   map[string]interface{}{"cantidad": 100},
+	}
+}
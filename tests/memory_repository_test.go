@@ -0,0 +1,243 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestMemoryRepository_GuardarEvento_Idempotent(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	ctx := context.Background()
+
+	evento := &models.EventoVerificado{
+		IDProducto:            "prod-test-001",
+		IDEvento:              "evt-001",
+		DatosEvento:           map[string]interface{}{"cantidad": 100},
+		ResultadoVerificacion: models.VerificacionOK,
+	}
+
+	// Act
+	require.NoError(t, repo.GuardarEvento(ctx, evento))
+
+	doublon := *evento
+	doublon.ResultadoVerificacion = models.VerificacionHashMismatch
+	require.NoError(t, repo.GuardarEvento(ctx, &doublon))
+
+	// La copie stockée ne doit pas être affectée par une mutation de l'appelant
+	evento.DatosEvento["cantidad"] = 0
+
+	// Assert
+	stocke, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	require.NotNil(t, stocke)
+	assert.Equal(t, models.VerificacionOK, stocke.ResultadoVerificacion)
+	assert.Equal(t, 100, stocke.DatosEvento["cantidad"])
+}
+
+func TestMemoryRepository_ActualizarResultadoVerificacion_Version(t *testing.T) {
+	// Arrange: deux lecteurs du même événement
+	repo := services.NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, repo.GuardarEvento(ctx, &models.EventoVerificado{
+		IDProducto:            "prod-test-001",
+		IDEvento:              "evt-001",
+		ResultadoVerificacion: models.VerificacionPendienteConfirmacion,
+	}))
+	premier, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	second, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), premier.Version)
+
+	// Act
+	premier.ResultadoVerificacion = models.VerificacionOK
+	errPremier := repo.ActualizarResultadoVerificacion(ctx, premier)
+	second.ResultadoVerificacion = models.VerificacionNotFound
+	errSecond := repo.ActualizarResultadoVerificacion(ctx, second)
+
+	// Assert: la seconde mise à jour, fondée sur une lecture périmée, est refusée
+	require.NoError(t, errPremier)
+	assert.Equal(t, int64(2), premier.Version)
+	assert.ErrorIs(t, errSecond, services.ErrConflictoVersion)
+
+	stocke, err := repo.ObtenerEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, stocke.ResultadoVerificacion)
+	assert.Equal(t, int64(2), stocke.Version)
+
+	// Act: la même mise à jour par lot signale le conflit
+	conflictos, err := repo.ActualizarResultadosVerificacionLote(ctx, []models.EventoVerificado{*second})
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, []services.ClaveEvento{{IDProducto: "prod-test-001", IDEvento: "evt-001"}}, conflictos)
+}
+
+func TestMemoryRepository_ObtenerHistorial_FiltreLote(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	ctx := context.Background()
+
+	require.NoError(t, repo.GuardarHistorial(ctx, &models.HistorialTransparencia{
+		IDProducto:   "prod-test-001",
+		Lote:         "lot-2025-01",
+		EstadoActual: models.EstadoInconsistente,
+		UltimoCheck:  time.Now(),
+	}))
+
+	// Act
+	trouve, err := repo.ObtenerHistorial(ctx, "prod-test-001", "lot-2025-01")
+	require.NoError(t, err)
+	autreLote, err := repo.ObtenerHistorial(ctx, "prod-test-001", "lot-2025-02")
+	require.NoError(t, err)
+	inconsistentes, err := repo.ListarHistorialesInconsistentes(ctx)
+	require.NoError(t, err)
+
+	// Assert
+	assert.NotNil(t, trouve)
+	assert.Nil(t, autreLote)
+	assert.Len(t, inconsistentes, 1)
+}
+
+func TestMemoryRepository_HistorialPorLote(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	ctx := context.Background()
+
+	for _, lote := range []string{"lot-2025-02", "", "lot-2025-01"} {
+		require.NoError(t, repo.GuardarHistorial(ctx, &models.HistorialTransparencia{
+			IDProducto:   "prod-test-001",
+			Lote:         lote,
+			EstadoActual: models.EstadoConforme,
+		}))
+	}
+
+	// Act: un second lot n'écrase pas le premier
+	loteA, err := repo.ObtenerHistorial(ctx, "prod-test-001", "lot-2025-01")
+	require.NoError(t, err)
+	global, err := repo.ObtenerHistorial(ctx, "prod-test-001", "")
+	require.NoError(t, err)
+	historiales, err := repo.ListarHistorialesPorProducto(ctx, "prod-test-001")
+	require.NoError(t, err)
+
+	// Assert
+	require.NotNil(t, loteA)
+	assert.Equal(t, "lot-2025-01", loteA.Lote)
+	require.NotNil(t, global)
+	assert.Equal(t, "", global.Lote)
+	require.Len(t, historiales, 3)
+	assert.Equal(t, []string{"", "lot-2025-01", "lot-2025-02"}, []string{historiales[0].Lote, historiales[1].Lote, historiales[2].Lote})
+}
+
+func TestMemoryRepository_ObtenerEventosPagina_Cursor(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	ctx := context.Background()
+	for i := 0; i < 7; i++ {
+		require.NoError(t, repo.GuardarEvento(ctx, &models.EventoVerificado{
+			IDProducto: "prod-test-001",
+			IDEvento:   fmt.Sprintf("evt-%03d", i),
+		}))
+	}
+
+	// Act
+	var vus []string
+	consulta := services.ConsultaPaginada{Limite: 3}
+	pages := 0
+	for {
+		eventos, suivant, err := repo.ObtenerEventosPagina(ctx, "prod-test-001", consulta)
+		require.NoError(t, err)
+		for _, evento := range eventos {
+			vus = append(vus, evento.IDEvento)
+		}
+		pages++
+		if suivant == "" {
+			break
+		}
+		consulta.Cursor = suivant
+	}
+
+	// Assert
+	assert.Equal(t, 3, pages)
+	assert.Equal(t, []string{"evt-000", "evt-001", "evt-002", "evt-003", "evt-004", "evt-005", "evt-006"}, vus)
+
+	_, _, err := repo.ObtenerEventosPagina(ctx, "prod-test-001", services.ConsultaPaginada{Cursor: "pas-un-cursor"})
+	assert.ErrorIs(t, err, services.ErrCursorInvalido)
+
+	// Un cursor émis pour un produit n'est pas valable pour un autre
+	_, suivant, err := repo.ObtenerEventosPagina(ctx, "prod-test-001", services.ConsultaPaginada{Limite: 1})
+	require.NoError(t, err)
+	_, _, err = repo.ObtenerEventosPagina(ctx, "prod-test-002", services.ConsultaPaginada{Cursor: suivant})
+	assert.ErrorIs(t, err, services.ErrCursorInvalido)
+}
+
+func TestHistorialService_SynchroniserDepuisBlockchain_MemoryRepository(t *testing.T) {
+	// Arrange
+	repo := services.NewMemoryRepository()
+	repo.AgregarEventoBlockchain(models.BlockchainEvent{
+		IDTransaction: "tx-001",
+		IDProducto:    "prod-test-001",
+		TipoEvento:    "fabricacion",
+		FechaEvento:   "2025-11-04T02:10:07Z",
+		ActorEmisor:   "Laboratorio Medisupply SA",
+		Estado:        "confirmado",
+		DatosEvento:   `{"lote": "LOT-12345", "cantidad": 1000}`,
+		HashEvento:    "f28ac63a5723c7f026a37d5cfe951bc4909147b384fab6e44e2d942b0f7db65e",
+	})
+
+	service := services.NewHistorialService(repo, nil, nil, false)
+
+	// Act
+	err := service.SynchroniserDepuisBlockchain(context.Background(), "prod-test-001")
+
+	// Assert
+	require.NoError(t, err)
+	eventos, err := repo.ObtenerEventos(context.Background(), "prod-test-001")
+	require.NoError(t, err)
+	require.Len(t, eventos, 1)
+	assert.Equal(t, "tx-001", eventos[0].IDEvento)
+	assert.Equal(t, models.VerificacionOK, eventos[0].ResultadoVerificacion)
+}
+
+func TestMemoryRepository_Tareas_ExpirationEtFiltres(t *testing.T) {
+	// Arrange: une tâche expirée, deux reconstructions du même produit et une synchronisation globale
+	repo := services.NewMemoryRepository()
+	ctx := context.Background()
+	maintenant := time.Now()
+
+	tareas := []*models.TaskStatus{
+		{TaskID: "t-expiree", Status: "completed", IDProducto: "prod-001", CreatedAt: maintenant.Add(-4 * time.Hour), ExpiraEn: maintenant.Add(-time.Hour).Unix()},
+		{TaskID: "t-ancienne", Status: "completed", IDProducto: "prod-001", CreatedAt: maintenant.Add(-2 * time.Hour), ExpiraEn: maintenant.Add(time.Hour).Unix()},
+		{TaskID: "t-recente", Status: "processing", IDProducto: "prod-001", CreatedAt: maintenant.Add(-time.Hour)},
+		{TaskID: "t-sync", Status: "completed", Tipo: models.TipoTareaSincronizacion, CreatedAt: maintenant},
+	}
+	for _, tarea := range tareas {
+		require.NoError(t, repo.GuardarTaskStatus(ctx, tarea))
+	}
+
+	// Act & Assert: une tâche expirée n'est plus lisible
+	expiree, err := repo.ObtenerTaskStatus(ctx, "t-expiree")
+	require.NoError(t, err)
+	assert.Nil(t, expiree)
+
+	parProducto, _, err := repo.ListarTareas(ctx, services.FiltroTareas{IDProducto: "prod-001"}, services.ConsultaPaginada{})
+	require.NoError(t, err)
+	require.Len(t, parProducto, 2)
+	assert.Equal(t, "t-recente", parProducto[0].TaskID) // Plus récente d'abord
+	assert.Equal(t, "t-ancienne", parProducto[1].TaskID)
+
+	terminees, _, err := repo.ListarTareas(ctx, services.FiltroTareas{Status: "completed"}, services.ConsultaPaginada{Limite: 1})
+	require.NoError(t, err)
+	require.Len(t, terminees, 1)
+	assert.Equal(t, "t-sync", terminees[0].TaskID)
+}
@@ -0,0 +1,120 @@
+package services_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+func TestMerkle_PruebasInclusion(t *testing.T) {
+	for _, taille := range []int{1, 2, 3, 5, 8} {
+		t.Run(fmt.Sprintf("%d feuilles", taille), func(t *testing.T) {
+			// Arrange
+			hojas := make([]string, taille)
+			for i := range hojas {
+				hojas[i] = hashDatos(t, map[string]interface{}{"indice": i})
+			}
+
+			// Act
+			raiz, pruebas, err := services.ConstruirArbolMerkle(hojas)
+
+			// Assert
+			require.NoError(t, err)
+			require.Len(t, pruebas, taille)
+			for i, prueba := range pruebas {
+				assert.Equal(t, raiz, prueba.Raiz)
+				incluido, err := services.VerificarPruebaMerkle(hojas[i], &prueba)
+				require.NoError(t, err)
+				assert.True(t, incluido, "feuille %d", i)
+			}
+
+			autre := hashDatos(t, map[string]interface{}{"indice": "autre"})
+			incluido, err := services.VerificarPruebaMerkle(autre, &pruebas[0])
+			require.NoError(t, err)
+			assert.False(t, incluido)
+		})
+	}
+}
+
+func TestMerkleBatcher_AnclajePorLote(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := services.NewMemoryRepository()
+	ledger := services.NewLocalLedger()
+	service := services.NewHistorialService(repo, ledger, services.NewMemoryEventBus(1), true)
+	batcher := services.NewMerkleBatcher(repo, ledger, ledger, 10, time.Minute)
+	service.DefinirMerkleBatcher(batcher)
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, service.TraiterEvenementTransaccion(ctx, &models.TransaccionBlockchainEvent{
+			IDEvento:    fmt.Sprintf("evt-%03d", i),
+			IDProducto:  "prod-test-001",
+			FechaEvento: time.Now(),
+			DatosEvento: map[string]interface{}{"cantidad": 100 + i},
+		}))
+	}
+
+	enAttente, err := repo.ListarEventosPorResultado(ctx, models.VerificacionPendienteAnclaje)
+	require.NoError(t, err)
+	require.Len(t, enAttente, 3)
+
+	// Act
+	ancres, err := batcher.Anclar(ctx)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, 3, ancres)
+
+	eventos, err := repo.ObtenerEventos(ctx, "prod-test-001")
+	require.NoError(t, err)
+	require.Len(t, eventos, 3)
+	for _, evento := range eventos {
+		assert.Equal(t, models.VerificacionOK, evento.ResultadoVerificacion, evento.IDEvento)
+		require.NotNil(t, evento.PruebaMerkle)
+		assert.Equal(t, eventos[0].ReferenciaBlockchain, evento.ReferenciaBlockchain, "une seule transaction pour le lot")
+	}
+
+	verificado, err := service.VerificarEvento(ctx, "prod-test-001", "evt-001")
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionOK, verificado.ResultadoVerificacion)
+}
+
+func TestMerkleBatcher_PruebaAlterada(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := services.NewMemoryRepository()
+	ledger := services.NewLocalLedger()
+	datos := map[string]interface{}{"cantidad": 100}
+	otros := map[string]interface{}{"cantidad": 200}
+
+	raiz, pruebas, err := services.ConstruirArbolMerkle([]string{hashDatos(t, datos), hashDatos(t, otros)})
+	require.NoError(t, err)
+	txHash, err := ledger.AnclarHash(ctx, raiz)
+	require.NoError(t, err)
+
+	// La preuve de l'autre feuille ne prouve pas l'inclusion de cet événement
+	require.NoError(t, repo.GuardarEvento(ctx, &models.EventoVerificado{
+		IDProducto:           "prod-test-001",
+		IDEvento:             "evt-001",
+		Fecha:                time.Now(),
+		DatosEvento:          datos,
+		ReferenciaBlockchain: txHash,
+		PruebaMerkle:         &pruebas[1],
+	}))
+	service := services.NewHistorialService(repo, ledger, nil, true)
+
+	// Act
+	evento, err := service.VerificarEvento(ctx, "prod-test-001", "evt-001")
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, models.VerificacionHashMismatch, evento.ResultadoVerificacion)
+	assert.Contains(t, evento.Observaciones, "Preuve Merkle invalide")
+}
@@ -0,0 +1,170 @@
+package services_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// digestDatos retourne les 32 octets du hash JCS des données, tels que signés par l'acteur
+func digestDatos(t *testing.T, datos map[string]interface{}) []byte {
+	t.Helper()
+	digest, err := hex.DecodeString(hashDatos(t, datos))
+	require.NoError(t, err)
+	return digest
+}
+
+// certificatAutoSigne génère un certificat X.509 ECDSA P-256 auto-signé
+func certificatAutoSigne(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+	cle, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	modele := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "laboratorio-x509"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, modele, modele, &cle.PublicKey, cle)
+	require.NoError(t, err)
+
+	return cle, string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestSignatureVerifier_VerificarFirma(t *testing.T) {
+	datos := map[string]interface{}{"cantidad": 100, "planta": "Planta A"}
+	datosAlteres := map[string]interface{}{"cantidad": 900, "planta": "Planta A"}
+	digest := digestDatos(t, datos)
+
+	// ECDSA secp256k1 (adresse Ethereum)
+	cleEth, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	firmaEth, err := ethcrypto.Sign(digest, cleEth)
+	require.NoError(t, err)
+
+	// Ed25519
+	publiqueEd, priveeEd, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	firmaEd := ed25519.Sign(priveeEd, digest)
+
+	// X.509
+	cleX509, certificado := certificatAutoSigne(t)
+	firmaX509, err := ecdsa.SignASN1(rand.Reader, cleX509, digest)
+	require.NoError(t, err)
+	firmaX509JSON, err := json.Marshal(models.FirmaDigital{Certificado: certificado, Firma: base64.StdEncoding.EncodeToString(firmaX509)})
+	require.NoError(t, err)
+
+	registry := services.NewMemoryActorRegistry()
+	registry.RegistrarClave(models.ClaveActor{IDActor: "fabricante-eth", Algoritmo: models.AlgoritmoFirmaECDSASecp256k1, Direccion: ethcrypto.PubkeyToAddress(cleEth.PublicKey).Hex()})
+	registry.RegistrarClave(models.ClaveActor{IDActor: "distribuidor-ed", Algoritmo: models.AlgoritmoFirmaEd25519, ClavePublica: hex.EncodeToString(publiqueEd)})
+	registry.RegistrarClave(models.ClaveActor{IDActor: "laboratorio-x509", Algoritmo: models.AlgoritmoFirmaX509, Certificado: certificado})
+
+	tests := []struct {
+		name             string
+		actor            string
+		firma            string
+		datos            map[string]interface{}
+		firmaObligatoria bool
+		valide           bool
+	}{
+		{name: "ECDSA secp256k1 valide", actor: "fabricante-eth", firma: "0x" + hex.EncodeToString(firmaEth), datos: datos, valide: true},
+		{name: "ECDSA secp256k1 sur données modifiées", actor: "fabricante-eth", firma: "0x" + hex.EncodeToString(firmaEth), datos: datosAlteres},
+		{name: "ECDSA signée par un autre acteur", actor: "distribuidor-ed", firma: "0x" + hex.EncodeToString(firmaEth), datos: datos},
+		{name: "Ed25519 valide", actor: "distribuidor-ed", firma: base64.StdEncoding.EncodeToString(firmaEd), datos: datos, valide: true},
+		{name: "X.509 valide avec certificat joint", actor: "laboratorio-x509", firma: string(firmaX509JSON), datos: datos, valide: true},
+		{name: "acteur non enregistré", actor: "inconnu", firma: base64.StdEncoding.EncodeToString(firmaEd), datos: datos},
+		{name: "signature absente tolérée", actor: "fabricante-eth", datos: datos, valide: true},
+		{name: "signature absente obligatoire", actor: "fabricante-eth", datos: datos, firmaObligatoria: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Arrange
+			verifier := services.NewSignatureVerifier(registry, tt.firmaObligatoria)
+			evento := &models.EventoVerificado{
+				IDProducto:            "prod-test-001",
+				IDEvento:              "evt-001",
+				Fecha:                 time.Now(),
+				DatosEvento:           tt.datos,
+				ActorEmisor:           tt.actor,
+				FirmaDigital:          tt.firma,
+				ResultadoVerificacion: models.VerificacionOK,
+			}
+
+			// Act
+			err := verifier.VerificarFirma(context.Background(), evento)
+
+			// Assert
+			if tt.valide {
+				assert.NoError(t, err)
+				assert.Equal(t, models.VerificacionOK, evento.ResultadoVerificacion)
+			} else {
+				assert.Error(t, err)
+				assert.Equal(t, models.VerificacionFirmaInvalida, evento.ResultadoVerificacion)
+			}
+		})
+	}
+}
+
+func TestSignatureVerifier_ReconstruirHistorial_FirmaInvalidaPublieInconsistencia(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	datos := map[string]interface{}{"cantidad": 100}
+	cleEth, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	cleFaussaire, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	firmaFalsa, err := ethcrypto.Sign(digestDatos(t, datos), cleFaussaire)
+	require.NoError(t, err)
+
+	registry := services.NewMemoryActorRegistry()
+	registry.RegistrarClave(models.ClaveActor{IDActor: "fabricante-eth", Algoritmo: models.AlgoritmoFirmaECDSASecp256k1, Direccion: ethcrypto.PubkeyToAddress(cleEth.PublicKey).Hex()})
+
+	repo := services.NewMemoryRepository()
+	require.NoError(t, repo.GuardarEvento(ctx, &models.EventoVerificado{
+		IDProducto:   "prod-test-001",
+		IDEvento:     "evt-001",
+		Fecha:        time.Now(),
+		DatosEvento:  datos,
+		ActorEmisor:  "fabricante-eth",
+		FirmaDigital: hex.EncodeToString(firmaFalsa),
+	}))
+	bus := services.NewMemoryEventBus(10)
+	service := services.NewHistorialService(repo, nil, bus, true)
+	service.DefinirVerificateurSignatures(services.NewSignatureVerifier(registry, false))
+
+	// Act
+	historial, err := service.ReconstruirHistorial(ctx, "prod-test-001", "", true)
+
+	// Assert
+	require.NoError(t, err)
+	assert.Equal(t, models.EstadoInconsistente, historial.EstadoActual)
+
+	publicados := bus.Publicados()
+	require.Len(t, publicados, 1)
+	assert.Equal(t, services.EventTypeHistorialInconsistencia, publicados[0].EventType)
+
+	var inconsistencia models.InconsistenciaEvent
+	require.NoError(t, json.Unmarshal(publicados[0].Payload, &inconsistencia))
+	require.Len(t, inconsistencia.Detalles, 1)
+	assert.Equal(t, models.VerificacionFirmaInvalida, inconsistencia.Detalles[0].Error)
+}
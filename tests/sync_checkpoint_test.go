@@ -0,0 +1,148 @@
+package services_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/edinfamous/historial-blockchain/internal/models"
+	"github.com/edinfamous/historial-blockchain/internal/services"
+)
+
+// repositoireComptant compte les lectures incrémentales de la table blockchain
+type repositoireComptant struct {
+	*services.MemoryRepository
+	lectures []*models.PuntoSincronizacion
+}
+
+func (r *repositoireComptant) ObtenerEventosBlockchainDesde(ctx context.Context, idProducto string, desde *models.PuntoSincronizacion) ([]models.BlockchainEvent, error) {
+	r.lectures = append(r.lectures, desde)
+	return r.MemoryRepository.ObtenerEventosBlockchainDesde(ctx, idProducto, desde)
+}
+
+func eventoBlockchainPrueba(idTransaction, updatedAt string) models.BlockchainEvent {
+	return models.BlockchainEvent{
+		IDTransaction: idTransaction,
+		IDProducto:    "prod-sync-001",
+		TipoEvento:    "fabricacion",
+		FechaEvento:   "2025-11-04T02:10:07Z",
+		DatosEvento:   `{"cantidad": 10}`,
+		UpdatedAt:     updatedAt,
+	}
+}
+
+func TestHistorialService_SynchroniserDepuisBlockchain_PointDeReprise(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := &repositoireComptant{MemoryRepository: services.NewMemoryRepository()}
+	repo.AgregarEventoBlockchain(eventoBlockchainPrueba("tx-001", "2025-11-04T02:10:07Z"))
+	repo.AgregarEventoBlockchain(eventoBlockchainPrueba("tx-002", "2025-11-04T03:00:00Z"))
+	service := services.NewHistorialService(repo, nil, nil, false)
+
+	// Act: première synchronisation complète
+	require.NoError(t, service.SynchroniserDepuisBlockchain(ctx, "prod-sync-001"))
+
+	// Assert
+	punto, err := repo.ObtenerPuntoSincronizacion(ctx, "prod-sync-001")
+	require.NoError(t, err)
+	require.NotNil(t, punto)
+	assert.Equal(t, "2025-11-04T03:00:00Z", punto.UltimoUpdatedAt)
+	assert.Equal(t, "tx-002", punto.UltimoIDTransaction)
+
+	// Act: une nouvelle ligne, seule lue au passage suivant
+	repo.AgregarEventoBlockchain(eventoBlockchainPrueba("tx-003", "2025-11-04T04:00:00Z"))
+	require.NoError(t, service.SynchroniserDepuisBlockchain(ctx, "prod-sync-001"))
+
+	// Assert
+	nouveaux, err := repo.MemoryRepository.ObtenerEventosBlockchainDesde(ctx, "prod-sync-001", punto)
+	require.NoError(t, err)
+	require.Len(t, nouveaux, 1)
+	assert.Equal(t, "tx-003", nouveaux[0].IDTransaction)
+
+	eventos, err := repo.ObtenerEventos(ctx, "prod-sync-001")
+	require.NoError(t, err)
+	assert.Len(t, eventos, 3)
+
+	punto, err = repo.ObtenerPuntoSincronizacion(ctx, "prod-sync-001")
+	require.NoError(t, err)
+	assert.Equal(t, "tx-003", punto.UltimoIDTransaction)
+}
+
+func TestHistorialService_ObtenerHistorial_FenetreFraicheur(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := &repositoireComptant{MemoryRepository: services.NewMemoryRepository()}
+	repo.AgregarEventoBlockchain(eventoBlockchainPrueba("tx-001", "2025-11-04T02:10:07Z"))
+	service := services.NewHistorialService(repo, nil, services.NewMemoryEventBus(10), false)
+	service.DefinirFenetreFraicheur(time.Minute)
+
+	// Act: la première lecture synchronise, la seconde est dans la fenêtre
+	_, err := service.ObtenerHistorial(ctx, "prod-sync-001", "")
+	require.NoError(t, err)
+	_, err = service.ObtenerHistorial(ctx, "prod-sync-001", "")
+	require.NoError(t, err)
+
+	// Assert
+	assert.Len(t, repo.lectures, 1)
+
+	// Act: une reconstruction forcée relit toute la table sans tenir compte du point de reprise
+	_, err = service.ReconstruirHistorial(ctx, "prod-sync-001", "", true)
+	require.NoError(t, err)
+
+	// Assert
+	require.Len(t, repo.lectures, 2)
+	assert.Nil(t, repo.lectures[1])
+}
+
+// repositoireConcurrent simule un autre processus qui enregistre et vérifie un événement juste
+// après la lecture des événements existants par la synchronisation
+type repositoireConcurrent struct {
+	*services.MemoryRepository
+	intercale models.EventoVerificado
+}
+
+func (r *repositoireConcurrent) ObtenerEventosLote(ctx context.Context, claves []services.ClaveEvento) ([]models.EventoVerificado, error) {
+	eventos, err := r.MemoryRepository.ObtenerEventosLote(ctx, claves)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.MemoryRepository.GuardarEvento(ctx, &r.intercale); err != nil {
+		return nil, err
+	}
+	return eventos, r.MemoryRepository.ActualizarResultadoVerificacion(ctx, &r.intercale)
+}
+
+func TestHistorialService_SynchroniserDepuisBlockchain_NEcrasePasUnEvenementConcurrent(t *testing.T) {
+	// Arrange
+	ctx := context.Background()
+	repo := &repositoireConcurrent{
+		MemoryRepository: services.NewMemoryRepository(),
+		intercale: models.EventoVerificado{
+			IDProducto:            "prod-sync-001",
+			IDEvento:              "tx-001",
+			ResultadoVerificacion: models.VerificacionOK,
+			Observaciones:         "Vérifié par un autre processus",
+		},
+	}
+	repo.AgregarEventoBlockchain(eventoBlockchainPrueba("tx-001", "2025-11-04T02:10:07Z"))
+	repo.AgregarEventoBlockchain(eventoBlockchainPrueba("tx-002", "2025-11-04T03:00:00Z"))
+	service := services.NewHistorialService(repo, nil, nil, false)
+
+	// Act
+	require.NoError(t, service.SynchroniserDepuisBlockchain(ctx, "prod-sync-001"))
+
+	// Assert: l'événement inséré entre la lecture et l'écriture est conservé tel quel
+	concurrent, err := repo.ObtenerEvento(ctx, "prod-sync-001", "tx-001")
+	require.NoError(t, err)
+	require.NotNil(t, concurrent)
+	assert.Equal(t, "Vérifié par un autre processus", concurrent.Observaciones)
+	assert.Equal(t, int64(2), concurrent.Version)
+
+	nouveau, err := repo.ObtenerEvento(ctx, "prod-sync-001", "tx-002")
+	require.NoError(t, err)
+	require.NotNil(t, nouveau)
+	assert.Equal(t, int64(1), nouveau.Version)
+}